@@ -0,0 +1,88 @@
+package main
+
+import (
+  "cache"
+  "fmt"
+  "strconv"
+  "testing"
+  "time"
+)
+
+//基准测试使用的键数量
+const benchKeys = 1024
+
+//创建写入了 benchKeys 个数据项的缓存
+func newBenchCache(opts ...cache.Option) (*cache.Cache, []string) {
+  c := cache.New(opts...)
+  keys := make([]string, benchKeys)
+  for i := range keys {
+    keys[i] = "key:" + strconv.Itoa(i)
+    c.Set(keys[i], i, cache.WithTTL(time.Hour))
+  }
+  return c, keys
+}
+
+//只测试读取，写入在计时开始前完成
+func benchGet(opts ...cache.Option) func(b *testing.B) {
+  return func(b *testing.B) {
+    c, keys := newBenchCache(opts...)
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+      c.Get(keys[i % benchKeys])
+    }
+  }
+}
+
+func benchGetParallel(opts ...cache.Option) func(b *testing.B) {
+  return func(b *testing.B) {
+    c, keys := newBenchCache(opts...)
+    b.ReportAllocs()
+    b.ResetTimer()
+    b.RunParallel(func(pb *testing.PB) {
+      for i := 0; pb.Next(); i++ {
+        c.Get(keys[i % benchKeys])
+      }
+    })
+  }
+}
+
+func benchGetMiss(b *testing.B) {
+  c, _ := newBenchCache()
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Get("missing")
+  }
+}
+
+func benchSet(b *testing.B) {
+  c, keys := newBenchCache()
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Set(keys[i % benchKeys], i)
+  }
+}
+
+//运行缓存读写路径的基准测试，输出每次操作的耗时和内存分配，用于比较修改前后的结果。
+//同样的基准测试在 cache 包的 bench_test.go 中，可以用 go test -bench 重复运行
+func main() {
+  benchmarks := []struct {
+    name string
+    f    func(b *testing.B)
+  } {
+    {"Get", benchGet()},
+    {"GetParallel", benchGetParallel()},
+    {"GetMiss", benchGetMiss},
+    {"GetLRU", benchGet(cache.WithLRU(), cache.WithMaxEntries(benchKeys * 4))},
+    {"GetLockFree", benchGet(cache.WithLockFreeReads())},
+    {"GetLockFreeParallel", benchGetParallel(cache.WithLockFreeReads())},
+    {"GetLockProfiling", benchGetParallel(cache.WithLockProfiling())},
+    {"Set", benchSet},
+  }
+  for _, bm := range benchmarks {
+    r := testing.Benchmark(bm.f)
+    fmt.Printf("%-20s %10d %10d ns/op %6d B/op %4d allocs/op\n", bm.name, r.N, r.NsPerOp(), r.AllocedBytesPerOp(), r.AllocsPerOp())
+  }
+}
@@ -0,0 +1,365 @@
+package cache
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/binary"
+  "encoding/gob"
+  "fmt"
+  "hash/crc32"
+  "io"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sync"
+  "time"
+  "weak"
+)
+
+//追加日志写入磁盘的间隔，进程崩溃时最多丢失这段时间内的写入
+const aofSyncInterval = time.Second
+
+//追加日志中的一条记录，Op 为 EventSet、EventDelete 或 EventFlush
+type aofRecord struct {
+  Op   EventType
+  Key  string
+  Item Item
+}
+
+//重写期间产生的记录，s 为记录所属的分片
+type pendingRecord struct {
+  s *shard
+  r aofRecord
+}
+
+//只追加的持久化日志。每条记录按帧写入：4 字节长度、4 字节 CRC32、gob 数据，
+//一个文件是同一个 gob 编码器的输出，重放时遇到损坏或不完整的帧就停止
+type aof struct {
+  rewriteMu sync.Mutex  // 串行化重写
+  mu        sync.Mutex
+  path      string
+  f         *os.File
+  w         *bufio.Writer
+  enc       *gob.Encoder
+  buf       *bytes.Buffer  // 编码器的输出，每条记录编码后作为一帧写入 w
+  err       error         // 第一次写入失败的错误，之后不再写入
+  closed    bool
+  rewriting bool
+  aborted   bool          // 重写期间发生了清空，重写的结果作废
+  pending   []pendingRecord
+  stop      chan struct{}
+  done      chan struct{}
+  closeOnce sync.Once
+  closeErr  error
+}
+
+//打开 path 上的追加日志，重放已有的记录后返回缓存，之后每次写入、删除和清空都会追加到日志中。
+//打开时会把日志重写为当前数据项的快照，使用 WithAOFRewrite 可以定期重写。
+//日志每隔一秒写入磁盘，Close 时写入剩余的记录
+func OpenWithAOF(path string, opts ...Option) (*Cache, error) {
+  c := New(opts...)
+  items, err := replayAOF(path, c.now())
+  if err == nil {
+    err = c.load(items, nil)
+  }
+  if err != nil {
+    c.Close()
+    return nil, err
+  }
+  a := &aof{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+  c.lockAll()
+  c.aof = a
+  interval := c.aofRewrite
+  c.unlockAll()
+  go aofLoop(weak.Make(c), a, interval, c.log())
+  //缓存未调用 Close 就被丢弃时，也把剩余的记录写入文件
+  runtime.AddCleanup(c, func(a *aof) { a.close() }, a)
+  if err := c.RewriteAOF(); err != nil {
+    c.Close()
+    return nil, err
+  }
+  return c, nil
+}
+
+//读取日志中的记录，返回重放后在 now 时未过期的数据项，文件不存在时返回空的结果
+func replayAOF(path string, now int64) (map[string]Item, error) {
+  items := map[string]Item{}
+  f, err := os.Open(path)
+  if os.IsNotExist(err) {
+    return items, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  dec := gob.NewDecoder(&frameReader{r: bufio.NewReader(f)})
+  for {
+    var r aofRecord
+    //日志结尾不完整时，已经读出的记录仍然有效，遇到未注册的类型时返回错误
+    if err := decodeError(dec.Decode(&r)); err != nil {
+      if _, ok := err.(*UnregisteredTypeError); ok {
+        return nil, err
+      }
+      break
+    }
+    switch r.Op {
+    case EventSet:
+      items[r.Key] = r.Item
+    case EventDelete:
+      delete(items, r.Key)
+    case EventFlush:
+      items = map[string]Item{}
+    }
+  }
+  for k, item := range items {
+    if item.Expiration > 0 && now > item.Expiration {
+      delete(items, k)
+    }
+  }
+  return items, nil
+}
+
+//逐帧读取并校验，遇到损坏或不完整的帧时返回 io.EOF
+type frameReader struct {
+  r   *bufio.Reader
+  cur []byte
+}
+
+func (fr *frameReader) Read(p []byte) (int, error) {
+  for len(fr.cur) == 0 {
+    var h [8]byte
+    if _, err := io.ReadFull(fr.r, h[:]); err != nil {
+      return 0, io.EOF
+    }
+    n := binary.BigEndian.Uint32(h[:4])
+    b := make([]byte, n)
+    if _, err := io.ReadFull(fr.r, b); err != nil || crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(h[4:]) {
+      return 0, io.EOF
+    }
+    fr.cur = b
+  }
+  n := copy(p, fr.cur)
+  fr.cur = fr.cur[n:]
+  return n, nil
+}
+
+//在新文件上使用新的编码器
+func (a *aof) reset(f *os.File) {
+  a.f = f
+  a.w = bufio.NewWriter(f)
+  a.buf = &bytes.Buffer{}
+  a.enc = gob.NewEncoder(a.buf)
+}
+
+//编码一条记录并作为一帧写入，需要持有 mu
+func (a *aof) write(r aofRecord) {
+  if a.err != nil {
+    return
+  }
+  if r.Op == EventSet {
+    if err := checkTypes(map[string]Item{r.Key: r.Item}); err != nil {
+      a.err = err
+      return
+    }
+  }
+  if err := a.enc.Encode(&r); err != nil {
+    a.err = err
+    return
+  }
+  var h [8]byte
+  binary.BigEndian.PutUint32(h[:4], uint32(a.buf.Len()))
+  binary.BigEndian.PutUint32(h[4:], crc32.ChecksumIEEE(a.buf.Bytes()))
+  a.w.Write(h[:])
+  if _, err := a.w.Write(a.buf.Bytes()); err != nil {
+    a.err = err
+  }
+  a.buf.Reset()
+}
+
+//追加一条记录，在持有 s 的写锁时调用，清空时 s 为 nil
+func (a *aof) append(s *shard, r aofRecord) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.closed {
+    return
+  }
+  if a.rewriting {
+    if r.Op == EventFlush {
+      a.aborted = true
+    } else {
+      a.pending = append(a.pending, pendingRecord{s, r})
+    }
+  }
+  //第一次重写完成前还没有文件
+  if a.f != nil {
+    a.write(r)
+  }
+}
+
+//把事件追加到日志，过期不记录，淘汰按删除记录
+func (c *Cache) logAOF(t EventType, k string, item Item) {
+  switch t {
+  case EventSet:
+    if transient(item) {
+      c.aof.append(c.shard(k), aofRecord{Op: EventDelete, Key: k})
+      return
+    }
+    c.aof.append(c.shard(k), aofRecord{Op: EventSet, Key: k, Item: item})
+  case EventDelete, EventEvict:
+    c.aof.append(c.shard(k), aofRecord{Op: EventDelete, Key: k})
+  case EventFlush:
+    c.aof.append(nil, aofRecord{Op: EventFlush})
+  }
+}
+
+//把追加日志重写为当前数据项的快照，重写期间的写入不会被阻塞。没有使用 OpenWithAOF 时返回错误
+func (c *Cache) RewriteAOF() error {
+  a := c.aof
+  if a == nil {
+    return fmt.Errorf("Cache has no append-only log.")
+  }
+  a.rewriteMu.Lock()
+  defer a.rewriteMu.Unlock()
+  a.mu.Lock()
+  if a.closed {
+    a.mu.Unlock()
+    return ErrClosed
+  }
+  a.rewriting, a.aborted, a.pending = true, false, nil
+  a.mu.Unlock()
+  f, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp*")
+  if err != nil {
+    a.endRewrite()
+    return err
+  }
+  nw := &aof{}
+  nw.reset(f)
+  //记下复制每个分片时已有的重写期间的记录，之后的记录需要追加到新文件中
+  pos := make(map[*shard]int, len(c.shards))
+  items := map[string]Item{}
+  for _, s := range c.shards {
+    s.mu.RLock()
+    a.mu.Lock()
+    pos[s] = len(a.pending)
+    a.mu.Unlock()
+    for k, v := range s.items {
+      if !c.expired(v) && !transient(v) {
+        items[k] = v
+      }
+    }
+    s.mu.RUnlock()
+    for k, v := range items {
+      nw.write(aofRecord{Op: EventSet, Key: k, Item: v})
+    }
+    clear(items)
+  }
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.aborted || a.closed {
+    a.rewriting, a.pending = false, nil
+    f.Close()
+    os.Remove(f.Name())
+    return nil
+  }
+  for i, p := range a.pending {
+    if i >= pos[p.s] {
+      nw.write(p.r)
+    }
+  }
+  a.rewriting, a.pending = false, nil
+  err = nw.err
+  if err == nil {
+    err = nw.w.Flush()
+  }
+  if err == nil {
+    err = f.Sync()
+  }
+  if err == nil {
+    err = os.Rename(f.Name(), a.path)
+  }
+  if err != nil {
+    f.Close()
+    os.Remove(f.Name())
+    return err
+  }
+  if a.f != nil {
+    a.w.Flush()
+    a.f.Close()
+  }
+  //继续使用新文件的编码器，类型信息已经写在新文件中
+  a.f, a.w, a.enc, a.buf = nw.f, nw.w, nw.enc, nw.buf
+  a.err = nil
+  return nil
+}
+
+func (a *aof) endRewrite() {
+  a.mu.Lock()
+  a.rewriting, a.pending = false, nil
+  a.mu.Unlock()
+}
+
+//把缓冲的记录写入磁盘
+func (a *aof) sync() error {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  if a.f == nil {
+    return a.err
+  }
+  if err := a.w.Flush(); err != nil && a.err == nil {
+    a.err = err
+  }
+  if err := a.f.Sync(); err != nil && a.err == nil {
+    a.err = err
+  }
+  return a.err
+}
+
+//定期写入磁盘，rewrite 大于 0 时定期重写。只持有缓存的弱引用
+func aofLoop(p weak.Pointer[Cache], a *aof, rewrite time.Duration, log Logger) {
+  defer close(a.done)
+  ticker := time.NewTicker(aofSyncInterval)
+  defer ticker.Stop()
+  last := time.Now()
+  var failed error  // 写入失败后不再重复记录同一个错误
+  for {
+    select {
+    case <-ticker.C:
+      if err := a.sync(); err != nil && err != failed {
+        log.Errorf("Writing append-only log %s failed: %v", a.path, err)
+        failed = err
+      }
+      if rewrite <= 0 || time.Since(last) < rewrite {
+        continue
+      }
+      c := p.Value()
+      if c == nil {
+        return
+      }
+      if err := c.RewriteAOF(); err != nil {
+        log.Errorf("Rewriting append-only log %s failed: %v", a.path, err)
+      }
+      last = time.Now()
+    case <-a.stop:
+      return
+    }
+  }
+}
+
+//停止后台写入，写入剩余的记录并关闭文件，可以重复调用
+func (a *aof) close() error {
+  a.closeOnce.Do(func() {
+    close(a.stop)
+    <-a.done
+    err := a.sync()
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.closed = true
+    if a.f != nil {
+      if cerr := a.f.Close(); err == nil {
+        err = cerr
+      }
+    }
+    a.closeErr = err
+  })
+  return a.closeErr
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "sync"
+  "testing"
+  "time"
+)
+
+func openAOF(t *testing.T, path string, opts ...Option) *Cache {
+  t.Helper()
+  c, err := OpenWithAOF(path, opts...)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return c
+}
+
+//模拟进程崩溃：写入缓冲的记录后，把此时磁盘上的日志复制到新目录中，返回副本的路径
+func crashCopy(t *testing.T, c *Cache) string {
+  t.Helper()
+  if err := c.aof.sync(); err != nil {
+    t.Fatal(err)
+  }
+  data, err := os.ReadFile(c.aof.path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  path := filepath.Join(t.TempDir(), "aof")
+  if err := os.WriteFile(path, data, 0644); err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+//重放写入、删除和清空的记录
+func TestAOFReplay(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "aof")
+  c := openAOF(t, path)
+  c.Set("gone", 1)
+  c.Flush()
+  c.Set("a", "x")
+  c.Set("b", 2)
+  c.Set("c", 3)
+  c.Delete("b")
+  c.Set("a", "y")
+  if err := c.Close(); err != nil {
+    t.Fatal(err)
+  }
+  c = openAOF(t, path)
+  defer c.Close()
+  if got := present(c, "gone", "a", "b", "c"); fmt.Sprint(got) != "[a c]" {
+    t.Fatalf("replayed items %v, want [a c]", got)
+  }
+  if v, _ := c.Get("a"); v != "y" {
+    t.Fatalf("a = %v after replay, want y", v)
+  }
+}
+
+//重放时跳过已经过期的数据项
+func TestAOFReplayExpired(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "aof")
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := openAOF(t, path, WithClock(clock))
+  c.Set("short", 1, WithTTL(time.Second))
+  c.Set("long", 2, WithTTL(time.Hour))
+  c.Close()
+  clock.Advance(time.Minute)
+  c = openAOF(t, path, WithClock(clock))
+  defer c.Close()
+  if got := present(c, "short", "long"); fmt.Sprint(got) != "[long]" {
+    t.Fatalf("replayed items %v, want [long]", got)
+  }
+}
+
+//崩溃时最后一条记录只写了一部分，之前的记录仍然重放
+func TestAOFTornTail(t *testing.T) {
+  c := openAOF(t, filepath.Join(t.TempDir(), "aof"))
+  defer c.Close()
+  c.Set("a", 1)
+  c.Set("b", 2)
+  path := crashCopy(t, c)
+  c.Set("c", 3)
+  full, _ := os.ReadFile(crashCopy(t, c))
+  before, _ := os.ReadFile(path)
+  //只保留最后一帧的一半
+  torn := full[:len(before)+(len(full)-len(before))/2]
+  if err := os.WriteFile(path, torn, 0644); err != nil {
+    t.Fatal(err)
+  }
+  r := openAOF(t, path)
+  defer r.Close()
+  if got := present(r, "a", "b", "c"); fmt.Sprint(got) != "[a b]" {
+    t.Fatalf("replayed items %v, want [a b]", got)
+  }
+  //之后的写入接在重写后的日志上
+  r.Set("d", 4)
+  r.Close()
+  r = openAOF(t, path)
+  defer r.Close()
+  if got := present(r, "a", "b", "d"); fmt.Sprint(got) != "[a b d]" {
+    t.Fatalf("items after reopening %v, want [a b d]", got)
+  }
+}
+
+//重写过程中崩溃时日志还没有被替换，目录中只多出写了一半的临时文件，重放原来的日志
+func TestAOFCrashMidRewrite(t *testing.T) {
+  c := openAOF(t, filepath.Join(t.TempDir(), "aof"))
+  defer c.Close()
+  for i := 0; i < 100; i++ {
+    c.Set(fmt.Sprintf("k%d", i), i)
+  }
+  for i := 0; i < 100; i += 2 {
+    c.Delete(fmt.Sprintf("k%d", i))
+  }
+  path := crashCopy(t, c)
+  if err := c.RewriteAOF(); err != nil {
+    t.Fatal(err)
+  }
+  rewritten, err := os.ReadFile(c.aof.path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := os.WriteFile(path+".tmp123", rewritten[:len(rewritten)/2], 0644); err != nil {
+    t.Fatal(err)
+  }
+  r := openAOF(t, path)
+  defer r.Close()
+  if r.Count() != 50 {
+    t.Fatalf("Count() = %d after replay, want 50", r.Count())
+  }
+  for i := 0; i < 100; i++ {
+    k := fmt.Sprintf("k%d", i)
+    if _, found := r.Get(k); found != (i%2 == 1) {
+      t.Fatalf("%s present = %v after replay, want %v", k, found, i%2 == 1)
+    }
+  }
+}
+
+//重写期间的写入同时进入新日志，重写完成后崩溃也不会丢失
+func TestAOFRewriteConcurrentWrites(t *testing.T) {
+  c := openAOF(t, filepath.Join(t.TempDir(), "aof"), WithShards(4))
+  defer c.Close()
+  for i := 0; i < 1000; i++ {
+    c.Set(fmt.Sprintf("old%d", i), i)
+  }
+  var wg sync.WaitGroup
+  for g := 0; g < 4; g++ {
+    wg.Add(1)
+    go func(g int) {
+      defer wg.Done()
+      for i := 0; i < 250; i++ {
+        c.Set(fmt.Sprintf("new%d-%d", g, i), i)
+        c.Delete(fmt.Sprintf("old%d", g*250+i))
+      }
+    }(g)
+  }
+  for i := 0; i < 5; i++ {
+    if err := c.RewriteAOF(); err != nil {
+      t.Fatal(err)
+    }
+  }
+  wg.Wait()
+  if err := c.RewriteAOF(); err != nil {
+    t.Fatal(err)
+  }
+  r := openAOF(t, crashCopy(t, c), WithShards(4))
+  defer r.Close()
+  if r.Count() != 1000 {
+    t.Fatalf("Count() = %d after replay, want 1000", r.Count())
+  }
+  for g := 0; g < 4; g++ {
+    for i := 0; i < 250; i++ {
+      if _, found := r.Get(fmt.Sprintf("new%d-%d", g, i)); !found {
+        t.Fatalf("new%d-%d written during a rewrite was lost", g, i)
+      }
+    }
+  }
+}
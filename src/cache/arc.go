@@ -0,0 +1,111 @@
+package cache
+
+import (
+  "container/list"
+)
+
+//ARC（Adaptive Replacement Cache）的访问顺序。t1 是只访问过一次的数据项，t2 是访问过多次的数据项，
+//b1 和 b2 是最近从 t1 和 t2 淘汰的键。新数据项的键在 b1 中时说明 t1 太小，在 b2 中时说明 t2 太小，
+//据此调整 t1 的目标大小 p，在扫描和热点访问交替的负载下自动偏向最近使用或访问频率
+type arc struct {
+  t1, t2 *list.List  // 最近使用的在前
+  b1, b2 *list.List
+  index  map[string]*list.Element
+  p      int
+}
+
+type arcEntry struct {
+  key  string
+  list *list.List
+}
+
+func newARC() *arc {
+  return &arc{
+    t1: list.New(),
+    t2: list.New(),
+    b1: list.New(),
+    b2: list.New(),
+    index: map[string]*list.Element{},
+  }
+}
+
+//分片内的数据项数量，也是 p 的上限和每个幽灵列表的长度上限
+func (a *arc) capacity() int {
+  return max(a.t1.Len() + a.t2.Len(), 1)
+}
+
+func (a *arc) move(e *list.Element, to *list.List) {
+  entry := e.Value.(*arcEntry)
+  entry.list.Remove(e)
+  entry.list = to
+  a.index[entry.key] = to.PushFront(entry)
+}
+
+//访问数据项，已经在 t1 或 t2 中的移到 t2 的最前面，新数据项放入 t1，
+//键在幽灵列表中时调整 p 并直接放入 t2
+func (a *arc) access(k string) {
+  e, found := a.index[k]
+  if !found {
+    entry := &arcEntry{k, a.t1}
+    a.index[k] = a.t1.PushFront(entry)
+    return
+  }
+  switch e.Value.(*arcEntry).list {
+  case a.b1:
+    a.p = min(a.p + max(a.b2.Len() / a.b1.Len(), 1), a.capacity())
+  case a.b2:
+    a.p = max(a.p - max(a.b1.Len() / a.b2.Len(), 1), 0)
+  }
+  a.move(e, a.t2)
+}
+
+//移除数据项，幽灵列表中的键保留，需要持有写锁
+func (a *arc) remove(k string) {
+  e, found := a.index[k]
+  if !found {
+    return
+  }
+  entry := e.Value.(*arcEntry)
+  if entry.list == a.t1 || entry.list == a.t2 {
+    entry.list.Remove(e)
+    delete(a.index, k)
+  }
+}
+
+//数据项因容量上限被淘汰前调用，把键移到对应的幽灵列表，需要持有写锁
+func (a *arc) ghost(k string) {
+  e, found := a.index[k]
+  if !found {
+    return
+  }
+  to := a.b1
+  switch e.Value.(*arcEntry).list {
+  case a.t2:
+    to = a.b2
+  case a.b1, a.b2:
+    return
+  }
+  a.move(e, to)
+  for to.Len() > a.capacity() {
+    old := to.Back()
+    to.Remove(old)
+    delete(a.index, old.Value.(*arcEntry).key)
+  }
+}
+
+//按淘汰顺序遍历键，t1 超过目标大小时先从 t1 的末尾淘汰，否则先从 t2 的末尾淘汰，
+//f 返回 false 时停止。f 中可以淘汰当前的键
+func (a *arc) victims(f func(k string) bool) {
+  e1, e2 := a.t1.Back(), a.t2.Back()
+  for e1 != nil || e2 != nil {
+    e := e2
+    if e1 != nil && (e2 == nil || a.t1.Len() > a.p) {
+      e, e1 = e1, e1.Prev()
+    } else {
+      e2 = e2.Prev()
+    }
+    if !f(e.Value.(*arcEntry).key) {
+      return
+    }
+  }
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+  "os"
+  "path/filepath"
+  "time"
+  "weak"
+)
+
+//每隔 interval 把缓存保存到 path，先写临时文件再原子地重命名覆盖目标文件。
+//保存失败时调用 onError，可以为 nil。再次调用会替换之前的自动保存，缓存关闭后调用无效
+func (c *Cache) StartAutoSave(path string, interval time.Duration, onError func(error)) {
+  //停止、检查和替换在同一次加锁内完成，同时调用时不会遗留之前的 goroutine
+  c.autoSaveMu.Lock()
+  defer c.autoSaveMu.Unlock()
+  c.stopAutoSave()
+  stop, done := make(chan struct{}), make(chan struct{})
+  c.shards[0].mu.RLock()
+  closed := c.closed
+  c.shards[0].mu.RUnlock()
+  if closed {
+    return
+  }
+  c.autoSaveStop, c.autoSaveDone = stop, done
+  go autoSaveLoop(weak.Make(c), path, c.clock.NewTicker(interval), onError, stop, done)
+}
+
+//只持有缓存的弱引用，缓存被回收后在下一次保存时退出
+func autoSaveLoop(p weak.Pointer[Cache], path string, ticker Ticker, onError func(error), stop, done chan struct{}) {
+  defer close(done)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C():
+      c := p.Value()
+      if c == nil {
+        return
+      }
+      if err := c.saveFileAtomic(path); err != nil {
+        c.log().Errorf("Auto-save to %s failed: %v", path, err)
+        if onError != nil {
+          onError(err)
+        }
+      }
+    case <-stop:
+      return
+    }
+  }
+}
+
+//停止自动保存，等待正在进行的保存完成后返回，不能在 onError 中调用
+func (c *Cache) StopAutoSave() {
+  c.autoSaveMu.Lock()
+  defer c.autoSaveMu.Unlock()
+  c.stopAutoSave()
+}
+
+//需要持有 autoSaveMu
+func (c *Cache) stopAutoSave() {
+  if c.autoSaveStop != nil {
+    close(c.autoSaveStop)
+    <-c.autoSaveDone
+  }
+  c.autoSaveStop, c.autoSaveDone = nil, nil
+}
+
+//保存到同目录下的临时文件后重命名，目标文件不会出现写了一半的状态
+func (c *Cache) saveFileAtomic(path string) error {
+  f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+  if err != nil {
+    return err
+  }
+  tmp := f.Name()
+  if err = c.writeDump(f, GobSerializer{}); err == nil {
+    err = f.Sync()
+  }
+  if cerr := f.Close(); err == nil {
+    err = cerr
+  }
+  if err == nil {
+    err = os.Rename(tmp, path)
+  }
+  if err != nil {
+    os.Remove(tmp)
+  }
+  return err
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+  "path/filepath"
+  "runtime"
+  "sync"
+  "testing"
+  "time"
+)
+
+//同时调用 StartAutoSave 只保留最后一个自动保存，StopAutoSave 后没有遗留的 goroutine
+func TestStartAutoSaveConcurrent(t *testing.T) {
+  c := New(WithGCInterval(0))
+  path := filepath.Join(t.TempDir(), "dump")
+  before := runtime.NumGoroutine()
+  var wg sync.WaitGroup
+  for i := 0; i < 16; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      c.StartAutoSave(path, time.Hour, nil)
+    }()
+  }
+  wg.Wait()
+  waitGoroutines(t, before+1)
+  c.StopAutoSave()
+  waitGoroutines(t, before)
+}
+
+func TestStartAutoSaveClosed(t *testing.T) {
+  c := New()
+  c.Close()
+  before := runtime.NumGoroutine()
+  c.StartAutoSave(filepath.Join(t.TempDir(), "dump"), time.Hour, nil)
+  waitGoroutines(t, before)
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+  "strconv"
+  "testing"
+  "time"
+)
+
+//与 src/bench 中的程序相同的基准测试，可以用 go test -bench 运行并用 benchstat 比较
+const benchKeys = 1024
+
+//创建写入了 benchKeys 个数据项的缓存
+func newBenchCache(opts ...Option) (*Cache, []string) {
+  c := New(opts...)
+  keys := make([]string, benchKeys)
+  for i := range keys {
+    keys[i] = "key:" + strconv.Itoa(i)
+    c.Set(keys[i], i, WithTTL(time.Hour))
+  }
+  return c, keys
+}
+
+func benchGet(b *testing.B, opts ...Option) {
+  c, keys := newBenchCache(opts...)
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Get(keys[i % benchKeys])
+  }
+}
+
+func benchGetParallel(b *testing.B, opts ...Option) {
+  c, keys := newBenchCache(opts...)
+  b.ReportAllocs()
+  b.ResetTimer()
+  b.RunParallel(func(pb *testing.PB) {
+    for i := 0; pb.Next(); i++ {
+      c.Get(keys[i % benchKeys])
+    }
+  })
+}
+
+func BenchmarkGet(b *testing.B) {
+  benchGet(b)
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+  benchGetParallel(b)
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+  c, _ := newBenchCache()
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Get("missing")
+  }
+}
+
+func BenchmarkGetLRU(b *testing.B) {
+  benchGet(b, WithLRU(), WithMaxEntries(benchKeys * 4))
+}
+
+func BenchmarkGetLockFree(b *testing.B) {
+  benchGet(b, WithLockFreeReads())
+}
+
+func BenchmarkGetLockFreeParallel(b *testing.B) {
+  benchGetParallel(b, WithLockFreeReads())
+}
+
+func BenchmarkGetLockProfiling(b *testing.B) {
+  benchGetParallel(b, WithLockProfiling())
+}
+
+func BenchmarkSet(b *testing.B) {
+  c, keys := newBenchCache()
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Set(keys[i % benchKeys], i)
+  }
+}
+
+//写满 evictKeys 个数据项后继续写入新的键，每次写入都会淘汰一个数据项
+const evictKeys = 100000
+
+func benchSetEvict(b *testing.B, opts ...Option) {
+  c := New(append([]Option{WithShards(1), WithMaxEntries(evictKeys)}, opts...)...)
+  for i := 0; i < evictKeys; i++ {
+    c.Set("key:" + strconv.Itoa(i), i)
+  }
+  keys := make([]string, b.N)
+  for i := range keys {
+    keys[i] = "new:" + strconv.Itoa(i)
+  }
+  b.ReportAllocs()
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    c.Set(keys[i], i)
+  }
+}
+
+func BenchmarkSetEvict(b *testing.B) {
+  benchSetEvict(b)
+}
+
+func BenchmarkSetEvictLRU(b *testing.B) {
+  benchSetEvict(b, WithLRU())
+}
+
+func BenchmarkSetEvictARC(b *testing.B) {
+  benchSetEvict(b, WithARC())
+}
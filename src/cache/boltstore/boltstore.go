@@ -0,0 +1,210 @@
+package boltstore
+
+import (
+  "bytes"
+  "context"
+  "encoding/binary"
+  "encoding/gob"
+  "errors"
+  "time"
+
+  "cache"
+
+  bolt "go.etcd.io/bbolt"
+)
+
+//默认的 bucket 名称
+const DefaultBucket = "cache"
+
+//基于 bbolt 的后端存储，实现 cache.Store，每条记录保存过期时间，重启后仍然有效。
+//值使用 gob 编码，自定义类型需要先调用 cache.RegisterType
+type Store struct {
+  db     *bolt.DB
+  bucket []byte
+  owned  bool  // 由 Open 打开的数据库在 Close 时关闭
+}
+
+//用结构体包装值，gob 解码时还原接口中的具体类型
+type record struct {
+  Value interface{}
+}
+
+//打开或创建数据库文件，使用 DefaultBucket
+func Open(path string) (*Store, error) {
+  db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+  if err != nil {
+    return nil, err
+  }
+  s, err := New(db, DefaultBucket)
+  if err != nil {
+    db.Close()
+    return nil, err
+  }
+  s.owned = true
+  return s, nil
+}
+
+//使用已经打开的数据库中的 bucket，bucket 不存在时创建，Close 不会关闭 db
+func New(db *bolt.DB, bucket string) (*Store, error) {
+  s := &Store{db: db, bucket: []byte(bucket)}
+  err := db.Update(func(tx *bolt.Tx) error {
+    _, err := tx.CreateBucketIfNotExists(s.bucket)
+    return err
+  })
+  if err != nil {
+    return nil, err
+  }
+  return s, nil
+}
+
+//前 8 字节是过期时间的 UnixNano，之后是 gob 编码的值
+func encode(v interface{}, expiration int64) ([]byte, error) {
+  var buf bytes.Buffer
+  var head [8]byte
+  binary.BigEndian.PutUint64(head[:], uint64(expiration))
+  buf.Write(head[:])
+  if err := gob.NewEncoder(&buf).Encode(&record{v}); err != nil {
+    return nil, err
+  }
+  return buf.Bytes(), nil
+}
+
+func expirationOf(data []byte) int64 {
+  if len(data) < 8 {
+    return 0
+  }
+  return int64(binary.BigEndian.Uint64(data))
+}
+
+func expired(data []byte, now int64) bool {
+  e := expirationOf(data)
+  return e > 0 && now > e
+}
+
+func decode(data []byte) (interface{}, error) {
+  if len(data) < 8 {
+    return nil, errors.New("Record is too short.")
+  }
+  var r record
+  if err := gob.NewDecoder(bytes.NewReader(data[8:])).Decode(&r); err != nil {
+    return nil, err
+  }
+  return r.Value, nil
+}
+
+//读取数据项，不存在或已经过期时返回 cache.ErrNotFound，过期的记录由 DeleteExpired 删除
+func (s *Store) Get(ctx context.Context, key string) (interface{}, error) {
+  v, _, err := s.GetWithExpiration(ctx, key)
+  return v, err
+}
+
+//读取数据项及其过期时间，永不过期的数据项返回零值时间
+func (s *Store) GetWithExpiration(ctx context.Context, key string) (interface{}, time.Time, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, time.Time{}, err
+  }
+  var data []byte
+  err := s.db.View(func(tx *bolt.Tx) error {
+    //bbolt 返回的切片只在事务内有效
+    data = bytes.Clone(tx.Bucket(s.bucket).Get([]byte(key)))
+    return nil
+  })
+  if err != nil {
+    return nil, time.Time{}, err
+  }
+  if data == nil || expired(data, time.Now().UnixNano()) {
+    return nil, time.Time{}, cache.ErrNotFound
+  }
+  v, err := decode(data)
+  if err != nil {
+    return nil, time.Time{}, err
+  }
+  if e := expirationOf(data); e > 0 {
+    return v, time.Unix(0, e), nil
+  }
+  return v, time.Time{}, nil
+}
+
+//写入数据项，d 小于等于 0 表示永不过期
+func (s *Store) Set(ctx context.Context, key string, value interface{}, d time.Duration) error {
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+  var e int64
+  if d > 0 {
+    e = time.Now().Add(d).UnixNano()
+  }
+  data, err := encode(value, e)
+  if err != nil {
+    return err
+  }
+  return s.db.Update(func(tx *bolt.Tx) error {
+    return tx.Bucket(s.bucket).Put([]byte(key), data)
+  })
+}
+
+//删除数据项，不存在时不返回错误
+func (s *Store) Delete(ctx context.Context, key string) error {
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+  return s.db.Update(func(tx *bolt.Tx) error {
+    return tx.Bucket(s.bucket).Delete([]byte(key))
+  })
+}
+
+//删除已经过期的记录，返回删除的数量
+func (s *Store) DeleteExpired() (int, error) {
+  now := time.Now().UnixNano()
+  var keys [][]byte
+  err := s.db.Update(func(tx *bolt.Tx) error {
+    b := tx.Bucket(s.bucket)
+    //遍历期间不能修改 bucket，先收集再删除
+    err := b.ForEach(func(k, v []byte) error {
+      if expired(v, now) {
+        keys = append(keys, bytes.Clone(k))
+      }
+      return nil
+    })
+    if err != nil {
+      return err
+    }
+    for _, k := range keys {
+      if err := b.Delete(k); err != nil {
+        return err
+      }
+    }
+    return nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return len(keys), nil
+}
+
+//每隔 interval 删除一次过期的记录，直到 ctx 结束
+func (s *Store) Janitor(ctx context.Context, interval time.Duration) {
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C:
+      s.DeleteExpired()
+    case <-ctx.Done():
+      return
+    }
+  }
+}
+
+//返回底层的数据库
+func (s *Store) DB() *bolt.DB {
+  return s.db
+}
+
+//关闭由 Open 打开的数据库，New 传入的数据库由调用者关闭
+func (s *Store) Close() error {
+  if s.owned {
+    return s.db.Close()
+  }
+  return nil
+}
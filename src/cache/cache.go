@@ -3,18 +3,34 @@ package cache
 import (
   "fmt"
   "time"
-  "encoding/gob"
   "io"
   "sync"
+  "sync/atomic"
   "os"
+  "path"
+  "math/rand"
+  "runtime/debug"
+  "sort"
+  "strings"
 )
 
 type Item struct {
   Object interface{}  // 真正的数据项
   Expiration int64    // 生存时间
+  Cost int64          // 数据项的成本，用于容量限制
+  Sliding int64       // 滑动过期的时长，每次读取后按此延长生存时间，0 表示不滑动
+  Version uint64      // 每次写入时分配的版本号，在整个缓存内递增
+  Priority Priority   // 淘汰的优先级，低优先级的数据项先被淘汰
+  Pinned bool         // 固定的数据项不会因容量限制被淘汰
+  ComputeTime int64   // 通过 GetOrCompute 等方法计算数据项耗费的时间，XFetch 据此提前刷新
+  Compressed uint8    // 值的压缩方式，0 表示未压缩，压缩时 Object 为 gzip 数据
+  Created int64       // 写入的时间
+  Negative bool       // 缓存的“不存在”结果，Object 为 nil，Get 时视为未命中
+  NoCopy bool         // 使用 WithNoCopy 写入，开启 WithCopyOnRead 时读取也不复制
+  access *accessInfo  // 读取次数和最近一次读取的时间，不会被保存
 }
 
-//判断数据项是否已经过期
+//按系统时间判断数据项是否已经过期
 func (item Item) Expired() bool {
   if item.Expiration == 0 {
     return false
@@ -30,169 +46,1138 @@ const (
 )
 
 type Cache struct {
+  stats                counters  // 原子访问的字段放在前面以保证 32 位平台上的对齐
+  cost                 int64  // 当前数据项的成本总和，原子访问
+  entries              int64  // 当前数据项的数量，原子访问
+  lastSweep            int64  // 最近一次完成过期清理的时间，原子访问
+  sweepDuration        int64  // 最近一次过期清理的耗时，原子访问
+  version              uint64  // 最近一次分配的版本号，原子访问
+  gcStopped            int32  // 过期清理是否已停止，原子访问
+  subscribers          int32  // 订阅者的数量，原子访问
+  gcCursor             int32  // 有清理预算时下一次清理开始的分片，原子访问
+  evictionsSeen        uint64  // 上一次定期清理时的淘汰总数，原子访问
   defaultExpiration    time.Duration
-  items                map[string]Item
-  mu                   sync.RWMutex
+  shards               []*shard
   gcInterval           time.Duration
-  stopGc               chan bool
+  gc                   *gcStopper
+  reaper               *Reaper  // 共享的过期清理器，为 nil 时使用自己的 gcLoop
+  clock                Clock
+  staleFor             time.Duration  // 过期后继续保留的时间
+  xfetchBeta           float64  // LoadingCache 提前刷新的系数，0 表示关闭
+  tracer               Tracer   // 追踪钩子，为 nil 时不追踪
+  logger               Logger   // 后台任务的日志，为 nil 时不输出
+
+  //以下配置在修改时需要持有全部分片的写锁，读取时持有任一分片的锁即可
+  maxCost              int64  // 成本上限，0 表示不限制
+  maxEntries           int64  // 数据项数量上限，0 表示不限制
+  dryRun               bool   // 演练模式下只记录淘汰决定，不删除数据项
+  onDryRun             func(string, EvictionReason)
+  onEvicted            func(string, interface{})
+  onRemoved            func(string, interface{}, EvictionReason)
+  journal              *Journal  // 数据项生命周期事件日志，可以为 nil
+  freeOSMemoryAt       int      // 一次删除达到该数量时归还内存给操作系统，0 表示关闭
+  jitterFraction       float64  // 生存时间随机浮动的比例
+  sizer                Sizer    // 计算数据项成本的函数，为 nil 时成本为 1
+  estimator            Sizer    // SizeBytes 估算字节数的函数，为 nil 时使用 EstimateSize
+  maxItemBytes         int64    // 单个数据项的字节数上限，0 表示不限制
+  oversize             OversizePolicy
+  sliding              bool     // 是否对所有带过期时间的数据项使用滑动过期
+  closed               bool     // 是否已调用 Close
+  frozen               bool     // 是否已调用 Freeze
+  sorted               bool     // Save、ExportJSONL 和 Range 是否按键的顺序遍历
+  reclaim              bool     // 是否把丢弃的 map 交给后台回收器
+  cloner               func(interface{}) interface{}  // WithCopyOnRead 设置的复制函数，为 nil 时不复制，创建后不再修改
+  merge                MergeFunc  // 读取的数据项与已有数据项冲突时的合并方式，为 nil 时保留已有的
+  aof                  *aof     // 追加日志，为 nil 时不记录
+  compressAt           int      // 压缩值的长度阈值，0 表示不压缩
+  persistKey           []byte   // 转储文件的 AES 密钥，为 nil 时不加密
+  aofRewrite           time.Duration  // 追加日志的重写间隔，0 表示只在打开时重写
+  gcMaxItems           int      // 每次定期清理最多清理的数据项数量，0 表示不限制
+  gcMaxTime            time.Duration  // 每次定期清理的最长耗时，0 表示不限制
+  negativeTTL          time.Duration  // SetNegative 的默认生存时间，大于 0 时缓存加载返回的 ErrNotFound
+
+  keys                 KeyMutex  // LockKey 使用的按键加锁的互斥锁
+  loadLatency          latencies  // 最近的计算耗时
+  jitterMu             sync.Mutex  // 保护非并发安全的 jitterRand
+  jitterRand           *rand.Rand
+  dryRunMu             sync.Mutex
+  dryRunLog            []Eviction
+  nsMu                 sync.Mutex
+  namespaces           map[string]*Namespace
+  quotas               atomic.Pointer[map[string]*nsQuota]  // 有配额的命名空间，写时复制
+  disk                 atomic.Pointer[DiskTier]  // 磁盘层，为 nil 时被淘汰的数据项直接丢弃
+  lockGlobal           atomic.Pointer[lockHist]  // lockAll 的等待时间，为 nil 时不统计锁等待时间
+  autoSaveMu           sync.Mutex
+  autoSaveStop         chan struct{}  // 关闭时停止自动保存
+  autoSaveDone         chan struct{}  // 自动保存的 goroutine 退出后关闭
+  subMu                sync.RWMutex
+  subs                 map[<-chan Event]*subscription
+  eventMu              sync.Mutex  // 保护 events 和 dispatching
+  events               []queuedEvent  // 等待发送给订阅者的事件
+  dispatching          bool  // 是否有 goroutine 正在发送事件
 }
 
-func (c *Cache) gcLoop() {
-  ticker := time.NewTicker(c.gcInterval)
-  for {
-    select {
-    case <-ticker.C:
-      c.DeleteExpired()
-    case <-c.stopGc:
-      ticker.Stop()
-      return
-    }
+//数据项被移除的原因
+type EvictionReason int
+
+const (
+  //数据项已过期
+  ReasonExpired EvictionReason = iota
+  //超出容量被淘汰
+  ReasonEvicted
+  //被主动删除
+  ReasonDeleted
+  //被新值覆盖
+  ReasonReplaced
+  //缓存被清空
+  ReasonFlushed
+)
+
+func (r EvictionReason) String() string {
+  switch r {
+  case ReasonExpired:
+    return "expired"
+  case ReasonEvicted:
+    return "evicted"
+  case ReasonDeleted:
+    return "deleted"
+  case ReasonReplaced:
+    return "replaced"
+  case ReasonFlushed:
+    return "flushed"
+  }
+  return "unknown"
+}
+
+//一条淘汰记录
+type Eviction struct {
+  Key string
+  Reason EvictionReason
+}
+
+//演练模式最多保留的淘汰记录数
+const maxDryRunLog = 1024
+
+//等待触发 OnEvicted 和 OnRemoved 回调的数据项
+type evictedItem struct {
+  key    string
+  value  interface{}
+  reason EvictionReason
+}
+
+//设置数据项被删除、过期清理、淘汰或清空时调用的回调，回调在锁外执行，nil 表示取消
+func (c *Cache) OnEvicted(f func(key string, value interface{})) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.onEvicted = f
+}
+
+//设置数据项被移除时调用的回调，reason 为移除的原因，被新值覆盖时同样会调用。
+//回调在锁外执行，nil 表示取消
+func (c *Cache) OnRemoved(f func(key string, value interface{}, reason EvictionReason)) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.onRemoved = f
+}
+
+//事件类型对应的移除原因
+func reasonOf(t EventType) EvictionReason {
+  switch t {
+  case EventExpire:
+    return ReasonExpired
+  case EventEvict:
+    return ReasonEvicted
+  case EventFlush:
+    return ReasonFlushed
+  }
+  return ReasonDeleted
+}
+
+//记下被移除的数据项，在 unlock 时触发回调，需要持有写锁
+func (s *shard) notify(k string, item Item, reason EvictionReason) {
+  if s.c.onEvicted != nil || s.c.onRemoved != nil {
+    s.evicted = append(s.evicted, evictedItem{k, item.value(), reason})
   }
 }
 
-func (c *Cache) delete(k string) {
-  delete(c.Items, k)
+//删除数据项，记录事件并准备 OnEvicted 回调，需要持有写锁
+func (s *shard) remove(k string, t EventType) {
+  v, found := s.delete(k)
+  if !found {
+    return
+  }
+  s.c.record(t, k, v)
+  s.c.count(t)
+  s.notify(k, v, reasonOf(t))
+  if t == EventEvict {
+    s.spill(k, v)
+  }
+}
+
+func (s *shard) delete(k string) (Item, bool) {
+  s.unspill(k)
+  v, found := s.items[k]
+  if found {
+    atomic.AddInt64(&s.c.cost, -v.Cost)
+    atomic.AddInt64(&s.c.entries, -1)
+    delete(s.items, k)
+    s.publish(k, nil)
+    if !v.Pinned {
+      s.evictable[v.Priority.index()]--
+    }
+    if q := s.c.quotaOf(k); q != nil {
+      q.removed(k, v.Cost)
+    }
+    s.untouch(k)
+    s.schedule(k, 0)
+    s.untag(k)
+  }
+  return v, found
 }
 
 func (c *Cache) DeleteExpired() {
-  now := time.Now().UnixNano
-  c.mu.Lock()
-  defer c.mu.Unlock()
+  c.deleteExpired()
+}
 
-  for k, v := range c.items {
-    if v.Expiration > 0 && now > v.Expiration {
-      c.delete(k)
+//返回清理的数量
+func (c *Cache) deleteExpired() int {
+  start := time.Now()
+  now := c.now()
+  removed := 0
+  var old []map[string]Item
+  //只处理过期堆顶已到期的数据项，每批之后释放锁让其他操作进入
+  for _, s := range c.shards {
+    swept := 0
+    for more := true; more; {
+      var n int
+      s.mu.Lock()
+      n, more = s.sweep(now, sweepBatch)
+      swept += n
+      if !more && c.reclaim {
+        if m := s.shrink(swept); m != nil {
+          old = append(old, m)
+        }
+      }
+      s.unlock()
     }
+    removed += swept
+  }
+  if d := c.disk.Load(); d != nil {
+    removed += d.deleteExpired(now)
   }
+  atomic.StoreInt64(&c.sweepDuration, int64(time.Since(start)))
+  c.reclaimMaps(old, removed)
+  return removed
 }
 
-func (c *Cache) Set(k string, v interface{}, d time.Duration) {
-  var e int64
+//设置每次定期过期清理的预算，最多清理 maxItems 个数据项或耗时 maxTime，剩余的在下一次从停下的分片继续。
+//两者均为 0 表示每次清理全部过期的数据项，DeleteExpired 不受影响
+func (c *Cache) SetGCBudget(maxItems int, maxTime time.Duration) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.gcMaxItems = maxItems
+  c.gcMaxTime = maxTime
+}
+
+//定期过期清理，未设置预算时与 DeleteExpired 相同
+func (c *Cache) gcSweep() {
+  c.shards[0].mu.RLock()
+  maxItems, maxTime := c.gcMaxItems, c.gcMaxTime
+  c.shards[0].mu.RUnlock()
+  var removed int
+  start := time.Now()
+  if maxItems <= 0 && maxTime <= 0 {
+    removed = c.deleteExpired()
+  } else {
+    removed = c.sweepBudget(c.now(), start, maxItems, maxTime)
+    atomic.StoreInt64(&c.sweepDuration, int64(time.Since(start)))
+    c.releaseMemory(removed)
+  }
+  c.logSweep(removed, time.Since(start))
+}
+
+//从 gcCursor 指向的分片开始清理，预算用完时记下当前分片，返回清理的数量
+func (c *Cache) sweepBudget(now int64, start time.Time, maxItems int, maxTime time.Duration) int {
+  removed := 0
+  first := int(atomic.LoadInt32(&c.gcCursor))
+  for i := range c.shards {
+    j := (first + i) % len(c.shards)
+    s := c.shards[j]
+    for more := true; more; {
+      limit := sweepBatch
+      if maxItems > 0 {
+        limit = min(limit, maxItems - removed)
+      }
+      //至少清理一批，避免预算过小时一直没有进展
+      if limit <= 0 || maxTime > 0 && removed > 0 && time.Since(start) >= maxTime {
+        atomic.StoreInt32(&c.gcCursor, int32(j))
+        return removed
+      }
+      var n int
+      s.mu.Lock()
+      n, more = s.sweep(now, limit)
+      s.unlock()
+      removed += n
+    }
+  }
+  return removed
+}
+
+//设置数据项，选项为 WithTTL、WithTags、WithPriority、WithPinned、WithNoCopy 和 WithCost，
+//不带选项时使用默认的过期时间。缓存关闭或冻结后的写入，以及值超过 WithMaxItemBytes 的上限时，
+//数据项都不会保存且不返回错误，需要知道是否写入成功时使用 SetCtx、SetWithCost、Add 或 Replace
+func (c *Cache) Set(k string, v interface{}, opts ...ItemOption) {
+  o := itemConfig{ttl: DefaultExpiration, cost: -1}
+  for _, opt := range opts {
+    opt(&o)
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  s.setWith(k, v, o)
+}
+
+//设置带成本的数据项，成本超过上限时返回错误
+func (c *Cache) SetWithCost(k string, v interface{}, d time.Duration, cost int64) error {
+  if cost < 0 {
+    return fmt.Errorf("Cost of item %s can't be negative.", k)
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if err := c.writable(); err != nil {
+    return err
+  }
+  if c.maxCost > 0 && cost > c.maxCost {
+    return fmt.Errorf("Cost of item %s exceeds max cost %d.", k, c.maxCost)
+  }
+  return s.setWithCost(k, v, d, cost)
+}
+
+//成本按压缩后的值计算
+func (s *shard) set(k string, v interface{}, d time.Duration) error {
+  item := s.newItem(v, d, 0)
+  item.Cost = s.c.costOf(k, item.Object)
+  return s.setItem(k, item)
+}
+
+func (s *shard) setWithCost(k string, v interface{}, d time.Duration, cost int64) error {
+  return s.setItem(k, s.newItem(v, d, cost))
+}
+
+//按生存时间 d 创建数据项，d 的含义与 Set 相同，需要持有分片的锁
+func (s *shard) newItem(v interface{}, d time.Duration, cost int64) Item {
+  var e, sliding int64
   if d == DefaultExpiration {
-    d = c.defaultExpiration
+    d = s.c.defaultExpiration
   }
   if d > 0 {
-    e = time.Now().Add(d).UnixNano()
+    e = s.c.clock.Now().Add(s.c.jitter(d)).UnixNano()
+    if s.c.sliding {
+      sliding = int64(d)
+    }
+  }
+  v, z := s.c.compress(v)
+  return Item {
+    Object: v,
+    Expiration: e,
+    Cost: cost,
+    Sliding: sliding,
+    Compressed: z,
+  }
+}
+
+//数据项超过大小上限时返回 *ItemTooLargeError，未通过准入时不写入也不返回错误
+func (s *shard) setItem(k string, item Item) error {
+  if err := s.checkSize(k, &item); err != nil {
+    return err
   }
-  c.mu.Lock()
-  defer c.mu.Unlock()
-  c.item[k] = Item {
+  if _, found := s.items[k]; !found && !s.admit(k, item) {
+    return nil
+  }
+  if old, found := s.delete(k); found {
+    s.notify(k, old, ReasonReplaced)
+  }
+  s.insert(k, item)
+  s.c.record(EventSet, k, item)
+  atomic.AddUint64(&s.c.stats.sets, 1)
+  s.evictOverflow(k)
+  return nil
+}
+
+//放入数据项并更新计数，需要持有写锁
+func (s *shard) insert(k string, item Item) {
+  item.Version = atomic.AddUint64(&s.c.version, 1)
+  if item.Created == 0 {
+    item.Created = s.c.now()
+  }
+  item.access = &accessInfo{}
+  s.items[k] = item
+  s.publish(k, &item)
+  if !item.Pinned {
+    s.evictable[item.Priority.index()]++
+  }
+  if q := s.c.quotaOf(k); q != nil {
+    q.added(k, item.Cost)
+  }
+  atomic.AddInt64(&s.c.cost, item.Cost)
+  atomic.AddInt64(&s.c.entries, 1)
+  s.touch(k)
+  s.schedule(k, item.Expiration)
+}
+
+//设置数据项，过期时间由 expireAt 根据数据项计算得出，返回零值时间表示永不过期
+func (c *Cache) SetFunc(k string, v interface{}, expireAt func(v interface{}) time.Time) {
+  var e int64
+  if t := expireAt(v); !t.IsZero() {
+    e = t.UnixNano()
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  v, z := c.compress(v)
+  s.setItem(k, Item {
     Object: v,
     Expiration: e,
+    Cost: c.costOf(k, v),
+    Compressed: z,
+  })
+}
+
+//超出上限时先在本分片淘汰，仍然超出则记下剩余部分留给 unlock 在其他分片处理
+func (s *shard) evictOverflow(keep string) {
+  b := s.c.budget()
+  if s.c.overLimit(b) && s.evict(keep, b) {
+    s.overflow = b
   }
 }
 
-func (c *Cache) get(k string) (interface{}, bool) {
-  item, found := c.items[k]
+//在本分片内淘汰数据项直到不超过上限，keep 为不参与淘汰的键，返回淘汰后是否仍然超出。
+//LRU 模式下按最久未使用的顺序淘汰，否则优先淘汰已过期的数据项。同等条件下先淘汰低优先级的数据项，
+//按淘汰顺序只遍历一次
+func (s *shard) evict(keep string, b *budget) bool {
+  c := s.c
+  over := func() bool {
+    if c.dryRun {
+      return c.overLimit(b)
+    }
+    return c.overLimit(c.budget())
+  }
+  if !over() {
+    return false
+  }
+  if !s.ordered() {
+    s.evictExpired(keep, b, over)
+  }
+  //各优先级剩余可以淘汰的数量，固定的数据项和 keep 不参与
+  left := s.evictable
+  if v, found := s.items[keep]; found && !v.Pinned {
+    left[v.Priority.index()]--
+  }
+  lowest := func() int {
+    for i, n := range left {
+      if n > 0 {
+        return i
+      }
+    }
+    return priorities
+  }
+  evictOne := func(k string, v Item) {
+    b.cost -= v.Cost
+    b.count--
+    left[v.Priority.index()]--
+    if c.expired(v) {
+      s.evictItem(k, ReasonExpired)
+    } else {
+      if s.arc != nil && !c.dryRun {
+        s.arc.ghost(k)
+      }
+      s.evictItem(k, ReasonEvicted)
+    }
+  }
+  //遍历中遇到的高于当前最低优先级的数据项按顺序暂存，低优先级的淘汰完后先淘汰暂存的
+  var skipped [priorities][]string
+  drain := func() {
+    for over() {
+      l := lowest()
+      if l == priorities || len(skipped[l]) == 0 {
+        return
+      }
+      k := skipped[l][0]
+      skipped[l] = skipped[l][1:]
+      evictOne(k, s.items[k])
+    }
+  }
+  s.victims(func(k string, v Item) bool {
+    if !over() {
+      return false
+    }
+    //演练模式下已过期的数据项已经在 evictExpired 中记录过
+    if k == keep || v.Pinned || (c.dryRun && !s.ordered() && c.expired(v)) {
+      return true
+    }
+    if i := v.Priority.index(); i > lowest() {
+      skipped[i] = append(skipped[i], k)
+      return true
+    }
+    evictOne(k, v)
+    drain()
+    return true
+  })
+  //计数与遍历的结果不一致时，按优先级从低到高淘汰剩下暂存的数据项
+  for i := range skipped {
+    for _, k := range skipped[i] {
+      if !over() {
+        return false
+      }
+      evictOne(k, s.items[k])
+    }
+  }
+  return over()
+}
+
+//从过期堆的堆顶开始淘汰已过期的数据项，直到不再超出上限，不遍历全部数据项。需要持有写锁
+func (s *shard) evictExpired(keep string, b *budget, over func() bool) {
+  c := s.c
+  if c.dryRun {
+    //演练模式下数据项不会被删除，无法从堆顶逐个取出
+    for k, v := range s.items {
+      if k != keep && c.expired(v) {
+        b.cost -= v.Cost
+        b.count--
+        s.evictItem(k, ReasonExpired)
+      }
+    }
+    return
+  }
+  //堆中的时间包含 staleFor
+  stale, now := int64(c.staleFor), c.now()
+  for over() && len(s.expHeap) > 0 && s.expHeap[0].exp-stale < now {
+    k := s.expHeap[0].key
+    if k == keep {
+      return
+    }
+    v, found := s.items[k]
+    if !found {
+      s.schedule(k, 0)
+      continue
+    }
+    b.cost -= v.Cost
+    b.count--
+    s.evictItem(k, ReasonExpired)
+  }
+}
+
+//判断成本总和或数据项数量是否超出上限
+func (c *Cache) overLimit(b *budget) bool {
+  return (c.maxCost > 0 && b.cost > c.maxCost) || (c.maxEntries > 0 && b.count > c.maxEntries)
+}
+
+//淘汰一个数据项，演练模式下只记录不删除
+func (s *shard) evictItem(k string, reason EvictionReason) {
+  if !s.c.dryRun {
+    s.remove(k, EventEvict)
+    return
+  }
+  e := Eviction{Key: k, Reason: reason}
+  s.c.dryRunMu.Lock()
+  if len(s.c.dryRunLog) >= maxDryRunLog {
+    s.c.dryRunLog = s.c.dryRunLog[1:]
+  }
+  s.c.dryRunLog = append(s.c.dryRunLog, e)
+  s.c.dryRunMu.Unlock()
+  s.dryRunPending = append(s.dryRunPending, e)
+}
+
+//开启或关闭淘汰演练模式，f 在每次本应发生的淘汰时被调用，可以为 nil
+func (c *Cache) SetEvictionDryRun(on bool, f func(key string, reason EvictionReason)) {
+  c.lockAll()
+  c.dryRun = on
+  c.onDryRun = f
+  c.dryRunMu.Lock()
+  c.dryRunLog = nil
+  c.dryRunMu.Unlock()
+  c.unlockAll()
+  if !on {
+    c.evictOthers(nil, c.budget())
+  }
+}
+
+//返回演练模式下记录的淘汰决定
+func (c *Cache) DryRunEvictions() []Eviction {
+  c.dryRunMu.Lock()
+  defer c.dryRunMu.Unlock()
+  log := make([]Eviction, len(c.dryRunLog))
+  copy(log, c.dryRunLog)
+  return log
+}
+
+//设置成本上限，0 表示不限制，超出部分会立即被淘汰
+func (c *Cache) SetMaxCost(n int64) {
+  c.lockAll()
+  c.maxCost = n
+  c.unlockAll()
+  c.evictOthers(nil, c.budget())
+}
+
+//返回当前数据项的成本总和
+func (c *Cache) Cost() int64 {
+  return atomic.LoadInt64(&c.cost)
+}
+
+func (s *shard) get(k string) (interface{}, bool) {
+  v, negative, found := s.lookup(k)
+  return v, found && !negative
+}
+
+//第二个返回值表示找到的是 SetNegative 缓存的“不存在”结果
+func (s *shard) lookup(k string) (interface{}, bool, bool) {
+  item, found := s.find(k)
   if !found {
-    return nil, false
+    return nil, false, false
   }
-  if item.Expired() {
-    return nil, false
+  v, err := s.c.read(item)
+  if err != nil {
+    s.c.corrupt(k, item, err)
+    return nil, false, false
   }
-  return item.Object, true
+  return v, item.Negative, true
 }
 
-func (c *Cache) Get(k string) (interface{}, bool) {
-  c.mu.RLock()
-  item, found := c.items[k]
+//查找未过期的数据项并记录访问，包括 SetNegative 缓存的结果。只读取一次时钟，需要持有读锁
+func (s *shard) find(k string) (Item, bool) {
+  s.access(k)
+  item, found := s.items[k]
   if !found {
-    c.mu.RUnlock()
-    return nil, false
+    return Item{}, false
   }
-  if item.Expired() {
-    return nil, false
+  now := s.c.now()
+  if item.Expiration != 0 && now > item.Expiration {
+    return Item{}, false
+  }
+  s.touch(k)
+  s.accessedAt(k, item, now)
+  return item, true
+}
+
+//读锁内只查找一次 map，复制值和解压在锁外进行
+func (c *Cache) Get(k string) (interface{}, bool) {
+  s := c.shard(k)
+  if v, found, ok := s.getLockFree(k); ok {
+    if !found {
+      v, found = c.fromDisk(k)
+    }
+    c.hit(found)
+    return v, found
+  }
+  s.mu.RLock()
+  item, found := s.find(k)
+  s.mu.RUnlock()
+  var v interface{}
+  if found && !item.Negative {
+    var err error
+    if v, err = c.read(item); err != nil {
+      c.corrupt(k, item, err)
+      v, found = nil, false
+    }
+  } else {
+    v, found = c.fromDisk(k)
+  }
+  c.hit(found)
+  if found && item.Sliding > 0 {
+    s.slide(k)
   }
-  c.mu.RUnlock()
-  return item.Object, true
+  s.promoteRO()
+  return v, found
+}
+
+//批量获取数据项，返回找到的键值
+func (c *Cache) GetMulti(keys ...string) map[string]interface{} {
+  found := make(map[string]interface{}, len(keys))
+  for s, keys := range c.byShard(keys) {
+    var slide []string
+    s.mu.RLock()
+    for _, k := range keys {
+      v, ok := s.get(k)
+      if ok {
+        found[k] = v
+        if s.items[k].Sliding > 0 {
+          slide = append(slide, k)
+        }
+      }
+      c.hit(ok)
+    }
+    s.mu.RUnlock()
+    s.slide(slide...)
+  }
+  return found
+}
+
+//批量获取数据项，并在短暂的写锁中顺带删除遇到的过期数据项
+func (c *Cache) GetMultiAndClean(keys ...string) map[string]interface{} {
+  found := make(map[string]interface{}, len(keys))
+  for s, keys := range c.byShard(keys) {
+    var expired []string
+    s.mu.RLock()
+    for _, k := range keys {
+      item, ok := s.items[k]
+      if !ok {
+        c.hit(false)
+        continue
+      }
+      if c.expired(item) {
+        c.hit(false)
+        expired = append(expired, k)
+        continue
+      }
+      if item.Negative {
+        c.hit(false)
+        continue
+      }
+      v, err := c.read(item)
+      if err != nil {
+        c.corrupt(k, item, err)
+        c.hit(false)
+        continue
+      }
+      c.hit(true)
+      found[k] = v
+      s.touch(k)
+      s.accessed(k, item)
+    }
+    s.mu.RUnlock()
+    if len(expired) == 0 {
+      continue
+    }
+    s.mu.Lock()
+    for _, k := range expired {
+      //加写锁前可能已被重新设置，需要再次确认
+      if item, ok := s.items[k]; ok && c.expired(item) {
+        s.remove(k, EventExpire)
+      }
+    }
+    s.unlock()
+  }
+  return found
+}
+
+//批量设置数据项，同一分片的数据项只加一次锁
+func (c *Cache) SetMulti(items map[string]interface{}, d time.Duration) {
+  keys := make([]string, 0, len(items))
+  for k := range items {
+    keys = append(keys, k)
+  }
+  for s, keys := range c.byShard(keys) {
+    s.mu.Lock()
+    if c.writable() != nil {
+      s.unlock()
+      return
+    }
+    for _, k := range keys {
+      s.set(k, items[k], d)
+    }
+    s.unlock()
+  }
+}
+
+//批量删除数据项，同一分片的数据项只加一次锁
+func (c *Cache) DeleteMulti(keys ...string) {
+  for s, keys := range c.byShard(keys) {
+    s.mu.Lock()
+    if c.frozen {
+      s.unlock()
+      return
+    }
+    for _, k := range keys {
+      s.remove(k, EventDelete)
+    }
+    s.unlock()
+  }
+}
+
+//删除所有以 prefix 开头的数据项，返回删除的数量
+func (c *Cache) DeleteByPrefix(prefix string) int {
+  return c.deletePrefix(prefix, EventDelete)
+}
+
+//删除所有键匹配 glob 的数据项，返回删除的数量。glob 的语法与 path.Match 相同，格式错误时不删除任何数据项
+func (c *Cache) DeleteByPattern(glob string) int {
+  if _, err := path.Match(glob, ""); err != nil {
+    return 0
+  }
+  return c.deleteWhere(func(k string) bool {
+    ok, _ := path.Match(glob, k)
+    return ok
+  }, EventDelete)
+}
+
+//逐个分片删除 f 返回 true 的数据项，返回删除的数量，移除的原因为 ReasonDeleted。
+//f 在持有分片的写锁时调用，不能在 f 中读写缓存
+func (c *Cache) FlushWhere(f func(k string, v interface{}) bool) int {
+  removed := 0
+  for _, s := range c.shards {
+    s.mu.Lock()
+    if c.frozen {
+      s.unlock()
+      return removed
+    }
+    for k, item := range s.items {
+      v, err := item.decode()
+      if err != nil {
+        c.corrupt(k, item, err)
+        continue
+      }
+      if f(k, v) {
+        s.remove(k, EventDelete)
+        removed++
+      }
+    }
+    s.unlock()
+  }
+  c.releaseMemory(removed)
+  return removed
+}
+
+//删除创建时间早于 age 之前的数据项，不论是否过期，包括磁盘层中的数据项，返回删除的数量。
+//Load 读取的数据项保留保存时的创建时间，逐个分片加锁
+func (c *Cache) DeleteOlderThan(age time.Duration) int {
+  cutoff := c.now() - int64(age)
+  removed := 0
+  for _, s := range c.shards {
+    s.mu.Lock()
+    if c.frozen {
+      s.unlock()
+      return removed
+    }
+    for k, item := range s.items {
+      if item.Created < cutoff {
+        s.remove(k, EventDelete)
+        removed++
+      }
+    }
+    s.unlock()
+  }
+  if d := c.disk.Load(); d != nil {
+    removed += d.deleteIf(func(e diskEntry) bool { return e.created < cutoff })
+  }
+  c.releaseMemory(removed)
+  return removed
+}
+
+//锁住全部分片删除键满足 match 的数据项，返回删除的数量
+func (c *Cache) deleteWhere(match func(k string) bool, t EventType) int {
+  removed := 0
+  c.lockAll()
+  for _, s := range c.shards {
+    if c.frozen {
+      break
+    }
+    for k := range s.items {
+      if match(k) {
+        s.remove(k, t)
+        removed++
+      }
+    }
+  }
+  c.unlockAll()
+  c.releaseMemory(removed)
+  return removed
 }
 
 func (c *Cache) Add(k string, v interface{}, d time.Duration) error {
-  c.mu.Lock()
-  _, found := c.get(k)
+  s := c.shard(k)
+  s.mu.Lock()
+  if err := c.writable(); err != nil {
+    s.unlock()
+    return err
+  }
+  _, found := s.get(k)
   if found {
-    c.mu.Unlock()
+    s.unlock()
     return fmt.Errorf("Item %s already exists.", k)
   }
-  c.set(k, v, d)
-  c.mu.Unlock()
-  return nil
+  err := s.set(k, v, d)
+  s.unlock()
+  return err
 }
 
 func (c *Cache) Replace(k string, v interface{}, d time.Duration) error {
-  c.mu.Lock()
-  _, found := c.get(k)
+  s := c.shard(k)
+  s.mu.Lock()
+  if err := c.writable(); err != nil {
+    s.unlock()
+    return err
+  }
+  _, found := s.get(k)
   if !found {
-    c.mu.Unlock()
+    s.unlock()
     return fmt.Errorf("Item %s doesn't exist.", k)
   }
-  c.set(k, v, d)
-  c.mu.Unlock()
-  return nil
+  err := s.set(k, v, d)
+  s.unlock()
+  return err
+}
+
+//设置数据项并返回之前未过期的值，第二个返回值表示之前是否存在，两步在同一次加锁中完成。
+//缓存关闭或冻结时不设置，返回 nil 和 false
+func (c *Cache) Swap(k string, v interface{}, d time.Duration) (interface{}, bool) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return nil, false
+  }
+  old, found := s.get(k)
+  s.set(k, v, d)
+  return old, found
+}
+
+//数据项存在时才设置，返回被替换的值，第二个返回值表示是否设置。数据项不存在时才设置使用 GetOrSet
+func (c *Cache) SetIfPresent(k string, v interface{}, d time.Duration) (interface{}, bool) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return nil, false
+  }
+  old, found := s.get(k)
+  if !found {
+    return nil, false
+  }
+  s.set(k, v, d)
+  return old, true
 }
 
 func (c *Cache) Delete(k string) {
-  c.mu.Lock()
-  c.delete(k)
-  c.mu.Unlock()
+  s := c.shard(k)
+  s.mu.Lock()
+  if !c.frozen {
+    s.remove(k, EventDelete)
+  }
+  s.unlock()
+}
+
+//返回并删除数据项，与其他调用者之间只有一个能取到。SetNegative 缓存的结果视为未命中，不会被删除
+func (c *Cache) GetAndDelete(k string) (interface{}, bool) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  item, found := s.items[k]
+  if !found || c.expired(item) || item.Negative || c.frozen {
+    c.hit(false)
+    return nil, false
+  }
+  v, err := item.decode()
+  s.remove(k, EventDelete)
+  if err != nil {
+    c.corrupt(k, item, err)
+    c.hit(false)
+    return nil, false
+  }
+  c.hit(true)
+  return v, true
+}
+
+//随机取出并删除一个未过期的数据项，跳过 SetNegative 缓存的结果，缓存为空时返回 false
+func (c *Cache) PopRandom() (string, interface{}, bool) {
+  n := len(c.shards)
+  start := rand.Intn(n)
+  for i := 0; i < n; i++ {
+    s := c.shards[(start+i)%n]
+    s.mu.Lock()
+    if c.frozen {
+      s.unlock()
+      return "", nil, false
+    }
+    //map 的遍历顺序是随机的
+    for k, item := range s.items {
+      if c.expired(item) || item.Negative {
+        continue
+      }
+      v, err := item.decode()
+      s.remove(k, EventDelete)
+      if err != nil {
+        c.corrupt(k, item, err)
+        continue
+      }
+      s.unlock()
+      return k, v, true
+    }
+    s.unlock()
+  }
+  return "", nil, false
 }
 
 // 将数据项写入 io.Writer 中
-func (c *Cache) Save(w io.Writer) (err error) {
-  enc := gob.NewEncoder(w)
-  defer func() {
-    if x := recover(); x != nil {
-      err = fmt.Errorf("Error registering item types with Gob library!")
-    }
-  }()
-  c.mu.RLock()
-  defer c.mu.RUnlock()
-  for _, v := range c.items {
-    gob.Register(v.Object)
-  }
-  err = enc.Encode(&c.items)
-  //return
+func (c *Cache) Save(w io.Writer) error {
+  return c.SaveWith(w, GobSerializer{})
+}
+
+//使用指定的序列化方式将未过期的数据项写入 io.Writer 中。
+//s 实现了 ChunkSerializer 时逐个分片复制并按块编码，否则先复制全部数据项再编码
+func (c *Cache) SaveWith(w io.Writer, s Serializer) error {
+  _, err := c.save(w, s)
   return err
 }
 
+//返回写入的数据项数量
+func (c *Cache) save(w io.Writer, s Serializer) (int, error) {
+  //在副本上编码，编码期间不持有锁，写入者也不会与编码竞争
+  cs, ok := s.(ChunkSerializer)
+  if !ok {
+    items := c.snapshot()
+    c.portable(s, items)
+    return len(items), s.Encode(w, items)
+  }
+  enc := cs.NewEncoder(w)
+  if c.sortedIteration() {
+    return c.saveSorted(s, enc)
+  }
+  chunk := map[string]Item{}
+  n, written := 0, false
+  for _, sh := range c.shards {
+    c.copyShard(sh, chunk)
+    if len(chunk) >= saveChunk {
+      c.portable(s, chunk)
+      if err := enc.Encode(chunk); err != nil {
+        return n, err
+      }
+      n += len(chunk)
+      chunk, written = map[string]Item{}, true
+    }
+  }
+  //空缓存也要写入一个块，Load 才能区分空缓存和空文件
+  if len(chunk) > 0 || !written {
+    c.portable(s, chunk)
+    if err := enc.Encode(chunk); err != nil {
+      return n, err
+    }
+    n += len(chunk)
+  }
+  return n, nil
+}
+
+//Save 每块至少包含的数据项数量，达到后立即编码，不再继续累积
+const saveChunk = 4096
+
+//返回未过期数据项的副本，逐个分片复制
+func (c *Cache) snapshot() map[string]Item {
+  items := make(map[string]Item, c.Count())
+  for _, s := range c.shards {
+    c.copyShard(s, items)
+  }
+  return items
+}
+
+//在分片的读锁内把未过期的数据项复制到 items 中
+func (c *Cache) copyShard(s *shard, items map[string]Item) {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  for k, v := range s.items {
+    if !c.expired(v) && !transient(v) {
+      items[k] = v
+    }
+  }
+}
+
 //从 io.Reader 中读取数据项
 func (c *Cache) Load(r io.Reader) error {
-  dec := gob.NewDecoder()
-  items := map[string]Item{}
-  err := dec.Decode(&items)
-  if err == nil {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    for k, v := range items {
-      ov, found := c.items[k]
-      if !found || ov.Expired() {
-        c.items[k] = v
+  return c.LoadWith(r, GobSerializer{})
+}
+
+//使用指定的序列化方式从 io.Reader 中读取数据项
+func (c *Cache) LoadWith(r io.Reader, sr Serializer) error {
+  items, err := sr.Decode(r)
+  if err != nil {
+    return err
+  }
+  return c.load(items, nil)
+}
+
+//放入读取的数据项，与未过期的数据项冲突时由 merge 决定，merge 为 nil 时使用 SetMergePolicy 设置的合并方式。
+//成本按当前的设置重新计算，超过 WithMaxItemBytes 上限的数据项被跳过
+func (c *Cache) load(items map[string]Item, merge MergeFunc) error {
+  keys := make([]string, 0, len(items))
+  for k := range items {
+    keys = append(keys, k)
+  }
+  for s, keys := range c.byShard(keys) {
+    s.mu.Lock()
+    if err := c.writable(); err != nil {
+      s.unlock()
+      return err
+    }
+    f := merge
+    if f == nil {
+      f = c.merge
+    }
+    for _, k := range keys {
+      item, err := s.prepare(k, items[k])
+      if err != nil {
+        continue
+      }
+      ov, found := s.items[k]
+      if found && !c.expired(ov) {
+        if f == nil || !f(k, ov, item) {
+          continue
+        }
+        s.notify(k, ov, ReasonReplaced)
       }
+      s.delete(k)
+      s.insert(k, item)
+      c.record(EventSet, k, item)
     }
+    s.evictOverflow("")
+    s.unlock()
+  }
+  return nil
+}
+
+//读入的数据项按当前的设置压缩并重新计算成本，与 Set 一样超过 WithMaxItemBytes 的上限时返回错误，
+//需要持有写锁
+func (s *shard) prepare(k string, item Item) (Item, error) {
+  if item.Negative {
+    return item, nil
+  }
+  if item.Compressed == 0 {
+    item.Object, item.Compressed = s.c.compress(item.Object)
   }
-  return v
+  item.Cost = s.c.costOf(k, item.Object)
+  return item, s.checkSize(k, &item)
 }
 
-//保存数据项到文件
+//保存数据项到文件，文件带有格式版本和校验和
 func (c *Cache) SaveToFile(file string) error {
-  f, err = os.Create(file)
+  return c.SaveToFileWith(file, GobSerializer{})
+}
+
+//使用指定的序列化方式保存数据项到文件，只支持 GobSerializer、JSONSerializer 和用 RegisterDumpSerializer 注册的序列化方式
+func (c *Cache) SaveToFileWith(file string, s Serializer) error {
+  f, err := os.Create(file)
   if err != nil {
     return err
   }
-  if err = c.Save(f); err != nil {
+  if err = c.writeDump(f, s); err != nil {
     f.Close()
     return err
   }
   return f.Close()
 }
 
-//从文件中加载缓存数据项
+//从文件中加载缓存数据项，序列化方式由文件头决定。文件损坏或版本不支持时返回 *DumpError，
+//没有文件头的旧格式文件按 gob 读取
 func (c *Cache) LoadFile(file string) error {
   f, err := os.Open(file)
   if err != nil {
     return err
   }
-  if err = c.Load(f); err != nil {
+  if err = c.readDump(f, file); err != nil {
     f.Close()
     return err
   }
@@ -201,33 +1186,251 @@ func (c *Cache) LoadFile(file string) error {
 
 //返回缓存数据项的数量
 func (c *Cache) Count() int {
-  c.mu.RLock()
-  defer c.mu.RUnLock()
-  return len(c.items)
+  return int(atomic.LoadInt64(&c.entries))
+}
+
+//返回所有未过期数据项的键
+func (c *Cache) Keys() []string {
+  keys := make([]string, 0, c.Count())
+  c.scanKeys(func(k string) {
+    keys = append(keys, k)
+  })
+  return keys
+}
+
+//返回以 prefix 开头的未过期数据项的数量，不复制键
+func (c *Cache) CountPrefix(prefix string) int {
+  n := 0
+  c.scanKeys(func(k string) {
+    if strings.HasPrefix(k, prefix) {
+      n++
+    }
+  })
+  return n
 }
 
-//清空缓存
+//返回键匹配 glob 的未过期数据项的键，glob 的语法与 path.Match 相同，格式错误时返回 nil
+func (c *Cache) KeysMatching(glob string) []string {
+  if _, err := path.Match(glob, ""); err != nil {
+    return nil
+  }
+  var keys []string
+  c.scanKeys(func(k string) {
+    if ok, _ := path.Match(glob, k); ok {
+      keys = append(keys, k)
+    }
+  })
+  return keys
+}
+
+//逐个分片在读锁下遍历未过期数据项的键，每次只锁一个分片
+func (c *Cache) scanKeys(f func(k string)) {
+  for _, s := range c.shards {
+    s.mu.RLock()
+    for k, v := range s.items {
+      if !c.expired(v) {
+        f(k)
+      }
+    }
+    s.mu.RUnlock()
+  }
+}
+
+//返回所有未过期数据项的副本，逐个分片复制，压缩的值会被解压
+func (c *Cache) Items() map[string]Item {
+  items := make(map[string]Item, c.Count())
+  for _, s := range c.shards {
+    c.copyShard(s, items)
+  }
+  for k, v := range items {
+    if v.Compressed == 0 {
+      continue
+    }
+    o, err := c.read(v)
+    if err != nil {
+      c.corrupt(k, v, err)
+      delete(items, k)
+      continue
+    }
+    v.Object, v.Compressed = o, 0
+    items[k] = v
+  }
+  return items
+}
+
+//依次对未过期的数据项调用 f，f 返回 false 时停止。
+//每个分片先复制再在锁外调用 f，f 中可以读写缓存，看到的是各分片复制时的状态
+func (c *Cache) Range(f func(k string, v interface{}) bool) {
+  type kv struct {
+    k string
+    v interface{}
+  }
+  var batch []kv
+  sorted := c.sortedIteration()
+  for i, s := range c.shards {
+    if !sorted {
+      batch = batch[:0]
+    }
+    s.mu.RLock()
+    for k, v := range s.items {
+      if c.expired(v) || v.Negative {
+        continue
+      }
+      if o, err := c.read(v); err != nil {
+        c.corrupt(k, v, err)
+      } else {
+        batch = append(batch, kv{k, o})
+      }
+    }
+    s.mu.RUnlock()
+    //按顺序遍历时先收集全部分片
+    if sorted {
+      if i < len(c.shards) - 1 {
+        continue
+      }
+      sort.Slice(batch, func(i, j int) bool { return batch[i].k < batch[j].k })
+    }
+    for _, e := range batch {
+      if !f(e.k, e.v) {
+        return
+      }
+    }
+  }
+}
+
+//清空缓存，清空期间锁住全部分片
 func (c *Cache) Flush() {
-  c.mu.Lock()
-  defer c.mu.UnLock()
-  c.items = map[string]Item{}
+  c.lockAll()
+  if c.frozen {
+    c.unlockAll()
+    return
+  }
+  removed := c.Count()
+  var old []map[string]Item
+  for _, s := range c.shards {
+    if c.onEvicted != nil || c.onRemoved != nil {
+      for k, v := range s.items {
+        s.notify(k, v, ReasonFlushed)
+      }
+    }
+    if c.reclaim {
+      old = append(old, s.items)
+    }
+    s.items = map[string]Item{}
+    s.evictable = [priorities]int{}
+    s.resetRO()
+    s.resetLRU()
+    s.resetExpHeap()
+    s.tags, s.keyTags = nil, nil
+  }
+  atomic.StoreInt64(&c.cost, 0)
+  atomic.StoreInt64(&c.entries, 0)
+  c.resetQuotas()
+  c.record(EventFlush, "", Item{})
+  c.unlockAll()
+  if d := c.disk.Load(); d != nil {
+    d.clear()
+  }
+  c.reclaimMaps(old, removed)
+}
+
+//一次删除至少 n 个数据项后调用 debug.FreeOSMemory 将内存归还给操作系统，0 表示关闭。
+//FreeOSMemory 会强制执行一次完整的 GC，在大堆上可能造成数十毫秒的延迟，
+//只适合在大量缓存被清理后偶尔使用
+func (c *Cache) SetFreeOSMemory(n int) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.freeOSMemoryAt = n
+}
+
+//在删除了 removed 个数据项后按需归还内存，需要在锁外调用
+func (c *Cache) releaseMemory(removed int) {
+  c.shards[0].mu.RLock()
+  n := c.freeOSMemoryAt
+  c.shards[0].mu.RUnlock()
+  if n > 0 && removed >= n {
+    debug.FreeOSMemory()
+  }
 }
 
-//停止过期缓存清理
+//设置生存时间的随机浮动比例，每次 Set 的生存时间在 ±fraction 范围内随机变化。
+//r 为随机数来源，传入固定种子的 *rand.Rand 可以得到可复现的结果，nil 表示使用以当前时间为种子的来源
+func (c *Cache) SetTTLJitter(fraction float64, r *rand.Rand) {
+  if r == nil {
+    r = rand.New(rand.NewSource(time.Now().UnixNano()))
+  }
+  c.lockAll()
+  defer c.unlockAll()
+  c.jitterFraction = fraction
+  c.jitterMu.Lock()
+  c.jitterRand = r
+  c.jitterMu.Unlock()
+}
+
+//按浮动比例调整生存时间，需要持有分片的锁
+func (c *Cache) jitter(d time.Duration) time.Duration {
+  if c.jitterFraction <= 0 {
+    return d
+  }
+  c.jitterMu.Lock()
+  f := c.jitterRand.Float64()
+  c.jitterMu.Unlock()
+  j := time.Duration(float64(d) * c.jitterFraction * (2*f - 1))
+  if d+j <= 0 {
+    return d
+  }
+  return d + j
+}
+
+//停止过期清理，可以重复调用
 func (c *Cache) StopGc() {
-  c.StopGc <- true
+  if c.reaper != nil {
+    c.reaper.remove(c)
+  } else if c.gc != nil {
+    c.gc.signal()
+    <-c.gc.done
+  }
+  atomic.StoreInt32(&c.gcStopped, 1)
+}
+
+//过期清理超过多少个 gcInterval 没有完成即认为不健康
+const healthySweeps = 3
+
+//检查过期清理是否仍在正常运行
+func (c *Cache) Healthy() (bool, error) {
+  if atomic.LoadInt32(&c.gcStopped) == 1 {
+    return false, fmt.Errorf("Gc of cache has been stopped.")
+  }
+  last := time.Unix(0, atomic.LoadInt64(&c.lastSweep))
+  if since := c.clock.Now().Sub(last); since > healthySweeps*c.gcInterval {
+    return false, fmt.Errorf("Gc of cache hasn't swept for %v.", since)
+  }
+  return true, nil
 }
 
-//创建一个缓存系统
+//创建一个缓存系统，需要更多配置时使用 New
 func NewCache(defaultExpiration, gcInterval time.Duration) *Cache {
+  return NewShardedCache(DefaultShards, defaultExpiration, gcInterval)
+}
+
+//创建一个指定分片数量的缓存系统，不同分片上的读写互不阻塞
+func NewShardedCache(shards int, defaultExpiration, gcInterval time.Duration) *Cache {
+  return New(WithShards(shards), WithDefaultExpiration(defaultExpiration), WithGCInterval(gcInterval))
+}
+
+func newCache(shards int, defaultExpiration, gcInterval time.Duration) *Cache {
+  if shards <= 0 {
+    shards = 1
+  }
   c := &Cache {
     defaultExpiration: defaultExpiration,
     gcInterval: gcInterval,
-    items: map[string]Item{},
-    stopGc: make(chan bool),
+    lastSweep: time.Now().UnixNano(),
+    clock: RealClock,
+  }
+  c.shards = make([]*shard, shards)
+  for i := range c.shards {
+    c.shards[i] = newShard(c)
   }
-  //启动过期清理方法
-  go c.gcLoop()
   return c
 }
-
@@ -0,0 +1,56 @@
+package cache
+
+import (
+  "errors"
+  "time"
+)
+
+//CompareAndSwap 时数据项的版本号已经改变
+var ErrVersionMismatch = errors.New("Item version doesn't match.")
+
+//获取数据项及其版本号，数据项每次被写入后版本号都会改变
+func (c *Cache) GetWithVersion(k string) (interface{}, uint64, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  item, found := s.items[k]
+  if !found || c.expired(item) || item.Negative {
+    s.mu.RUnlock()
+    c.hit(false)
+    return nil, 0, false
+  }
+  s.touch(k)
+  s.accessed(k, item)
+  s.mu.RUnlock()
+  v, err := c.read(item)
+  if err != nil {
+    c.corrupt(k, item, err)
+    c.hit(false)
+    return nil, 0, false
+  }
+  c.hit(true)
+  return v, item.Version, true
+}
+
+//数据项的版本号等于 version 时才设置为 v。
+//version 为 0 表示只在数据项不存在时设置。数据项不存在时返回 ErrNotFound，版本号不同时返回 ErrVersionMismatch
+func (c *Cache) CompareAndSwap(k string, version uint64, v interface{}, d time.Duration) error {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if err := c.writable(); err != nil {
+    return err
+  }
+  item, found := s.items[k]
+  if found && (c.expired(item) || item.Negative) {
+    found = false
+  }
+  switch {
+  case version == 0 && found:
+    return ErrVersionMismatch
+  case version != 0 && !found:
+    return ErrNotFound
+  case found && item.Version != version:
+    return ErrVersionMismatch
+  }
+  return s.set(k, v, d)
+}
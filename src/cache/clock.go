@@ -0,0 +1,120 @@
+package cache
+
+import (
+  "sync"
+  "time"
+)
+
+//时间来源，测试中可以用 FakeClock 手动推进时间
+type Clock interface {
+  Now() time.Time
+  NewTicker(d time.Duration) Ticker
+}
+
+//定时器，与 time.Ticker 相同
+type Ticker interface {
+  C() <-chan time.Time
+  Stop()
+}
+
+//使用系统时间的时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+  return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+  return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+  t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+  return t.t.C
+}
+
+func (t realTicker) Stop() {
+  t.t.Stop()
+}
+
+//只在调用 Advance 时前进的时钟，并发安全
+type FakeClock struct {
+  mu      sync.Mutex
+  now     time.Time
+  tickers []*fakeTicker
+}
+
+//创建一个从 now 开始的时钟
+func NewFakeClock(now time.Time) *FakeClock {
+  return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.now
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+  if d <= 0 {
+    panic("non-positive interval for NewTicker")
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  t := &fakeTicker{f: f, d: d, next: f.now.Add(d), c: make(chan time.Time, 1)}
+  f.tickers = append(f.tickers, t)
+  return t
+}
+
+//把时间推进 d，触发期间到期的定时器。与 time.Ticker 一样，接收方来不及处理时会丢弃多余的触发
+func (f *FakeClock) Advance(d time.Duration) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  f.now = f.now.Add(d)
+  for _, t := range f.tickers {
+    for !t.next.After(f.now) {
+      select {
+      case t.c <- t.next:
+      default:
+      }
+      t.next = t.next.Add(t.d)
+    }
+  }
+}
+
+type fakeTicker struct {
+  f    *FakeClock
+  d    time.Duration
+  next time.Time
+  c    chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+  return t.c
+}
+
+func (t *fakeTicker) Stop() {
+  t.f.mu.Lock()
+  defer t.f.mu.Unlock()
+  for i, o := range t.f.tickers {
+    if o == t {
+      t.f.tickers = append(t.f.tickers[:i], t.f.tickers[i+1:]...)
+      return
+    }
+  }
+}
+
+//缓存时钟的当前时间
+func (c *Cache) now() int64 {
+  return c.clock.Now().UnixNano()
+}
+
+//按缓存的时钟判断数据项是否已经过期
+func (c *Cache) expired(item Item) bool {
+  return item.Expiration != 0 && c.now() > item.Expiration
+}
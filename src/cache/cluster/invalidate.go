@@ -0,0 +1,275 @@
+package cluster
+
+import (
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  mrand "math/rand"
+  "net"
+  "sync"
+
+  "cache"
+)
+
+const (
+  opDelete byte = iota + 1
+  opFlush
+)
+
+const (
+  msgMagic     = 'I'
+  msgHeader    = 1 + 1 + 1 + 8 + 8 + 2  // magic、操作、剩余转发次数、来源、序号、键长度
+  macSize      = sha256.Size
+  maxKeyLen    = 60000  // 保证消息不超过一个 UDP 数据报
+  gossipFanout = 3      // 收到消息后转发给的随机节点数量
+  seenSize     = 4096   // 记住的最近消息数量，用于去重
+)
+
+//Invalidator 不再运行时发送返回的错误
+var ErrInvalidatorClosed = errors.New("Invalidator has been closed.")
+
+//在多个实例的本地缓存之间通过 UDP 广播删除和清空操作，只同步失效，不同步数据。
+//来源节点把消息发给所有已知节点，收到的节点再转发给几个随机节点以应对丢包，重复的消息会被忽略。
+//需要同步的删除和清空应通过 Invalidator 的 Delete 和 Flush 进行
+type Invalidator struct {
+  c      *cache.Cache
+  conn   *net.UDPConn
+  origin uint64
+  secret []byte
+  done   chan struct{}
+
+  mu     sync.Mutex
+  seq    uint64
+  peers  map[string]*net.UDPAddr
+  seen   map[[2]uint64]struct{}
+  order  [][2]uint64  // seen 中的消息按收到的顺序排列，超出 seenSize 时删除最早的
+  closed bool
+}
+
+//在 addr 上监听 UDP 并向 peers 广播 c 的失效操作。secret 不为空时消息带 HMAC 签名，
+//签名不对的消息会被丢弃，所有节点需要使用相同的 secret
+func NewInvalidator(c *cache.Cache, addr string, peers []string, secret []byte) (*Invalidator, error) {
+  laddr, err := net.ResolveUDPAddr("udp", addr)
+  if err != nil {
+    return nil, err
+  }
+  conn, err := net.ListenUDP("udp", laddr)
+  if err != nil {
+    return nil, err
+  }
+  var b [8]byte
+  if _, err := rand.Read(b[:]); err != nil {
+    conn.Close()
+    return nil, err
+  }
+  inv := &Invalidator {
+    c: c,
+    conn: conn,
+    origin: binary.BigEndian.Uint64(b[:]),
+    secret: secret,
+    done: make(chan struct{}),
+    peers: map[string]*net.UDPAddr{},
+    seen: map[[2]uint64]struct{}{},
+  }
+  for _, p := range peers {
+    if err := inv.AddPeer(p); err != nil {
+      conn.Close()
+      return nil, err
+    }
+  }
+  go inv.loop()
+  return inv, nil
+}
+
+//返回监听的地址
+func (inv *Invalidator) Addr() net.Addr {
+  return inv.conn.LocalAddr()
+}
+
+//加入一个节点
+func (inv *Invalidator) AddPeer(addr string) error {
+  a, err := net.ResolveUDPAddr("udp", addr)
+  if err != nil {
+    return err
+  }
+  inv.mu.Lock()
+  defer inv.mu.Unlock()
+  inv.peers[addr] = a
+  return nil
+}
+
+//移除一个节点
+func (inv *Invalidator) RemovePeer(addr string) {
+  inv.mu.Lock()
+  defer inv.mu.Unlock()
+  delete(inv.peers, addr)
+}
+
+//删除本地的数据项并通知所有节点删除
+func (inv *Invalidator) Delete(k string) error {
+  if len(k) > maxKeyLen {
+    return fmt.Errorf("Item %s is too long to broadcast.", k)
+  }
+  inv.c.Delete(k)
+  return inv.broadcast(opDelete, k)
+}
+
+//清空本地缓存并通知所有节点清空
+func (inv *Invalidator) Flush() error {
+  inv.c.Flush()
+  return inv.broadcast(opFlush, "")
+}
+
+//停止接收消息并关闭连接，不会关闭缓存
+func (inv *Invalidator) Close() error {
+  inv.mu.Lock()
+  if inv.closed {
+    inv.mu.Unlock()
+    return nil
+  }
+  inv.closed = true
+  inv.mu.Unlock()
+  err := inv.conn.Close()
+  <-inv.done
+  return err
+}
+
+//发送一条本节点产生的消息，转发一次
+func (inv *Invalidator) broadcast(op byte, k string) error {
+  inv.mu.Lock()
+  if inv.closed {
+    inv.mu.Unlock()
+    return ErrInvalidatorClosed
+  }
+  inv.seq++
+  seq := inv.seq
+  peers := inv.peerList()
+  inv.mu.Unlock()
+  msg := inv.encode(op, 1, inv.origin, seq, k)
+  var err error
+  for _, p := range peers {
+    if _, e := inv.conn.WriteToUDP(msg, p); e != nil && err == nil {
+      err = e
+    }
+  }
+  return err
+}
+
+//需要持有 mu
+func (inv *Invalidator) peerList() []*net.UDPAddr {
+  peers := make([]*net.UDPAddr, 0, len(inv.peers))
+  for _, p := range inv.peers {
+    peers = append(peers, p)
+  }
+  return peers
+}
+
+func (inv *Invalidator) loop() {
+  defer close(inv.done)
+  buf := make([]byte, 65536)
+  for {
+    n, from, err := inv.conn.ReadFromUDP(buf)
+    if err != nil {
+      if errors.Is(err, net.ErrClosed) {
+        return
+      }
+      continue
+    }
+    inv.handle(buf[:n], from)
+  }
+}
+
+func (inv *Invalidator) handle(msg []byte, from *net.UDPAddr) {
+  op, hops, origin, seq, k, ok := inv.decode(msg)
+  if !ok || origin == inv.origin {
+    return
+  }
+  id := [2]uint64{origin, seq}
+  inv.mu.Lock()
+  if _, found := inv.seen[id]; found {
+    inv.mu.Unlock()
+    return
+  }
+  inv.seen[id] = struct{}{}
+  inv.order = append(inv.order, id)
+  if len(inv.order) > seenSize {
+    delete(inv.seen, inv.order[0])
+    inv.order = inv.order[1:]
+  }
+  var forward []*net.UDPAddr
+  if hops > 0 {
+    forward = inv.peerList()
+    mrand.Shuffle(len(forward), func(i, j int) { forward[i], forward[j] = forward[j], forward[i] })
+  }
+  inv.mu.Unlock()
+  switch op {
+  case opDelete:
+    inv.c.Delete(k)
+  case opFlush:
+    inv.c.Flush()
+  }
+  if hops == 0 {
+    return
+  }
+  out := inv.encode(op, hops-1, origin, seq, k)
+  sent := 0
+  for _, p := range forward {
+    if sent == gossipFanout {
+      break
+    }
+    if p.IP.Equal(from.IP) && p.Port == from.Port {
+      continue
+    }
+    inv.conn.WriteToUDP(out, p)
+    sent++
+  }
+}
+
+func (inv *Invalidator) encode(op, hops byte, origin, seq uint64, k string) []byte {
+  msg := make([]byte, msgHeader, msgHeader + len(k) + macSize)
+  msg[0], msg[1], msg[2] = msgMagic, op, hops
+  binary.BigEndian.PutUint64(msg[3:], origin)
+  binary.BigEndian.PutUint64(msg[11:], seq)
+  binary.BigEndian.PutUint16(msg[19:], uint16(len(k)))
+  msg = append(msg, k...)
+  if len(inv.secret) > 0 {
+    msg = append(msg, inv.mac(msg)...)
+  }
+  return msg
+}
+
+func (inv *Invalidator) mac(msg []byte) []byte {
+  h := hmac.New(sha256.New, inv.secret)
+  h.Write(msg)
+  return h.Sum(nil)
+}
+
+func (inv *Invalidator) decode(msg []byte) (op, hops byte, origin, seq uint64, k string, ok bool) {
+  if len(inv.secret) > 0 {
+    if len(msg) < msgHeader + macSize {
+      return
+    }
+    body, sum := msg[:len(msg)-macSize], msg[len(msg)-macSize:]
+    if !hmac.Equal(sum, inv.mac(body)) {
+      return
+    }
+    msg = body
+  }
+  if len(msg) < msgHeader || msg[0] != msgMagic {
+    return
+  }
+  l := int(binary.BigEndian.Uint16(msg[19:]))
+  if len(msg) != msgHeader + l {
+    return
+  }
+  op, hops = msg[1], msg[2]
+  if op != opDelete && op != opFlush {
+    return
+  }
+  origin = binary.BigEndian.Uint64(msg[3:])
+  seq = binary.BigEndian.Uint64(msg[11:])
+  return op, hops, origin, seq, string(msg[msgHeader:]), true
+}
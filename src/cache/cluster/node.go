@@ -0,0 +1,168 @@
+package cluster
+
+import (
+  "bufio"
+  "context"
+  "fmt"
+  "io"
+  "net"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+//集群中的一个远程缓存节点，grpcclient.Client 可以直接作为节点使用
+type Node interface {
+  Get(ctx context.Context, k string) ([]byte, bool, error)
+  Set(ctx context.Context, k string, v []byte, d time.Duration) error
+  Delete(ctx context.Context, k string) (bool, error)
+}
+
+//通过 Redis 协议访问的节点，例如 server 包提供的服务。
+//请求在同一个连接上串行执行，连接出错后在下一次请求时重新建立
+type RESPNode struct {
+  addr string
+  mu   sync.Mutex
+  conn net.Conn
+  r    *bufio.Reader
+}
+
+//创建一个访问 addr 的节点，第一次请求时才建立连接
+func NewRESPNode(addr string) *RESPNode {
+  return &RESPNode{addr: addr}
+}
+
+//返回节点的地址
+func (n *RESPNode) Addr() string {
+  return n.addr
+}
+
+func (n *RESPNode) Get(ctx context.Context, k string) ([]byte, bool, error) {
+  v, err := n.do(ctx, "GET", k)
+  if err != nil {
+    return nil, false, err
+  }
+  if v == nil {
+    return nil, false, nil
+  }
+  b, ok := v.([]byte)
+  if !ok {
+    return nil, false, fmt.Errorf("Unexpected reply %v to GET from %s.", v, n.addr)
+  }
+  return b, true, nil
+}
+
+//d 小于等于 0 时不设置过期时间，不足 1 毫秒的部分向上取整
+func (n *RESPNode) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+  args := []string{"SET", k, string(v)}
+  if d > 0 {
+    ms := (d + time.Millisecond - 1) / time.Millisecond
+    args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+  }
+  _, err := n.do(ctx, args...)
+  return err
+}
+
+func (n *RESPNode) Delete(ctx context.Context, k string) (bool, error) {
+  v, err := n.do(ctx, "DEL", k)
+  if err != nil {
+    return false, err
+  }
+  i, _ := v.(int64)
+  return i > 0, nil
+}
+
+//关闭连接
+func (n *RESPNode) Close() error {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  if n.conn == nil {
+    return nil
+  }
+  err := n.conn.Close()
+  n.conn, n.r = nil, nil
+  return err
+}
+
+//发送一条命令并读取回复，服务端返回的错误不会关闭连接
+func (n *RESPNode) do(ctx context.Context, args ...string) (interface{}, error) {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  if n.conn == nil {
+    var d net.Dialer
+    conn, err := d.DialContext(ctx, "tcp", n.addr)
+    if err != nil {
+      return nil, err
+    }
+    n.conn, n.r = conn, bufio.NewReader(conn)
+  }
+  //ctx 被取消时让阻塞的读写立即返回
+  conn := n.conn
+  deadline, _ := ctx.Deadline()
+  conn.SetDeadline(deadline)
+  stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+  defer stop()
+  v, err := n.roundTrip(args)
+  if _, ok := err.(replyError); !ok && err != nil {
+    conn.Close()
+    n.conn, n.r = nil, nil
+    if ctx.Err() != nil {
+      err = ctx.Err()
+    }
+  }
+  return v, err
+}
+
+func (n *RESPNode) roundTrip(args []string) (interface{}, error) {
+  var b strings.Builder
+  b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+  for _, a := range args {
+    b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+  }
+  if _, err := io.WriteString(n.conn, b.String()); err != nil {
+    return nil, err
+  }
+  return readReply(n.r)
+}
+
+//服务端返回的错误回复
+type replyError string
+
+func (e replyError) Error() string {
+  return string(e)
+}
+
+//读取一条回复，空的批量回复返回 nil
+func readReply(r *bufio.Reader) (interface{}, error) {
+  line, err := r.ReadString('\n')
+  if err != nil {
+    return nil, err
+  }
+  line = strings.TrimRight(line, "\r\n")
+  if len(line) == 0 {
+    return nil, fmt.Errorf("Protocol error: empty reply")
+  }
+  switch line[0] {
+  case '+':
+    return line[1:], nil
+  case '-':
+    return nil, replyError(line[1:])
+  case ':':
+    return strconv.ParseInt(line[1:], 10, 64)
+  case '$':
+    l, err := strconv.Atoi(line[1:])
+    if err != nil {
+      return nil, fmt.Errorf("Protocol error: invalid bulk length")
+    }
+    if l < 0 {
+      return nil, nil
+    }
+    buf := make([]byte, l+2)
+    if _, err := io.ReadFull(r, buf); err != nil {
+      return nil, err
+    }
+    return buf[:l], nil
+  }
+  return nil, fmt.Errorf("Protocol error: unexpected reply '%s'", line)
+}
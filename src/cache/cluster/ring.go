@@ -0,0 +1,233 @@
+package cluster
+
+import (
+  "context"
+  "errors"
+  "hash/fnv"
+  "sort"
+  "strconv"
+  "sync"
+  "time"
+)
+
+//哈希环中没有节点时返回的错误
+var ErrNoNodes = errors.New("No nodes in ring.")
+
+//每个节点默认的虚拟节点数量
+const DefaultVirtualNodes = 160
+
+//用带虚拟节点的一致性哈希把键分布到多个节点上，每个键保存在顺时针方向的 replicas 个不同节点中。
+//增减节点时只有相邻区间的键会移动
+type Ring struct {
+  mu       sync.RWMutex
+  vnodes   int
+  replicas int
+  hashes   []uint32           // 排好序的虚拟节点哈希
+  owners   map[uint32]string  // 虚拟节点哈希对应的节点名
+  nodes    map[string]Node
+}
+
+//创建一个哈希环，vnodes 小于等于 0 时使用 DefaultVirtualNodes，replicas 小于 1 时为 1
+func NewRing(vnodes, replicas int) *Ring {
+  if vnodes <= 0 {
+    vnodes = DefaultVirtualNodes
+  }
+  if replicas < 1 {
+    replicas = 1
+  }
+  return &Ring {
+    vnodes: vnodes,
+    replicas: replicas,
+    owners: map[uint32]string{},
+    nodes: map[string]Node{},
+  }
+}
+
+func hash(s string) uint32 {
+  h := fnv.New32a()
+  h.Write([]byte(s))
+  return h.Sum32()
+}
+
+//节点 name 的第 i 个虚拟节点的哈希
+func vnode(name string, i int) uint32 {
+  return hash(name + "#" + strconv.Itoa(i))
+}
+
+//把虚拟节点 h 分配给 name，哈希冲突时归名字较小的节点，结果与加入顺序无关
+func (r *Ring) own(h uint32, name string) {
+  owner, found := r.owners[h]
+  if !found {
+    r.hashes = append(r.hashes, h)
+  } else if owner < name {
+    return
+  }
+  r.owners[h] = name
+}
+
+//加入名为 name 的节点，同名节点已存在时替换
+func (r *Ring) Add(name string, n Node) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  if _, found := r.nodes[name]; found {
+    r.nodes[name] = n
+    return
+  }
+  r.nodes[name] = n
+  for i := 0; i < r.vnodes; i++ {
+    r.own(vnode(name, i), name)
+  }
+  sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+//移除名为 name 的节点并返回它，节点不存在时返回 nil。不会关闭节点
+func (r *Ring) Remove(name string) Node {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  n, found := r.nodes[name]
+  if !found {
+    return nil
+  }
+  delete(r.nodes, name)
+  hashes := r.hashes[:0]
+  for _, h := range r.hashes {
+    if r.owners[h] == name {
+      delete(r.owners, h)
+    } else {
+      hashes = append(hashes, h)
+    }
+  }
+  r.hashes = hashes
+  //冲突时被覆盖的其他节点的虚拟节点需要补回来
+  for other := range r.nodes {
+    for i := 0; i < r.vnodes; i++ {
+      r.own(vnode(other, i), other)
+    }
+  }
+  sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+  return n
+}
+
+//返回所有节点的名字，按名字排序
+func (r *Ring) Names() []string {
+  r.mu.RLock()
+  defer r.mu.RUnlock()
+  names := make([]string, 0, len(r.nodes))
+  for name := range r.nodes {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+//返回负责 k 的节点名，第一个为主节点，节点不足时少于 replicas 个
+func (r *Ring) Lookup(k string) []string {
+  r.mu.RLock()
+  defer r.mu.RUnlock()
+  return r.lookup(k)
+}
+
+func (r *Ring) lookup(k string) []string {
+  if len(r.hashes) == 0 {
+    return nil
+  }
+  n := min(r.replicas, len(r.nodes))
+  names := make([]string, 0, n)
+  h := hash(k)
+  i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+  for j := 0; j < len(r.hashes) && len(names) < n; j++ {
+    name := r.owners[r.hashes[(i+j)%len(r.hashes)]]
+    dup := false
+    for _, m := range names {
+      if m == name {
+        dup = true
+        break
+      }
+    }
+    if !dup {
+      names = append(names, name)
+    }
+  }
+  return names
+}
+
+func (r *Ring) replicasOf(k string) []Node {
+  r.mu.RLock()
+  defer r.mu.RUnlock()
+  names := r.lookup(k)
+  nodes := make([]Node, len(names))
+  for i, name := range names {
+    nodes[i] = r.nodes[name]
+  }
+  return nodes
+}
+
+//依次从负责 k 的节点读取，节点出错时尝试下一个，所有节点都出错时返回最后一个错误
+func (r *Ring) Get(ctx context.Context, k string) ([]byte, bool, error) {
+  nodes := r.replicasOf(k)
+  if len(nodes) == 0 {
+    return nil, false, ErrNoNodes
+  }
+  var err error
+  for _, n := range nodes {
+    var v []byte
+    var found bool
+    if v, found, err = n.Get(ctx, k); err == nil {
+      return v, found, nil
+    }
+    if ctx.Err() != nil {
+      break
+    }
+  }
+  return nil, false, err
+}
+
+//写入所有负责 k 的节点，返回第一个错误
+func (r *Ring) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+  nodes := r.replicasOf(k)
+  if len(nodes) == 0 {
+    return ErrNoNodes
+  }
+  return each(nodes, func(n Node) error { return n.Set(ctx, k, v, d) })
+}
+
+//从所有负责 k 的节点删除，任一节点上存在时返回 true
+func (r *Ring) Delete(ctx context.Context, k string) (bool, error) {
+  nodes := r.replicasOf(k)
+  if len(nodes) == 0 {
+    return false, ErrNoNodes
+  }
+  var mu sync.Mutex
+  deleted := false
+  err := each(nodes, func(n Node) error {
+    ok, err := n.Delete(ctx, k)
+    mu.Lock()
+    deleted = deleted || ok
+    mu.Unlock()
+    return err
+  })
+  return deleted, err
+}
+
+//并发地对每个节点执行 f
+func each(nodes []Node, f func(Node) error) error {
+  if len(nodes) == 1 {
+    return f(nodes[0])
+  }
+  errs := make([]error, len(nodes))
+  var wg sync.WaitGroup
+  for i, n := range nodes {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      errs[i] = f(n)
+    }()
+  }
+  wg.Wait()
+  for _, err := range errs {
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
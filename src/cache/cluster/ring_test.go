@@ -0,0 +1,177 @@
+package cluster
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "testing"
+  "time"
+
+  "cache"
+)
+
+//用本地缓存模拟的节点，down 为 true 时所有请求返回错误
+type memNode struct {
+  c    *cache.Cache
+  down bool
+}
+
+var errDown = errors.New("Node is down.")
+
+func newMemNode() *memNode {
+  return &memNode{c: cache.New()}
+}
+
+func (n *memNode) Get(ctx context.Context, k string) ([]byte, bool, error) {
+  if n.down {
+    return nil, false, errDown
+  }
+  v, found := n.c.Get(k)
+  if !found {
+    return nil, false, nil
+  }
+  return v.([]byte), true, nil
+}
+
+func (n *memNode) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+  if n.down {
+    return errDown
+  }
+  n.c.Set(k, v, cache.WithTTL(d))
+  return nil
+}
+
+func (n *memNode) Delete(ctx context.Context, k string) (bool, error) {
+  if n.down {
+    return false, errDown
+  }
+  _, found := n.c.GetAndDelete(k)
+  return found, nil
+}
+
+const ringKeys = 10000
+
+//返回每个键的主节点
+func primaries(r *Ring) map[string]string {
+  owners := make(map[string]string, ringKeys)
+  for i := 0; i < ringKeys; i++ {
+    k := fmt.Sprintf("key%d", i)
+    owners[k] = r.Lookup(k)[0]
+  }
+  return owners
+}
+
+func newTestRing(names ...string) *Ring {
+  r := NewRing(0, 1)
+  for _, name := range names {
+    r.Add(name, newMemNode())
+  }
+  return r
+}
+
+//加入节点时只有移到新节点的键改变主节点，移动的比例接近 1/n
+func TestRingAdd(t *testing.T) {
+  r := newTestRing("a", "b", "c")
+  before := primaries(r)
+  r.Add("d", newMemNode())
+  moved := 0
+  for k, owner := range primaries(r) {
+    if owner == before[k] {
+      continue
+    }
+    if owner != "d" {
+      t.Fatalf("%s moved from %s to %s, want only moves to d", k, before[k], owner)
+    }
+    moved++
+  }
+  if moved < ringKeys/8 || moved > ringKeys*3/8 {
+    t.Fatalf("%d of %d keys moved to the new node, want about a quarter", moved, ringKeys)
+  }
+}
+
+//移除节点时只有它的键移到其他节点，重新加入后恢复原来的分布
+func TestRingRemove(t *testing.T) {
+  r := newTestRing("a", "b", "c", "d")
+  before := primaries(r)
+  if r.Remove("b") == nil {
+    t.Fatal("Remove(b) returned nil")
+  }
+  if r.Remove("b") != nil {
+    t.Fatal("Remove of a missing node returned a node")
+  }
+  for k, owner := range primaries(r) {
+    switch {
+    case owner == "b":
+      t.Fatalf("%s is still on the removed node", k)
+    case before[k] != "b" && owner != before[k]:
+      t.Fatalf("%s moved from %s to %s although its node stayed", k, before[k], owner)
+    }
+  }
+  r.Add("b", newMemNode())
+  for k, owner := range primaries(r) {
+    if owner != before[k] {
+      t.Fatalf("%s is on %s after re-adding b, want %s", k, owner, before[k])
+    }
+  }
+  if fmt.Sprint(r.Names()) != "[a b c d]" {
+    t.Fatalf("Names() = %v, want [a b c d]", r.Names())
+  }
+}
+
+//分布只取决于节点集合，与加入顺序无关
+func TestRingOrder(t *testing.T) {
+  a, b := newTestRing("a", "b", "c"), newTestRing("c", "a", "b")
+  pa, pb := primaries(a), primaries(b)
+  for k := range pa {
+    if pa[k] != pb[k] {
+      t.Fatalf("%s is on %s and %s depending on the order nodes were added", k, pa[k], pb[k])
+    }
+  }
+}
+
+//写入所有副本，主节点出错时从下一个副本读取
+func TestRingReplicas(t *testing.T) {
+  r := NewRing(0, 2)
+  nodes := map[string]*memNode{}
+  for _, name := range []string{"a", "b", "c"} {
+    nodes[name] = newMemNode()
+    r.Add(name, nodes[name])
+  }
+  ctx := context.Background()
+  names := r.Lookup("k")
+  if len(names) != 2 || names[0] == names[1] {
+    t.Fatalf("Lookup(k) = %v, want two different nodes", names)
+  }
+  if err := r.Set(ctx, "k", []byte("v"), cache.NoExpiration); err != nil {
+    t.Fatal(err)
+  }
+  for _, name := range names {
+    if _, found := nodes[name].c.Get("k"); !found {
+      t.Fatalf("replica %s does not have the item", name)
+    }
+  }
+  nodes[names[0]].down = true
+  if v, found, err := r.Get(ctx, "k"); err != nil || !found || string(v) != "v" {
+    t.Fatalf("Get(k) with the primary down = %q, %v, %v, want v", v, found, err)
+  }
+  if _, err := r.Delete(ctx, "k"); err != errDown {
+    t.Fatalf("Delete(k) with the primary down = %v, want %v", err, errDown)
+  }
+  nodes[names[1]].down = true
+  if _, _, err := r.Get(ctx, "k"); err != errDown {
+    t.Fatalf("Get(k) with all replicas down = %v, want %v", err, errDown)
+  }
+}
+
+func TestRingEmpty(t *testing.T) {
+  r := NewRing(0, 1)
+  if _, _, err := r.Get(context.Background(), "k"); err != ErrNoNodes {
+    t.Fatalf("Get on an empty ring = %v, want ErrNoNodes", err)
+  }
+  if err := r.Set(context.Background(), "k", nil, 0); err != ErrNoNodes {
+    t.Fatalf("Set on an empty ring = %v, want ErrNoNodes", err)
+  }
+  if r.Lookup("k") != nil {
+    t.Fatal("Lookup on an empty ring returned nodes")
+  }
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+  "bytes"
+  "compress/gzip"
+  "encoding/base64"
+  "fmt"
+  "io"
+  "sync"
+)
+
+//Item.Compressed 的取值
+const (
+  compressBytes  uint8 = iota + 1  // 原值为 []byte
+  compressString                   // 原值为 string
+)
+
+var gzipWriters = sync.Pool {
+  New: func() interface{} {
+    w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+    return w
+  },
+}
+
+//设置压缩的阈值，之后写入的长度不小于 threshold 的 []byte 和 string 值会用 gzip 压缩后保存，
+//读取时自动解压。0 表示不压缩，已有的数据项不受影响
+func (c *Cache) SetCompression(threshold int) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.compressAt = threshold
+}
+
+//按阈值压缩值，返回保存的值和压缩方式，压缩后没有变小时保存原值。需要持有分片的锁
+func (c *Cache) compress(v interface{}) (interface{}, uint8) {
+  if c.compressAt <= 0 {
+    return v, 0
+  }
+  var b []byte
+  var z uint8
+  switch v := v.(type) {
+  case []byte:
+    b, z = v, compressBytes
+  case string:
+    b, z = []byte(v), compressString
+  default:
+    return v, 0
+  }
+  if len(b) < c.compressAt {
+    return v, 0
+  }
+  var buf bytes.Buffer
+  w := gzipWriters.Get().(*gzip.Writer)
+  w.Reset(&buf)
+  _, err := w.Write(b)
+  if err == nil {
+    err = w.Close()
+  }
+  gzipWriters.Put(w)
+  if err != nil || buf.Len() >= len(b) {
+    return v, 0
+  }
+  return bytes.Clone(buf.Bytes()), z
+}
+
+//返回数据项的原值，压缩的值会被解压，压缩的数据损坏时返回错误
+func (item Item) decode() (interface{}, error) {
+  if item.Compressed == 0 {
+    return item.Object, nil
+  }
+  var data []byte
+  switch v := item.Object.(type) {
+  case []byte:
+    data = v
+  case string:
+    //JSON 把 []byte 编码为 base64 字符串，兼容之前未解压就保存的 JSON 文件
+    b, err := base64.StdEncoding.DecodeString(v)
+    if err != nil {
+      return nil, err
+    }
+    data = b
+  default:
+    return nil, fmt.Errorf("Compressed value has type %T.", item.Object)
+  }
+  r, err := gzip.NewReader(bytes.NewReader(data))
+  if err != nil {
+    return nil, err
+  }
+  b, err := io.ReadAll(r)
+  if err != nil {
+    return nil, err
+  }
+  if item.Compressed == compressString {
+    return string(b), nil
+  }
+  return b, nil
+}
+
+//与 decode 相同，压缩的数据损坏时返回 nil，用于回调和事件等无法返回错误的地方
+func (item Item) value() interface{} {
+  v, _ := item.decode()
+  return v
+}
+
+//压缩的数据无法解压的数据项视为不存在，记录日志后在另一个 goroutine 中删除，持有锁时也可以调用。
+//缓存已冻结时不删除
+func (c *Cache) corrupt(k string, item Item, err error) {
+  c.log().Warnf("Item %s can't be decompressed and is dropped: %v", k, err)
+  go func() {
+    s := c.shard(k)
+    s.mu.Lock()
+    defer s.unlock()
+    if cur, found := s.items[k]; found && cur.Version == item.Version && !c.frozen {
+      s.remove(k, EventDelete)
+    }
+  }()
+}
+
+//除 gob 以外的序列化方式不一定能保留 []byte 类型，保存前解压，读回的是原值。
+//items 是副本，无法解压的数据项不保存
+func (c *Cache) portable(s Serializer, items map[string]Item) {
+  switch s.(type) {
+  case GobSerializer, *GobSerializer:
+    return
+  }
+  for k, item := range items {
+    if item.Compressed == 0 {
+      continue
+    }
+    v, err := item.decode()
+    if err != nil {
+      c.corrupt(k, item, err)
+      delete(items, k)
+      continue
+    }
+    item.Object, item.Compressed = v, 0
+    items[k] = item
+  }
+}
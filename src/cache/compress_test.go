@@ -0,0 +1,108 @@
+package cache
+
+import (
+  "bytes"
+  "fmt"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+//开启压缩后用 JSON 保存再读回，Get 返回的是原值而不是压缩后的数据
+func TestCompressionJSONRoundTrip(t *testing.T) {
+  long := strings.Repeat("compressible ", 100)
+  for _, sorted := range []bool{false, true} {
+    c := New(WithCompression(64))
+    c.SetSortedIteration(sorted)
+    c.Set("s", long)
+    c.Set("short", "x")
+    var buf bytes.Buffer
+    if err := c.SaveWith(&buf, JSONSerializer{}); err != nil {
+      t.Fatal(err)
+    }
+    if v, _ := c.Get("s"); v != long {
+      t.Fatalf("sorted=%v: value in the original cache changed after saving", sorted)
+    }
+    d := New()
+    if err := d.LoadWith(&buf, JSONSerializer{}); err != nil {
+      t.Fatal(err)
+    }
+    if v, _ := d.Get("s"); v != long {
+      t.Fatalf("sorted=%v: Get(s) = %.40q, want the original string", sorted, v)
+    }
+    if v, _ := d.Get("short"); v != "x" {
+      t.Fatalf("sorted=%v: Get(short) = %v, want x", sorted, v)
+    }
+  }
+}
+
+//之前没有解压就用 JSON 保存的文件，压缩的数据被编码为 base64 字符串，读回后仍能解压
+func TestCompressedBase64Value(t *testing.T) {
+  long := strings.Repeat("compressible ", 100)
+  c := New(WithCompression(64))
+  obj, mode := c.compress(long)
+  if mode == 0 {
+    t.Fatal("value was not compressed")
+  }
+  var buf bytes.Buffer
+  items := map[string]Item{"s": {Object: obj, Compressed: mode}}
+  if err := (JSONSerializer{}).Encode(&buf, items); err != nil {
+    t.Fatal(err)
+  }
+  d := New()
+  if err := d.LoadWith(&buf, JSONSerializer{}); err != nil {
+    t.Fatal(err)
+  }
+  if v, _ := d.Get("s"); v != long {
+    t.Fatalf("Get(s) = %.40q, want the original string", v)
+  }
+}
+
+//记录 Warnf 的 Logger
+type warnLogger struct {
+  mu    sync.Mutex
+  warns []string
+}
+
+func (l *warnLogger) Debugf(format string, args ...interface{}) {}
+func (l *warnLogger) Errorf(format string, args ...interface{}) {}
+
+func (l *warnLogger) Warnf(format string, args ...interface{}) {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  l.warns = append(l.warns, fmt.Sprintf(format, args...))
+}
+
+func (l *warnLogger) count() int {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  return len(l.warns)
+}
+
+//压缩的数据损坏时读取视为未命中，数据项被删除并记录日志，不会返回压缩的数据
+func TestCompressedCorrupt(t *testing.T) {
+  log := &warnLogger{}
+  c := New(WithLogger(log))
+  items := map[string]Item{"bad": {Object: []byte("not gzip"), Compressed: compressString}}
+  var buf bytes.Buffer
+  if err := (GobSerializer{}).Encode(&buf, items); err != nil {
+    t.Fatal(err)
+  }
+  if err := c.Load(&buf); err != nil {
+    t.Fatal(err)
+  }
+  if v, found := c.Get("bad"); found || v != nil {
+    t.Fatalf("Get(bad) = %v, %v, want a miss", v, found)
+  }
+  if log.count() == 0 {
+    t.Fatal("the corrupt item was not logged")
+  }
+  deadline := time.Now().Add(5 * time.Second)
+  for c.Count() != 0 {
+    if time.Now().After(deadline) {
+      t.Fatal("the corrupt item was not deleted")
+    }
+    time.Sleep(time.Millisecond)
+  }
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync/atomic"
+  "time"
+)
+
+//一次正在进行的计算，同一个键的并发请求共享结果
+type call struct {
+  done chan struct{}  // 计算完成后关闭
+  v    interface{}
+  err  error
+}
+
+//返回已有的数据项，不存在时调用 f 计算并以生存时间 d 保存。
+//第二个返回值表示本次调用是否执行了 f，同一个键同时只有一个调用会执行 f，其余调用等待并共享结果。
+//f 返回错误时不保存结果
+func (c *Cache) GetOrSetWithStatus(k string, d time.Duration, f func() (interface{}, error)) (interface{}, bool, error) {
+  return c.getOrCompute(context.Background(), k, d, f)
+}
+
+//与 GetOrSetWithStatus 相同，等待其他调用的计算结果时 ctx 结束则提前返回 ctx 的错误
+func (c *Cache) getOrCompute(ctx context.Context, k string, d time.Duration, f func() (interface{}, error)) (interface{}, bool, error) {
+  return c.compute(ctx, k, d, f, false)
+}
+
+//refresh 为 true 时即使数据项存在也重新计算，同一个键正在计算时共享其结果
+func (c *Cache) compute(ctx context.Context, k string, d time.Duration, f func() (interface{}, error), refresh bool) (interface{}, bool, error) {
+  if !refresh {
+    if v, found := c.Get(k); found {
+      return v, false, nil
+    }
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  if v, negative, found := s.lookup(k); found && !refresh {
+    s.unlock()
+    if negative {
+      return nil, false, ErrNotFound
+    }
+    return v, false, nil
+  }
+  if c.closed {
+    s.unlock()
+    return nil, false, ErrClosed
+  }
+  if cl, found := s.calls[k]; found {
+    s.unlock()
+    atomic.AddUint64(&c.stats.coalesced, 1)
+    select {
+    case <-cl.done:
+      return c.copyValue(cl.v), false, cl.err
+    case <-ctx.Done():
+      return nil, false, ctx.Err()
+    }
+  }
+  cl := &call{done: make(chan struct{})}
+  s.calls[k] = cl
+  s.unlock()
+
+  //f 发生 panic 时也要唤醒等待者
+  finished := false
+  loaded := c.loadStarted()
+  start := time.Now()
+  defer func() {
+    s.mu.Lock()
+    if !finished {
+      cl.err = fmt.Errorf("Computing item %s panicked.", k)
+      c.log().Errorf("Computing item %s panicked.", k)
+    } else if cl.err == nil && c.writable() == nil {
+      s.set(k, cl.v, d)
+      if item, found := s.items[k]; found {
+        item.ComputeTime = int64(time.Since(start))
+        s.items[k] = item
+        s.publish(k, &item)
+      }
+    } else if errors.Is(cl.err, ErrNotFound) && c.negativeTTL > 0 && c.writable() == nil {
+      s.setNegative(k, c.negativeTTL)
+    } else if cl.err != nil && !errors.Is(cl.err, ErrNotFound) {
+      c.log().Debugf("Loading item %s failed: %v", k, cl.err)
+    }
+    delete(s.calls, k)
+    s.unlock()
+    loaded(cl.err)
+    close(cl.done)
+  }()
+  cl.v, cl.err = f()
+  finished = true
+  return c.copyValue(cl.v), true, cl.err
+}
+
+//返回已有的数据项，不存在时以生存时间 d 保存 v 并返回 v。第二个返回值表示数据项是否已经存在
+func (c *Cache) GetOrSet(k string, v interface{}, d time.Duration) (interface{}, bool) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if old, found := s.get(k); found {
+    c.hit(true)
+    return old, true
+  }
+  c.hit(false)
+  if c.writable() == nil {
+    s.set(k, v, d)
+  }
+  return v, false
+}
+
+//返回已有的数据项，不存在时调用 fn 计算并以生存时间 d 保存，同一个键的并发调用只计算一次
+func (c *Cache) GetOrCompute(k string, d time.Duration, fn func() (interface{}, error)) (interface{}, error) {
+  v, _, err := c.GetOrSetWithStatus(k, d, fn)
+  return v, err
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+  "reflect"
+)
+
+//读取时返回值的副本，cloner 为 nil 时使用 DeepCopy。调用方修改 Get 返回的切片或 map 不会影响缓存，
+//使用 WithNoCopy 写入的数据项和压缩的数据项不复制，后者每次读取都会解压出新的值
+func WithCopyOnRead(cloner func(interface{}) interface{}) Option {
+  if cloner == nil {
+    cloner = DeepCopy
+  }
+  return func(o *options) { o.cloner = cloner }
+}
+
+//返回读取者得到的值，开启 WithCopyOnRead 时为副本，压缩的数据无法解压时返回错误
+func (c *Cache) read(item Item) (interface{}, error) {
+  if c.cloner == nil || item.NoCopy || item.Compressed != 0 {
+    return item.decode()
+  }
+  return c.cloner(item.Object), nil
+}
+
+//复制计算得到的值，计算者和等待者各自得到一份副本
+func (c *Cache) copyValue(v interface{}) interface{} {
+  if c.cloner == nil || v == nil {
+    return v
+  }
+  return c.cloner(v)
+}
+
+//用反射深度复制切片、map、指针、数组和结构体，同一个指针只复制一次。
+//结构体的未导出字段、chan 和函数按原值复制
+func DeepCopy(v interface{}) interface{} {
+  if v == nil {
+    return nil
+  }
+  return deepCopy(reflect.ValueOf(v), map[uintptr]reflect.Value{}).Interface()
+}
+
+func deepCopy(v reflect.Value, seen map[uintptr]reflect.Value) reflect.Value {
+  switch v.Kind() {
+  case reflect.Slice:
+    if v.IsNil() {
+      return v
+    }
+    c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+    if !hasIndirect(v.Type().Elem()) {
+      reflect.Copy(c, v)
+      return c
+    }
+    for i := 0; i < v.Len(); i++ {
+      c.Index(i).Set(deepCopy(v.Index(i), seen))
+    }
+    return c
+  case reflect.Map:
+    if v.IsNil() {
+      return v
+    }
+    c := reflect.MakeMapWithSize(v.Type(), v.Len())
+    it := v.MapRange()
+    for it.Next() {
+      c.SetMapIndex(deepCopy(it.Key(), seen), deepCopy(it.Value(), seen))
+    }
+    return c
+  case reflect.Ptr:
+    if v.IsNil() {
+      return v
+    }
+    if c, found := seen[v.Pointer()]; found {
+      return c
+    }
+    c := reflect.New(v.Type().Elem())
+    seen[v.Pointer()] = c
+    c.Elem().Set(deepCopy(v.Elem(), seen))
+    return c
+  case reflect.Interface:
+    if v.IsNil() {
+      return v
+    }
+    c := reflect.New(v.Type()).Elem()
+    c.Set(deepCopy(v.Elem(), seen))
+    return c
+  case reflect.Array:
+    c := reflect.New(v.Type()).Elem()
+    c.Set(v)
+    if hasIndirect(v.Type().Elem()) {
+      for i := 0; i < v.Len(); i++ {
+        c.Index(i).Set(deepCopy(v.Index(i), seen))
+      }
+    }
+    return c
+  case reflect.Struct:
+    c := reflect.New(v.Type()).Elem()
+    c.Set(v)
+    for i := 0; i < v.NumField(); i++ {
+      if f := c.Field(i); f.CanSet() && hasIndirect(f.Type()) {
+        f.Set(deepCopy(v.Field(i), seen))
+      }
+    }
+    return c
+  }
+  return v
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+  "crypto/aes"
+  "crypto/cipher"
+  "crypto/rand"
+  "encoding/binary"
+  "errors"
+  "io"
+)
+
+//加密的转储文件无法用当前的密钥解密，或者没有设置密钥
+var ErrDumpKey = errors.New("Dump file can't be decrypted with the persistence key.")
+
+//加密时每段明文的长度，每段单独认证，读写都只需要一段的内存
+const sealChunk = 64 << 10
+
+var errDecrypt = errors.New("Decryption failed.")
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+  block, err := aes.NewCipher(key)
+  if err != nil {
+    return nil, err
+  }
+  return cipher.NewGCM(block)
+}
+
+//第 seq 段的 nonce，由随机的基础 nonce 与段号异或得到
+func segmentNonce(base []byte, seq uint64) []byte {
+  n := append([]byte(nil), base...)
+  var b [8]byte
+  binary.BigEndian.PutUint64(b[:], seq)
+  for i := range b {
+    n[len(n)-8+i] ^= b[i]
+  }
+  return n
+}
+
+//按段加密写入：先写基础 nonce，之后每段为 4 字节长度和密文。
+//最后一段的附加数据不同，截掉结尾的段会在读取时被发现
+type sealWriter struct {
+  aead  cipher.AEAD
+  w     io.Writer
+  nonce []byte
+  seq   uint64
+  buf   []byte
+}
+
+func newSealWriter(key []byte, w io.Writer) (*sealWriter, error) {
+  aead, err := newAEAD(key)
+  if err != nil {
+    return nil, err
+  }
+  nonce := make([]byte, aead.NonceSize())
+  if _, err := rand.Read(nonce); err != nil {
+    return nil, err
+  }
+  if _, err := w.Write(nonce); err != nil {
+    return nil, err
+  }
+  return &sealWriter{aead: aead, w: w, nonce: nonce, buf: make([]byte, 0, sealChunk)}, nil
+}
+
+func (w *sealWriter) Write(p []byte) (int, error) {
+  n := len(p)
+  for len(p) > 0 {
+    m := min(len(p), sealChunk - len(w.buf))
+    w.buf = append(w.buf, p[:m]...)
+    p = p[m:]
+    if len(w.buf) == sealChunk {
+      if err := w.seal(false); err != nil {
+        return n - len(p), err
+      }
+    }
+  }
+  return n, nil
+}
+
+//写入最后一段，可能为空
+func (w *sealWriter) Close() error {
+  return w.seal(true)
+}
+
+func (w *sealWriter) seal(last bool) error {
+  aad := []byte{0}
+  if last {
+    aad[0] = 1
+  }
+  ct := w.aead.Seal(nil, segmentNonce(w.nonce, w.seq), w.buf, aad)
+  w.seq++
+  w.buf = w.buf[:0]
+  var h [4]byte
+  binary.BigEndian.PutUint32(h[:], uint32(len(ct)))
+  if _, err := w.w.Write(h[:]); err != nil {
+    return err
+  }
+  _, err := w.w.Write(ct)
+  return err
+}
+
+//读取 sealWriter 写入的数据并逐段解密认证，failed 表示曾经解密失败
+type openReader struct {
+  aead   cipher.AEAD
+  r      io.Reader
+  nonce  []byte
+  seq    uint64
+  cur    []byte
+  done   bool
+  failed bool
+}
+
+func newOpenReader(key []byte, r io.Reader) (*openReader, error) {
+  aead, err := newAEAD(key)
+  if err != nil {
+    return nil, err
+  }
+  nonce := make([]byte, aead.NonceSize())
+  if _, err := io.ReadFull(r, nonce); err != nil {
+    return nil, io.ErrUnexpectedEOF
+  }
+  return &openReader{aead: aead, r: r, nonce: nonce}, nil
+}
+
+func (r *openReader) Read(p []byte) (int, error) {
+  for len(r.cur) == 0 {
+    if r.done {
+      return 0, io.EOF
+    }
+    if err := r.open(); err != nil {
+      return 0, err
+    }
+  }
+  n := copy(p, r.cur)
+  r.cur = r.cur[n:]
+  return n, nil
+}
+
+func (r *openReader) open() error {
+  var h [4]byte
+  if _, err := io.ReadFull(r.r, h[:]); err != nil {
+    return io.ErrUnexpectedEOF
+  }
+  l := binary.BigEndian.Uint32(h[:])
+  if l > sealChunk + uint32(r.aead.Overhead()) {
+    r.failed = true
+    return errDecrypt
+  }
+  ct := make([]byte, l)
+  if _, err := io.ReadFull(r.r, ct); err != nil {
+    return io.ErrUnexpectedEOF
+  }
+  nonce := segmentNonce(r.nonce, r.seq)
+  pt, err := r.aead.Open(nil, nonce, ct, []byte{0})
+  if err != nil {
+    if pt, err = r.aead.Open(nil, nonce, ct, []byte{1}); err != nil {
+      r.failed = true
+      return errDecrypt
+    }
+    r.done = true
+  }
+  r.seq++
+  r.cur = pt
+  return nil
+}
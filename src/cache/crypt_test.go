@@ -0,0 +1,112 @@
+package cache
+
+import (
+  "bytes"
+  "errors"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func TestEncryptedDump(t *testing.T) {
+  file := filepath.Join(t.TempDir(), "dump")
+  c := New(WithPersistenceKey(testKey))
+  c.Set("a", "plaintext-value")
+  c.Set("big", strings.Repeat("x", 3*sealChunk))
+  if err := c.SaveToFile(file); err != nil {
+    t.Fatal(err)
+  }
+  data, err := os.ReadFile(file)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if bytes.Contains(data, []byte("plaintext-value")) {
+    t.Fatal("the dump file contains a value in plain text")
+  }
+  r := New(WithPersistenceKey(testKey))
+  if err := r.LoadFile(file); err != nil {
+    t.Fatal(err)
+  }
+  if v, _ := r.Get("a"); v != "plaintext-value" {
+    t.Fatalf("a = %v after loading, want plaintext-value", v)
+  }
+  if v, _ := r.Get("big"); v != strings.Repeat("x", 3*sealChunk) {
+    t.Fatal("a value spanning several segments did not round-trip")
+  }
+}
+
+//密钥不对或没有密钥时返回 ErrDumpKey，不放入任何数据项
+func TestEncryptedDumpKey(t *testing.T) {
+  file := filepath.Join(t.TempDir(), "dump")
+  c := New(WithPersistenceKey(testKey))
+  c.Set("a", 1)
+  if err := c.SaveToFile(file); err != nil {
+    t.Fatal(err)
+  }
+  for _, r := range []*Cache{New(WithPersistenceKey(bytes.Repeat([]byte{8}, 32))), New()} {
+    if err := r.LoadFile(file); !errors.Is(err, ErrDumpKey) {
+      t.Fatalf("LoadFile = %v, want ErrDumpKey", err)
+    }
+    if r.Count() != 0 {
+      t.Fatalf("Count() = %d after a failed load, want 0", r.Count())
+    }
+  }
+}
+
+//设置了密钥时仍然可以读取没有加密的文件
+func TestEncryptedDumpPlain(t *testing.T) {
+  file := filepath.Join(t.TempDir(), "dump")
+  c := New()
+  c.Set("a", 1)
+  if err := c.SaveToFile(file); err != nil {
+    t.Fatal(err)
+  }
+  r := New(WithPersistenceKey(testKey))
+  if err := r.LoadFile(file); err != nil {
+    t.Fatal(err)
+  }
+  if _, found := r.Get("a"); !found {
+    t.Fatal("a was not loaded from an unencrypted file")
+  }
+}
+
+//截掉结尾的段或修改密文时读取失败
+func TestSealTamper(t *testing.T) {
+  var buf bytes.Buffer
+  w, err := newSealWriter(testKey, &buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  plain := bytes.Repeat([]byte("0123456789"), sealChunk/4)
+  w.Write(plain)
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+  sealed := buf.Bytes()
+  read := func(b []byte) ([]byte, error) {
+    r, err := newOpenReader(testKey, bytes.NewReader(b))
+    if err != nil {
+      return nil, err
+    }
+    return io.ReadAll(r)
+  }
+  if got, err := read(sealed); err != nil || !bytes.Equal(got, plain) {
+    t.Fatalf("reading the sealed data failed: %v", err)
+  }
+  //每段为 4 字节长度加上明文和认证标签，截掉最后一段之后的数据
+  r, _ := newOpenReader(testKey, bytes.NewReader(sealed))
+  segment := 4 + sealChunk + r.aead.Overhead()
+  whole := r.aead.NonceSize() + 2*segment
+  if _, err := read(sealed[:whole]); err == nil {
+    t.Fatal("reading data with the last segment cut off succeeded")
+  }
+  tampered := bytes.Clone(sealed)
+  tampered[len(tampered)-1] ^= 1
+  if _, err := read(tampered); err != errDecrypt {
+    t.Fatalf("reading tampered data = %v, want %v", err, errDecrypt)
+  }
+}
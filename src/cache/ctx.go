@@ -0,0 +1,106 @@
+package cache
+
+import (
+  "context"
+  "errors"
+  "time"
+)
+
+//获取数据项，未找到时返回 ErrNotFound。数据项正在由 GetOrLoad 等调用计算时等待结果，
+//ctx 结束时提前返回 ctx 的错误
+func (c *Cache) GetCtx(ctx context.Context, k string) (interface{}, error) {
+  ctx, end := c.trace(ctx, OpGet, k)
+  v, err := c.getCtx(ctx, k)
+  endGet(end, false, err)
+  return v, err
+}
+
+//结束 OpGet 追踪，computed 表示数据项是加载得到的，未命中不算作错误
+func endGet(end func(bool, error), computed bool, err error) {
+  if errors.Is(err, ErrNotFound) {
+    end(false, nil)
+    return
+  }
+  end(!computed && err == nil, err)
+}
+
+func (c *Cache) getCtx(ctx context.Context, k string) (interface{}, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, err
+  }
+  if v, found := c.Get(k); found {
+    return v, nil
+  }
+  s := c.shard(k)
+  s.mu.RLock()
+  cl, found := s.calls[k]
+  s.mu.RUnlock()
+  if !found {
+    return nil, ErrNotFound
+  }
+  select {
+  case <-cl.done:
+    if cl.err != nil {
+      return nil, cl.err
+    }
+    return c.copyValue(cl.v), nil
+  case <-ctx.Done():
+    return nil, ctx.Err()
+  }
+}
+
+//设置数据项，ctx 已经结束时不写入并返回 ctx 的错误，缓存已关闭时返回 ErrClosed，
+//值超过 WithMaxItemBytes 的上限时返回 *ItemTooLargeError
+func (c *Cache) SetCtx(ctx context.Context, k string, v interface{}, d time.Duration) error {
+  ctx, end := c.trace(ctx, OpSet, k)
+  err := c.setCtx(ctx, k, v, d)
+  end(false, err)
+  return err
+}
+
+func (c *Cache) setCtx(ctx context.Context, k string, v interface{}, d time.Duration) error {
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+  return c.trySet(k, v, d)
+}
+
+//与 Set 相同，返回缓存关闭、冻结和数据项超过大小上限的错误
+func (c *Cache) trySet(k string, v interface{}, d time.Duration) error {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if err := c.writable(); err != nil {
+    return err
+  }
+  return s.set(k, v, d)
+}
+
+//删除数据项，ctx 已经结束时不删除并返回 ctx 的错误
+func (c *Cache) DeleteCtx(ctx context.Context, k string) error {
+  ctx, end := c.trace(ctx, OpDelete, k)
+  err := ctx.Err()
+  if err == nil && c.Frozen() {
+    err = ErrReadOnly
+  }
+  if err == nil {
+    c.Delete(k)
+  }
+  end(false, err)
+  return err
+}
+
+//返回已有的数据项，不存在时调用 loader 加载并以生存时间 d 保存，同一个键的并发调用合并为一次加载。
+//loader 收到的是第一个发起调用的 ctx，其余等待中的调用在自己的 ctx 结束时提前返回
+func (c *Cache) GetOrLoad(ctx context.Context, k string, d time.Duration, loader LoaderFunc) (interface{}, error) {
+  ctx, end := c.trace(ctx, OpGet, k)
+  if err := ctx.Err(); err != nil {
+    end(false, err)
+    return nil, err
+  }
+  v, computed, err := c.getOrCompute(ctx, k, d, func() (interface{}, error) {
+    return c.traceLoad(ctx, k, loader)
+  })
+  endGet(end, computed, err)
+  return v, err
+}
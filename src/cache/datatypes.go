@@ -0,0 +1,172 @@
+package cache
+
+import (
+  "fmt"
+  "sort"
+)
+
+//在写锁内读取并修改一个数据项，保持原有的过期时间和选项，不存在时按默认的过期时间创建。
+//f 返回的值替换原来的值并重新计算成本，返回错误时不修改
+func (c *Cache) modify(k string, f func(v interface{}, found bool) (interface{}, error)) (interface{}, error) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  err := c.writable()
+  if err != nil {
+    return nil, err
+  }
+  item, found := s.items[k]
+  if found && (c.expired(item) || item.Negative) {
+    found = false
+  }
+  var old interface{}
+  if found {
+    if old, err = item.decode(); err != nil {
+      c.corrupt(k, item, err)
+      old, found = nil, false
+    }
+  }
+  nv, err := f(old, found)
+  if err != nil {
+    return nil, err
+  }
+  if !found {
+    return nv, s.set(k, nv, DefaultExpiration)
+  }
+  item.Object, item.Compressed = c.compress(nv)
+  item.Cost = c.costOf(k, item.Object)
+  return nv, s.setItem(k, item)
+}
+
+//保存在缓存中一个键下的 int64 计数器，每次操作在该键所在分片的写锁内完成
+type Counter struct {
+  c   *Cache
+  key string
+}
+
+//返回键 k 上的计数器，数据项不存在时第一次 Add 按默认的过期时间创建
+func (c *Cache) Counter(k string) *Counter {
+  return &Counter{c, k}
+}
+
+//加上 n 并返回新的值，数据项不是 int64 时返回错误
+func (ct *Counter) Add(n int64) (int64, error) {
+  v, err := ct.c.modify(ct.key, func(v interface{}, found bool) (interface{}, error) {
+    if !found {
+      return n, nil
+    }
+    i, ok := v.(int64)
+    if !ok {
+      return nil, fmt.Errorf("The value for %s is not an int64.", ct.key)
+    }
+    return i + n, nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return v.(int64), nil
+}
+
+//返回当前的值，数据项不存在或不是 int64 时返回 0
+func (ct *Counter) Get() int64 {
+  v, _ := ct.c.Get(ct.key)
+  i, _ := v.(int64)
+  return i
+}
+
+//保存在缓存中一个键下的字符串集合，成员按顺序保存为 []string，每次修改复制一份新的切片，
+//之前读到的成员列表不会改变。每次修改在该键所在分片的写锁内完成
+type StringSet struct {
+  c   *Cache
+  key string
+}
+
+//返回键 k 上的字符串集合，数据项不存在时第一次 Add 按默认的过期时间创建
+func (c *Cache) StringSet(k string) *StringSet {
+  return &StringSet{c, k}
+}
+
+func (ss *StringSet) members(v interface{}, found bool) ([]string, error) {
+  if !found {
+    return nil, nil
+  }
+  m, ok := v.([]string)
+  if !ok {
+    return nil, fmt.Errorf("The value for %s is not a string set.", ss.key)
+  }
+  return m, nil
+}
+
+//加入成员，返回新加入的数量
+func (ss *StringSet) Add(members ...string) (int, error) {
+  added := 0
+  _, err := ss.c.modify(ss.key, func(v interface{}, found bool) (interface{}, error) {
+    m, err := ss.members(v, found)
+    if err != nil {
+      return nil, err
+    }
+    nm := append([]string(nil), m...)
+    for _, x := range members {
+      i := sort.SearchStrings(nm, x)
+      if i < len(nm) && nm[i] == x {
+        continue
+      }
+      nm = append(nm, "")
+      copy(nm[i+1:], nm[i:])
+      nm[i] = x
+      added++
+    }
+    return nm, nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return added, nil
+}
+
+//移除成员，返回移除的数量，集合为空时保留数据项
+func (ss *StringSet) Remove(members ...string) (int, error) {
+  removed := 0
+  _, err := ss.c.modify(ss.key, func(v interface{}, found bool) (interface{}, error) {
+    m, err := ss.members(v, found)
+    if err != nil {
+      return nil, err
+    }
+    drop := make(map[string]bool, len(members))
+    for _, x := range members {
+      drop[x] = true
+    }
+    nm := make([]string, 0, len(m))
+    for _, x := range m {
+      if drop[x] {
+        removed++
+        continue
+      }
+      nm = append(nm, x)
+    }
+    return nm, nil
+  })
+  if err != nil {
+    return 0, err
+  }
+  return removed, nil
+}
+
+//判断是否包含成员
+func (ss *StringSet) Contains(member string) bool {
+  m := ss.Members()
+  i := sort.SearchStrings(m, member)
+  return i < len(m) && m[i] == member
+}
+
+//返回按顺序排列的全部成员，调用方不能修改。数据项不存在或不是字符串集合时返回 nil
+func (ss *StringSet) Members() []string {
+  v, _ := ss.c.Get(ss.key)
+  m, _ := v.([]string)
+  return m
+}
+
+//返回成员的数量
+func (ss *StringSet) Len() int {
+  return len(ss.Members())
+}
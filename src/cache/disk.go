@@ -0,0 +1,459 @@
+package cache
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+)
+
+//磁盘层，缓存因容量上限淘汰的数据项写入目录中的文件，Get 未命中时从磁盘读回内存。
+//每个数据项一个文件，按键的哈希分到 256 个子目录，哈希相同的键使用带序号的文件名，读回后从磁盘删除。
+//文件由后台 goroutine 写入，写完之前的数据项保留在内存中，不再使用时调用 Close
+type DiskTier struct {
+  dir   string
+  mu    sync.Mutex
+  wmu   sync.Mutex  // 串行化文件的写入和删除
+  index map[string]diskEntry  // 磁盘上的数据项
+  files map[string]string  // 文件路径到键
+  gen   uint64
+
+  qmu    sync.Mutex
+  queue  []diskJob  // 等待后台写入和删除的文件
+  wake   chan struct{}
+  done   chan struct{}  // 后台 goroutine 退出后关闭
+  closed bool
+}
+
+//gen 用于判断写完文件时数据项是否已经被删除或再次写入
+type diskEntry struct {
+  gen        uint64
+  expiration int64
+  created    int64
+  path       string
+  pending    *Item  // 还没有写入文件的数据项
+}
+
+//缓存淘汰的数据项，在锁外写入磁盘
+type spill struct {
+  key  string
+  item Item
+  gen  uint64
+}
+
+//一次解锁后需要写入的数据项和删除的文件，先删除再写入
+type diskJob struct {
+  c      *Cache
+  spills []spill
+  drops  []string
+}
+
+//打开目录作为磁盘层，目录不存在时创建。目录中已有的数据项重新建立索引，无法读取的文件被删除
+func OpenDiskTier(dir string) (*DiskTier, error) {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return nil, err
+  }
+  d := &DiskTier {
+    dir: dir,
+    index: map[string]diskEntry{},
+    files: map[string]string{},
+    wake: make(chan struct{}, 1),
+    done: make(chan struct{}),
+  }
+  err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    if err != nil || info.IsDir() {
+      return err
+    }
+    if strings.HasPrefix(info.Name(), ".tmp") {
+      return os.Remove(path)
+    }
+    k, item, err := d.read(path)
+    if err != nil || !d.owns(k, path) {
+      return os.Remove(path)
+    }
+    if _, found := d.index[k]; found {
+      return os.Remove(path)
+    }
+    d.gen++
+    d.index[k] = diskEntry{d.gen, item.Expiration, item.Created, path, nil}
+    d.files[path] = k
+    return nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  go d.loop()
+  return d, nil
+}
+
+//等待已经淘汰的数据项写完后停止后台 goroutine，之后淘汰的数据项在调用者的 goroutine 中写入。可以重复调用
+func (d *DiskTier) Close() error {
+  d.qmu.Lock()
+  if d.closed {
+    d.qmu.Unlock()
+    return nil
+  }
+  d.closed = true
+  close(d.wake)
+  d.qmu.Unlock()
+  <-d.done
+  return nil
+}
+
+//返回磁盘上数据项的数量，可能包括已经过期但还没有清理的数据项
+func (d *DiskTier) Len() int {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  return len(d.index)
+}
+
+func (d *DiskTier) path(k string) string {
+  name := fmt.Sprintf("%016x", keyHash(k))
+  return filepath.Join(d.dir, name[:2], name)
+}
+
+//判断文件名是否属于键 k，哈希相同的键在文件名后加上 -序号
+func (d *DiskTier) owns(k, path string) bool {
+  base := d.path(k)
+  return path == base || strings.HasPrefix(path, base+"-")
+}
+
+//为没有文件的键选择一个没有被其他键使用的文件，需要持有 d.mu
+func (d *DiskTier) pick(k string) string {
+  base := d.path(k)
+  path := base
+  for i := 1; ; i++ {
+    if _, used := d.files[path]; !used {
+      return path
+    }
+    path = fmt.Sprintf("%s-%d", base, i)
+  }
+}
+
+//读取文件中唯一的数据项
+func (d *DiskTier) read(path string) (string, Item, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return "", Item{}, err
+  }
+  defer f.Close()
+  items, err := GobSerializer{}.Decode(f)
+  if err != nil {
+    return "", Item{}, err
+  }
+  if len(items) != 1 {
+    return "", Item{}, fmt.Errorf("Disk file %s holds %d items.", path, len(items))
+  }
+  for k, item := range items {
+    return k, item, nil
+  }
+  panic("unreachable")
+}
+
+//在索引中登记即将写入的数据项，需要持有数据项所在分片的写锁
+func (d *DiskTier) reserve(k string, item Item) uint64 {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  e, found := d.index[k]
+  path := e.path
+  if !found {
+    path = d.pick(k)
+  }
+  d.gen++
+  d.index[k] = diskEntry{d.gen, item.Expiration, item.Created, path, &item}
+  d.files[path] = k
+  return d.gen
+}
+
+//从索引中删除数据项，返回磁盘上是否有该数据项和它的文件，文件由调用者在锁外删除
+func (d *DiskTier) forget(k string) (string, bool) {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  e, found := d.index[k]
+  if found {
+    d.unindex(k, e)
+  }
+  return e.path, found
+}
+
+//需要持有 d.mu
+func (d *DiskTier) unindex(k string, e diskEntry) {
+  delete(d.index, k)
+  if d.files[e.path] == k {
+    delete(d.files, e.path)
+  }
+}
+
+//放入后台写入的队列，关闭后直接写入
+func (d *DiskTier) enqueue(job diskJob) {
+  d.qmu.Lock()
+  if d.closed {
+    d.qmu.Unlock()
+    d.run(job)
+    return
+  }
+  d.queue = append(d.queue, job)
+  d.qmu.Unlock()
+  select {
+  case d.wake <- struct{}{}:
+  default:
+  }
+}
+
+func (d *DiskTier) loop() {
+  defer close(d.done)
+  for range d.wake {
+    d.flush()
+  }
+  d.flush()
+}
+
+//按顺序执行队列中的全部任务
+func (d *DiskTier) flush() {
+  for {
+    d.qmu.Lock()
+    jobs := d.queue
+    d.queue = nil
+    d.qmu.Unlock()
+    if len(jobs) == 0 {
+      return
+    }
+    for _, job := range jobs {
+      d.run(job)
+    }
+  }
+}
+
+func (d *DiskTier) run(job diskJob) {
+  for _, path := range job.drops {
+    d.drop(path)
+  }
+  for _, sp := range job.spills {
+    if err := d.write(sp); err != nil {
+      job.c.log().Warnf("Spilling item %s to disk failed: %v", sp.key, err)
+    }
+  }
+}
+
+//写入文件，多次写入串行执行，写入前或写入期间数据项被删除或再次淘汰时放弃这次写入
+func (d *DiskTier) write(sp spill) error {
+  d.wmu.Lock()
+  defer d.wmu.Unlock()
+  d.mu.Lock()
+  e, current := d.current(sp)
+  d.mu.Unlock()
+  if !current {
+    return nil
+  }
+  path := e.path
+  err := os.MkdirAll(filepath.Dir(path), 0755)
+  if err == nil {
+    err = writeFileAtomic(path, func(f *os.File) error {
+      return GobSerializer{}.Encode(f, map[string]Item{sp.key: sp.item})
+    })
+  }
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  e, current = d.current(sp)
+  if err != nil {
+    if current {
+      d.unindex(sp.key, e)
+    }
+    return err
+  }
+  if current {
+    e.pending = nil
+    d.index[sp.key] = e
+  } else if owner, used := d.files[path]; !used || owner == sp.key {
+    //数据项已经被删除，或者同一个键有更新的值等待写入
+    os.Remove(path)
+  }
+  return nil
+}
+
+//需要持有 d.mu
+func (d *DiskTier) current(sp spill) (diskEntry, bool) {
+  e, found := d.index[sp.key]
+  return e, found && e.gen == sp.gen
+}
+
+//删除已经从索引中删除的数据项的文件，与写入串行执行，文件已经被其他数据项使用时不删除
+func (d *DiskTier) drop(path string) {
+  d.wmu.Lock()
+  defer d.wmu.Unlock()
+  d.mu.Lock()
+  _, used := d.files[path]
+  d.mu.Unlock()
+  if !used {
+    os.Remove(path)
+  }
+}
+
+//先写入同一目录下的临时文件再重命名，读取者不会看到写了一半的文件
+func writeFileAtomic(path string, write func(*os.File) error) error {
+  f, err := os.CreateTemp(filepath.Dir(path), ".tmp")
+  if err != nil {
+    return err
+  }
+  if err = write(f); err != nil {
+    f.Close()
+    os.Remove(f.Name())
+    return err
+  }
+  if err = f.Close(); err != nil {
+    os.Remove(f.Name())
+    return err
+  }
+  return os.Rename(f.Name(), path)
+}
+
+//读取数据项但不从磁盘删除，返回登记时的 gen，还没有写入文件的数据项从内存中返回。
+//不存在、已经过期或文件无法读取时返回 false，过期或损坏的文件被删除，
+//文件中的键与 k 不同时只从索引中删除 k，不删除文件
+func (d *DiskTier) peek(k string, now int64) (Item, uint64, bool) {
+  d.mu.Lock()
+  e, found := d.index[k]
+  d.mu.Unlock()
+  if !found {
+    return Item{}, 0, false
+  }
+  if e.expiration > 0 && now > e.expiration {
+    d.release(k, e.gen)
+    return Item{}, 0, false
+  }
+  if e.pending != nil {
+    return *e.pending, e.gen, true
+  }
+  rk, item, err := d.read(e.path)
+  if os.IsNotExist(err) {
+    return Item{}, 0, false
+  }
+  if err == nil && rk != k {
+    d.mu.Lock()
+    if cur, found := d.index[k]; found && cur.gen == e.gen {
+      d.unindex(k, cur)
+    }
+    d.mu.Unlock()
+    return Item{}, 0, false
+  }
+  if err != nil {
+    d.release(k, e.gen)
+    return Item{}, 0, false
+  }
+  return item, e.gen, true
+}
+
+//读回内存后从磁盘删除，期间被删除或再次淘汰时不做处理，与写入串行执行
+func (d *DiskTier) release(k string, gen uint64) {
+  d.wmu.Lock()
+  defer d.wmu.Unlock()
+  d.mu.Lock()
+  e, found := d.index[k]
+  current := found && e.gen == gen
+  if current {
+    d.unindex(k, e)
+  }
+  d.mu.Unlock()
+  if current {
+    os.Remove(e.path)
+  }
+}
+
+//删除磁盘上已经过期的数据项，返回删除的数量
+func (d *DiskTier) deleteExpired(now int64) int {
+  return d.deleteIf(func(e diskEntry) bool {
+    return e.expiration > 0 && now > e.expiration
+  })
+}
+
+//删除 match 返回 true 的数据项，返回删除的数量
+func (d *DiskTier) deleteIf(match func(e diskEntry) bool) int {
+  var paths []string
+  d.mu.Lock()
+  for k, e := range d.index {
+    if match(e) {
+      paths = append(paths, e.path)
+      d.unindex(k, e)
+    }
+  }
+  d.mu.Unlock()
+  for _, path := range paths {
+    d.drop(path)
+  }
+  return len(paths)
+}
+
+//删除磁盘上全部的数据项
+func (d *DiskTier) clear() {
+  d.mu.Lock()
+  paths := make([]string, 0, len(d.index))
+  for _, e := range d.index {
+    paths = append(paths, e.path)
+  }
+  d.index = map[string]diskEntry{}
+  d.files = map[string]string{}
+  d.mu.Unlock()
+  for _, path := range paths {
+    d.drop(path)
+  }
+}
+
+//设置磁盘层，因容量上限被淘汰的数据项写入磁盘，Get 未命中时从磁盘读回。nil 表示关闭，
+//已经写入磁盘的数据项保留在目录中。临时数据项和负缓存不会写入磁盘
+func (c *Cache) SetDiskTier(d *DiskTier) {
+  c.disk.Store(d)
+}
+
+//在锁外从磁盘读回数据项并放入内存，内存中已经有新值时不覆盖。放入内存后才从磁盘删除，
+//缓存已冻结时返回磁盘上的值但保留在磁盘上，已关闭时返回 false。无法解压的数据项从磁盘删除
+func (c *Cache) fromDisk(k string) (interface{}, bool) {
+  d := c.disk.Load()
+  if d == nil {
+    return nil, false
+  }
+  item, gen, found := d.peek(k, c.now())
+  if !found {
+    return nil, false
+  }
+  v, err := item.decode()
+  if err != nil {
+    c.log().Warnf("Item %s on disk can't be decompressed and is dropped: %v", k, err)
+    d.release(k, gen)
+    return nil, false
+  }
+  if err := c.load(map[string]Item{k: item}, KeepExisting); err != nil {
+    if errors.Is(err, ErrClosed) {
+      return nil, false
+    }
+    return v, true
+  }
+  d.release(k, gen)
+  return v, true
+}
+
+//交给后台 goroutine 先删除旧文件再写入新淘汰的数据项，同一个键可能先被删除再被淘汰
+func (c *Cache) writeDisk(spills []spill, drops []string) {
+  if d := c.disk.Load(); d != nil {
+    d.enqueue(diskJob{c, spills, drops})
+  }
+}
+
+//被淘汰的数据项登记到磁盘层，在解锁后写入，需要持有写锁
+func (s *shard) spill(k string, item Item) {
+  d := s.c.disk.Load()
+  if d == nil || item.Negative || transient(item) || s.c.expired(item) {
+    return
+  }
+  s.spills = append(s.spills, spill{k, item, d.reserve(k, item)})
+}
+
+//数据项被删除或覆盖时从磁盘层删除旧值，需要持有写锁
+func (s *shard) unspill(k string) {
+  if d := s.c.disk.Load(); d != nil {
+    if path, found := d.forget(k); found {
+      s.diskDrops = append(s.diskDrops, path)
+    }
+  }
+}
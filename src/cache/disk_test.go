@@ -0,0 +1,203 @@
+package cache
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+//返回容量为一个数据项的缓存，b 写入后 a 被淘汰到磁盘
+func spilled(t *testing.T) (*Cache, *DiskTier) {
+  t.Helper()
+  d, err := OpenDiskTier(t.TempDir())
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { d.Close() })
+  c := New(WithMaxEntries(1), WithDiskTier(d))
+  c.Set("a", "va")
+  c.Set("b", "vb")
+  if d.Len() != 1 {
+    t.Fatalf("disk holds %d items, want 1", d.Len())
+  }
+  return c, d
+}
+
+func TestDiskReadBack(t *testing.T) {
+  c, d := spilled(t)
+  if v, found := c.Get("a"); !found || v != "va" {
+    t.Fatalf("Get(a) = %v, %v", v, found)
+  }
+  if d.Len() != 1 {
+    t.Fatalf("disk holds %d items after reading a back, want 1 (b)", d.Len())
+  }
+}
+
+//冻结或关闭的缓存不能放入读回的数据项，磁盘上的数据项不能丢失
+func TestDiskReadBackFrozen(t *testing.T) {
+  c, d := spilled(t)
+  c.Freeze()
+  if v, found := c.Get("a"); !found || v != "va" {
+    t.Fatalf("Get(a) on a frozen cache = %v, %v", v, found)
+  }
+  if d.Len() != 1 {
+    t.Fatalf("disk holds %d items, want 1", d.Len())
+  }
+  c.Thaw()
+  if v, found := c.Get("a"); !found || v != "va" {
+    t.Fatalf("Get(a) after Thaw = %v, %v", v, found)
+  }
+}
+
+func TestDiskReadBackClosed(t *testing.T) {
+  c, d := spilled(t)
+  c.Close()
+  if _, found := c.Get("a"); found {
+    t.Fatal("Get(a) on a closed cache found the item")
+  }
+  if d.Len() != 1 {
+    t.Fatalf("disk holds %d items, want 1", d.Len())
+  }
+}
+
+//写入的文件在 Close 后可以由新打开的磁盘层读回
+func TestDiskReopen(t *testing.T) {
+  dir := t.TempDir()
+  d, err := OpenDiskTier(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := New(WithShards(1), WithMaxEntries(1), WithDiskTier(d))
+  c.Set("a", "va")
+  c.Set("b", "vb")
+  d.Close()
+  d, err = OpenDiskTier(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer d.Close()
+  if d.Len() != 1 {
+    t.Fatalf("reopened disk holds %d items, want 1", d.Len())
+  }
+  c = New(WithDiskTier(d))
+  if v, found := c.Get("a"); !found || v != "va" {
+    t.Fatalf("Get(a) = %v, %v", v, found)
+  }
+}
+
+//哈希相同的键使用不同的文件，读回一个键不会删除另一个键的文件
+func TestDiskCollision(t *testing.T) {
+  d, err := OpenDiskTier(t.TempDir())
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer d.Close()
+  //模拟一个与 b 哈希相同的键，它的文件占用了 b 的默认文件名
+  base := d.path("b")
+  if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+    t.Fatal(err)
+  }
+  err = writeFileAtomic(base, func(f *os.File) error {
+    return GobSerializer{}.Encode(f, map[string]Item{"other": {Object: "vo"}})
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  d.mu.Lock()
+  d.gen++
+  d.index["other"] = diskEntry{gen: d.gen, path: base}
+  d.files[base] = "other"
+  d.mu.Unlock()
+
+  c := New(WithShards(1), WithMaxEntries(1), WithDiskTier(d))
+  c.Set("b", "vb")
+  c.Set("c", "vc")
+  d.mu.Lock()
+  path := d.index["b"].path
+  d.mu.Unlock()
+  if path == base || !d.owns("b", path) {
+    t.Fatalf("b was spilled to %s, want a numbered file next to %s", path, base)
+  }
+  if v, found := c.Get("b"); !found || v != "vb" {
+    t.Fatalf("Get(b) = %v, %v", v, found)
+  }
+  if item, _, found := d.peek("other", c.now()); !found || item.Object != "vo" {
+    t.Fatalf("peek(other) = %v, %v after reading b back", item.Object, found)
+  }
+}
+
+//返回目录中文件的数量
+func countFiles(t *testing.T, dir string) int {
+  t.Helper()
+  n := 0
+  err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    if err == nil && !info.IsDir() {
+      n++
+    }
+    return err
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  return n
+}
+
+//删除、覆盖和清空时磁盘上的旧值也被删除
+func TestDiskRemove(t *testing.T) {
+  dir := t.TempDir()
+  d, err := OpenDiskTier(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := New(WithShards(1), WithMaxEntries(1), WithDiskTier(d))
+  c.Set("a", "va")
+  c.Set("b", "vb")
+  c.Delete("a")
+  if _, found := c.Get("a"); found || d.Len() != 0 {
+    t.Fatalf("Get(a) after Delete = %v, disk holds %d items, want a miss and an empty disk", found, d.Len())
+  }
+  c.Set("c", "vc")
+  c.Set("b", "new")
+  if v, _ := c.Get("b"); v != "new" {
+    t.Fatalf("Get(b) = %v after overwriting a spilled item, want new", v)
+  }
+  c.Set("x", "vx")
+  c.Flush()
+  if d.Len() != 0 {
+    t.Fatalf("disk holds %d items after Flush, want 0", d.Len())
+  }
+  d.Close()
+  if n := countFiles(t, dir); n != 0 {
+    t.Fatalf("%d files left on disk, want 0", n)
+  }
+}
+
+//磁盘上过期的数据项不会读回，过期清理时从磁盘删除
+func TestDiskExpired(t *testing.T) {
+  dir := t.TempDir()
+  d, err := OpenDiskTier(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer d.Close()
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := New(WithClock(clock), WithShards(1), WithMaxEntries(1), WithDiskTier(d), WithGCInterval(0))
+  c.Set("short", 1, WithTTL(time.Second))
+  c.Set("long", 2, WithTTL(time.Hour))
+  c.Set("x", 3)
+  if d.Len() != 2 {
+    t.Fatalf("disk holds %d items, want 2", d.Len())
+  }
+  clock.Advance(time.Minute)
+  c.DeleteExpired()
+  if d.Len() != 1 {
+    t.Fatalf("disk holds %d items after DeleteExpired, want 1", d.Len())
+  }
+  if _, found := c.Get("short"); found {
+    t.Fatal("an expired item was read back from disk")
+  }
+  if v, found := c.Get("long"); !found || v != 2 {
+    t.Fatalf("Get(long) = %v, %v, want 2", v, found)
+  }
+}
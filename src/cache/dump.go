@@ -0,0 +1,242 @@
+package cache
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "hash/crc32"
+  "io"
+  "os"
+  "reflect"
+  "sync"
+)
+
+//转储文件的格式版本
+const dumpVersion = 1
+
+//转储文件头：magic、版本、编码、标志、数据项数量、数据长度、数据的 CRC32
+const dumpHeaderSize = 8 + 2 + 1 + 1 + 8 + 8 + 4
+
+var dumpMagic = []byte("GOCACHE\x00")
+
+//文件头中的标志
+const dumpEncrypted byte = 1
+
+//文件头中的编码，3 由 cache/msgpackcodec 注册
+const (
+  codecGob byte = iota + 1
+  codecJSON
+)
+
+//用 RegisterDumpSerializer 注册的序列化方式
+var (
+  dumpCodecsMu sync.RWMutex
+  dumpCodecs   = map[reflect.Type]byte{}
+  dumpSerializers = map[byte]Serializer{}
+)
+
+//注册可以用于 SaveToFileWith 的序列化方式，codec 写入文件头，LoadFile 据此选择序列化方式。
+//gob 和 JSON 不需要注册，codec 已被使用时返回错误，通常在 init 中调用
+func RegisterDumpSerializer(codec byte, s Serializer) error {
+  dumpCodecsMu.Lock()
+  defer dumpCodecsMu.Unlock()
+  if codec == 0 || codec == codecGob || codec == codecJSON || dumpSerializers[codec] != nil {
+    return fmt.Errorf("Dump codec %d is already in use.", codec)
+  }
+  t := reflect.TypeOf(s)
+  if t.Kind() == reflect.Ptr {
+    t = t.Elem()
+  }
+  dumpCodecs[t], dumpCodecs[reflect.PointerTo(t)] = codec, codec
+  dumpSerializers[codec] = s
+  return nil
+}
+
+//转储文件损坏，例如被截断或校验和不一致
+var ErrDumpCorrupt = errors.New("Dump file is corrupt.")
+
+//转储文件的版本或编码不支持
+var ErrDumpVersion = errors.New("Dump file version is not supported.")
+
+//读取转储文件失败时返回的错误，Err 为 ErrDumpCorrupt、ErrDumpVersion 或 ErrDumpKey
+type DumpError struct {
+  File   string
+  Reason string
+  Err    error
+}
+
+func (e *DumpError) Error() string {
+  return fmt.Sprintf("Dump file %s is invalid: %s.", e.File, e.Reason)
+}
+
+func (e *DumpError) Unwrap() error {
+  return e.Err
+}
+
+type dumpHeader struct {
+  version uint16
+  codec   byte
+  flags   byte
+  count   uint64
+  length  uint64
+  crc     uint32
+}
+
+func (h dumpHeader) marshal() []byte {
+  b := make([]byte, dumpHeaderSize)
+  copy(b, dumpMagic)
+  binary.BigEndian.PutUint16(b[8:], h.version)
+  b[10], b[11] = h.codec, h.flags
+  binary.BigEndian.PutUint64(b[12:], h.count)
+  binary.BigEndian.PutUint64(b[20:], h.length)
+  binary.BigEndian.PutUint32(b[28:], h.crc)
+  return b
+}
+
+func unmarshalHeader(b []byte) dumpHeader {
+  return dumpHeader {
+    version: binary.BigEndian.Uint16(b[8:]),
+    codec: b[10],
+    flags: b[11],
+    count: binary.BigEndian.Uint64(b[12:]),
+    length: binary.BigEndian.Uint64(b[20:]),
+    crc: binary.BigEndian.Uint32(b[28:]),
+  }
+}
+
+func codecOf(s Serializer) byte {
+  switch s.(type) {
+  case GobSerializer, *GobSerializer:
+    return codecGob
+  case JSONSerializer, *JSONSerializer:
+    return codecJSON
+  }
+  dumpCodecsMu.RLock()
+  defer dumpCodecsMu.RUnlock()
+  return dumpCodecs[reflect.TypeOf(s)]
+}
+
+func serializerOf(codec byte) Serializer {
+  switch codec {
+  case codecGob:
+    return GobSerializer{}
+  case codecJSON:
+    return JSONSerializer{}
+  }
+  dumpCodecsMu.RLock()
+  defer dumpCodecsMu.RUnlock()
+  return dumpSerializers[codec]
+}
+
+//统计写入的字节数并计算 CRC32
+type checksumWriter struct {
+  w   io.Writer
+  n   uint64
+  crc uint32
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+  n, err := w.w.Write(p)
+  w.n += uint64(n)
+  w.crc = crc32.Update(w.crc, crc32.IEEETable, p[:n])
+  return n, err
+}
+
+//先写入占位的文件头，数据写完后再回填数量、长度和校验和
+func (c *Cache) writeDump(f *os.File, s Serializer) error {
+  h := dumpHeader{version: dumpVersion, codec: codecOf(s)}
+  if h.codec == 0 {
+    return fmt.Errorf("Serializer %T can't be used for dump files.", s)
+  }
+  if _, err := f.Write(h.marshal()); err != nil {
+    return err
+  }
+  c.shards[0].mu.RLock()
+  key := c.persistKey
+  c.shards[0].mu.RUnlock()
+  cw := &checksumWriter{w: f}
+  var out io.Writer = cw
+  var sw *sealWriter
+  if key != nil {
+    var err error
+    if sw, err = newSealWriter(key, cw); err != nil {
+      return err
+    }
+    h.flags |= dumpEncrypted
+    out = sw
+  }
+  bw := bufio.NewWriter(out)
+  n, err := c.save(bw, s)
+  if err == nil {
+    err = bw.Flush()
+  }
+  if err == nil && sw != nil {
+    err = sw.Close()
+  }
+  if err != nil {
+    return err
+  }
+  h.count, h.length, h.crc = uint64(n), cw.n, cw.crc
+  _, err = f.WriteAt(h.marshal(), 0)
+  return err
+}
+
+//先完整读取并校验数据，通过后才放入缓存，损坏的文件不会留下部分数据
+func (c *Cache) readDump(r io.Reader, file string) error {
+  br := bufio.NewReader(r)
+  if magic, _ := br.Peek(len(dumpMagic)); !bytes.Equal(magic, dumpMagic) {
+    return c.Load(br)
+  }
+  b := make([]byte, dumpHeaderSize)
+  if _, err := io.ReadFull(br, b); err != nil {
+    return &DumpError{File: file, Reason: "truncated header", Err: ErrDumpCorrupt}
+  }
+  h := unmarshalHeader(b)
+  if h.version != dumpVersion {
+    return &DumpError{File: file, Reason: fmt.Sprintf("unsupported version %d", h.version), Err: ErrDumpVersion}
+  }
+  sr := serializerOf(h.codec)
+  if sr == nil {
+    return &DumpError{File: file, Reason: fmt.Sprintf("unsupported codec %d", h.codec), Err: ErrDumpVersion}
+  }
+  c.shards[0].mu.RLock()
+  key := c.persistKey
+  c.shards[0].mu.RUnlock()
+  cw := &checksumWriter{w: io.Discard}
+  payload := io.TeeReader(io.LimitReader(br, int64(h.length)), cw)
+  var in io.Reader = payload
+  var or *openReader
+  var items map[string]Item
+  var err error
+  if h.flags & dumpEncrypted != 0 {
+    if key == nil {
+      return &DumpError{File: file, Reason: "encrypted but no persistence key is set", Err: ErrDumpKey}
+    }
+    //密钥长度不对时直接返回，不算文件损坏
+    if or, err = newOpenReader(key, payload); err != nil && err != io.ErrUnexpectedEOF {
+      return err
+    }
+    in = or
+  }
+  if err == nil {
+    items, err = sr.Decode(in)
+  }
+  if _, cerr := io.Copy(io.Discard, payload); err == nil {
+    err = cerr
+  }
+  switch {
+  case cw.n != h.length:
+    return &DumpError{File: file, Reason: fmt.Sprintf("truncated, read %d of %d bytes", cw.n, h.length), Err: ErrDumpCorrupt}
+  case cw.crc != h.crc:
+    return &DumpError{File: file, Reason: "checksum mismatch", Err: ErrDumpCorrupt}
+  case or != nil && or.failed:
+    return &DumpError{File: file, Reason: "decryption failed", Err: ErrDumpKey}
+  case err != nil:
+    return &DumpError{File: file, Reason: err.Error(), Err: ErrDumpCorrupt}
+  case uint64(len(items)) != h.count:
+    return &DumpError{File: file, Reason: fmt.Sprintf("expected %d items, got %d", h.count, len(items)), Err: ErrDumpCorrupt}
+  }
+  return c.load(items, nil)
+}
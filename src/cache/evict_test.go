@@ -0,0 +1,216 @@
+package cache
+
+import (
+  "fmt"
+  "testing"
+  "time"
+)
+
+//没有访问顺序时，超出上限先淘汰已过期的数据项，按过期时间从早到晚
+func TestEvictExpiredFirst(t *testing.T) {
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := New(WithClock(clock), WithShards(1), WithMaxEntries(4), WithGCInterval(0))
+  c.Set("live1", 1, WithTTL(NoExpiration))
+  c.Set("old", 2, WithTTL(time.Second))
+  c.Set("older", 3, WithTTL(time.Millisecond))
+  c.Set("live2", 4, WithTTL(NoExpiration))
+  clock.Advance(2 * time.Second)
+  c.Set("new1", 5)
+  if c.Count() != 4 {
+    t.Fatalf("Count() = %d, want 4", c.Count())
+  }
+  //过期的数据项在 Get 中不可见，直接检查分片
+  if _, found := c.shard("older").items["older"]; found {
+    t.Fatal("the item that expired first was not evicted first")
+  }
+  if _, found := c.shard("old").items["old"]; !found {
+    t.Fatal("more items than needed were evicted")
+  }
+  c.Set("new2", 6)
+  if _, found := c.shard("old").items["old"]; found {
+    t.Fatal("the expired item was not evicted")
+  }
+  for _, k := range []string{"live1", "live2", "new1", "new2"} {
+    if _, found := c.Get(k); !found {
+      t.Fatalf("live item %s was evicted while an expired one remained", k)
+    }
+  }
+}
+
+//每次写入只淘汰需要的数量
+func TestEvictOverflowCount(t *testing.T) {
+  c := New(WithMaxEntries(100))
+  for i := 0; i < 1000; i++ {
+    c.Set(fmt.Sprintf("k%d", i), i)
+    if c.Count() > 100 {
+      t.Fatalf("Count() = %d after %d writes, want at most 100", c.Count(), i+1)
+    }
+  }
+  if c.Count() != 100 {
+    t.Fatalf("Count() = %d, want 100", c.Count())
+  }
+}
+
+//返回 keys 中仍在缓存中的键
+func present(c *Cache, keys ...string) []string {
+  var found []string
+  for _, k := range keys {
+    if _, ok := c.shard(k).items[k]; ok {
+      found = append(found, k)
+    }
+  }
+  return found
+}
+
+//LRU 模式下先淘汰低优先级的数据项，同一优先级内按最久未使用的顺序，固定的数据项不被淘汰
+func TestEvictPriorityLRU(t *testing.T) {
+  c := NewCacheWithLRU(5, NoExpiration, 0)
+  c.Set("n1", 1)
+  c.Set("h1", 2, WithPriority(PriorityHigh))
+  c.Set("p1", 3, WithPinned(), WithPriority(PriorityLow))
+  c.Set("l1", 4, WithPriority(PriorityLow))
+  c.Set("n2", 5)
+  want := [][]string{
+    {"n1", "h1", "p1", "n2", "x1"},
+    {"h1", "p1", "n2", "x1", "x2"},
+    {"h1", "p1", "x1", "x2", "x3"},
+    {"h1", "p1", "x2", "x3", "x4"},
+  }
+  all := []string{"n1", "h1", "p1", "l1", "n2", "x1", "x2", "x3", "x4"}
+  for i, w := range want {
+    c.Set(fmt.Sprintf("x%d", i+1), i)
+    if got := present(c, all...); fmt.Sprint(got) != fmt.Sprint(w) {
+      t.Fatalf("after writing x%d: %v, want %v", i+1, got, w)
+    }
+  }
+}
+
+//一次淘汰多个数据项时，低优先级的全部淘汰后再按顺序淘汰之前跳过的数据项
+func TestEvictPriorityBatch(t *testing.T) {
+  for _, opt := range []Option{WithLRU(), WithARC()} {
+    c := New(WithShards(1), opt)
+    c.Set("h1", 1, WithCost(1), WithPriority(PriorityHigh))
+    c.Set("n1", 2, WithCost(1))
+    c.Set("l1", 3, WithCost(1), WithPriority(PriorityLow))
+    c.Set("n2", 4, WithCost(1))
+    c.Set("l2", 5, WithCost(1), WithPriority(PriorityLow))
+    c.Set("h2", 6, WithCost(1), WithPriority(PriorityHigh))
+    c.SetMaxCost(3)
+    if got := present(c, "h1", "n1", "l1", "n2", "l2", "h2"); fmt.Sprint(got) != "[h1 n2 h2]" {
+      t.Fatalf("remaining items %v, want [h1 n2 h2]", got)
+    }
+    c.SetMaxCost(1)
+    if got := present(c, "h1", "n2", "h2"); fmt.Sprint(got) != "[h2]" {
+      t.Fatalf("remaining items %v, want [h2]", got)
+    }
+  }
+}
+
+//没有访问顺序时也按优先级淘汰
+func TestEvictPriorityUnordered(t *testing.T) {
+  c := New(WithShards(1), WithMaxEntries(3))
+  c.Set("h1", 1, WithPriority(PriorityHigh))
+  c.Set("n1", 2)
+  c.Set("l1", 3, WithPriority(PriorityLow))
+  c.Set("x", 4)
+  if got := present(c, "h1", "n1", "l1", "x"); fmt.Sprint(got) != "[h1 n1 x]" {
+    t.Fatalf("remaining items %v, want [h1 n1 x]", got)
+  }
+  c.Set("y", 5, WithPriority(PriorityHigh))
+  if got := present(c, "h1", "n1", "x", "y"); len(got) != 3 || got[0] != "h1" || got[2] != "y" {
+    t.Fatalf("remaining items %v, want h1, y and one of n1 and x", got)
+  }
+}
+
+//LRU 模式下读写都会更新访问顺序，先淘汰最久未使用的数据项
+func TestEvictLRUOrder(t *testing.T) {
+  c := NewCacheWithLRU(3, NoExpiration, 0)
+  c.Set("a", 1)
+  c.Set("b", 2)
+  c.Set("c", 3)
+  c.Get("a")
+  c.Set("d", 4)
+  if got := present(c, "a", "b", "c", "d"); fmt.Sprint(got) != "[a c d]" {
+    t.Fatalf("remaining items %v, want [a c d]", got)
+  }
+  c.Set("c", 5)
+  c.Set("e", 6)
+  if got := present(c, "a", "c", "d", "e"); fmt.Sprint(got) != "[c d e]" {
+    t.Fatalf("remaining items %v, want [c d e]", got)
+  }
+}
+
+//ARC 模式下访问过多次的数据项不会被一次性扫描挤出去，LRU 模式下会
+func TestEvictARCScan(t *testing.T) {
+  for _, tt := range []struct {
+    opt  Option
+    keep bool
+  }{{WithLRU(), false}, {WithARC(), true}} {
+    c := New(WithShards(1), WithMaxEntries(3), tt.opt)
+    c.Set("hot", 1)
+    c.Get("hot")
+    c.Set("a", 2)
+    c.Set("b", 3)
+    for i := 0; i < 5; i++ {
+      c.Set(fmt.Sprintf("x%d", i), i)
+    }
+    if _, found := c.Get("hot"); found != tt.keep {
+      t.Fatalf("hot present = %v after a scan, want %v", found, tt.keep)
+    }
+    if c.Count() != 3 {
+      t.Fatalf("Count() = %d, want 3", c.Count())
+    }
+  }
+}
+
+//ARC 模式下刚从 t1 淘汰的键再次写入时放入 t2，并增大 t1 的目标大小
+func TestEvictARCGhost(t *testing.T) {
+  c := New(WithShards(1), WithMaxEntries(2), WithARC())
+  c.Set("a", 1)
+  c.Set("b", 2)
+  c.Set("c", 3)
+  s := c.shards[0]
+  if e, found := s.arc.index["a"]; !found || e.Value.(*arcEntry).list != s.arc.b1 {
+    t.Fatal("the evicted key is not in the b1 ghost list")
+  }
+  c.Set("a", 4)
+  if s.arc.p == 0 {
+    t.Fatal("a b1 hit did not grow the t1 target")
+  }
+  if e := s.arc.index["a"]; e.Value.(*arcEntry).list != s.arc.t2 {
+    t.Fatal("a key written again after a b1 hit is not in t2")
+  }
+  if got := present(c, "a", "b", "c"); fmt.Sprint(got) != "[a c]" {
+    t.Fatalf("remaining items %v, want [a c]", got)
+  }
+}
+
+//TinyLFU 准入：缓存已满时访问频率不高于被淘汰者的新数据项不放入，足够热之后才放入
+func TestEvictTinyLFU(t *testing.T) {
+  c := New(WithShards(1), WithMaxEntries(3), WithLRU(), WithTinyLFU())
+  for _, k := range []string{"a", "b", "c"} {
+    c.Set(k, k)
+    for i := 0; i < 3; i++ {
+      c.Get(k)
+    }
+  }
+  c.Set("cold", 1)
+  if _, found := c.Get("cold"); found {
+    t.Fatal("a cold item was admitted over hot ones")
+  }
+  if got := present(c, "a", "b", "c"); len(got) != 3 {
+    t.Fatalf("remaining items %v, want [a b c]", got)
+  }
+  if c.Stats().Rejected != 1 {
+    t.Fatalf("Rejected = %d, want 1", c.Stats().Rejected)
+  }
+  for i := 0; i < 10 && present(c, "new") == nil; i++ {
+    c.Set("new", i)
+  }
+  if present(c, "new") == nil {
+    t.Fatal("a frequently written item was never admitted")
+  }
+  if c.Count() != 3 {
+    t.Fatalf("Count() = %d, want 3", c.Count())
+  }
+}
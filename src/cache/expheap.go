@@ -0,0 +1,82 @@
+package cache
+
+import (
+  "container/heap"
+)
+
+//每批最多清理的过期数据项数量，批与批之间释放分片的锁
+const sweepBatch = 1024
+
+//过期堆中的一项
+type expEntry struct {
+  key   string
+  exp   int64
+  index int
+}
+
+//按过期时间排序的最小堆，只包含有过期时间的数据项
+type expHeap []*expEntry
+
+func (h expHeap) Len() int { return len(h) }
+func (h expHeap) Less(i, j int) bool { return h[i].exp < h[j].exp }
+func (h expHeap) Swap(i, j int) {
+  h[i], h[j] = h[j], h[i]
+  h[i].index = i
+  h[j].index = j
+}
+
+func (h *expHeap) Push(x interface{}) {
+  e := x.(*expEntry)
+  e.index = len(*h)
+  *h = append(*h, e)
+}
+
+func (h *expHeap) Pop() interface{} {
+  old := *h
+  e := old[len(old)-1]
+  old[len(old)-1] = nil
+  *h = old[:len(old)-1]
+  return e
+}
+
+//更新数据项在过期堆中的位置，exp 为 0 时从堆中移除，需要持有写锁。
+//过期的数据项会再保留 staleFor 才被清理，期间可以用 GetStale 读取
+func (s *shard) schedule(k string, exp int64) {
+  if exp != 0 {
+    exp += int64(s.c.staleFor)
+  }
+  e, found := s.expIndex[k]
+  if exp == 0 {
+    if found {
+      heap.Remove(&s.expHeap, e.index)
+      delete(s.expIndex, k)
+    }
+    return
+  }
+  if found {
+    e.exp = exp
+    heap.Fix(&s.expHeap, e.index)
+    return
+  }
+  e = &expEntry{key: k, exp: exp}
+  heap.Push(&s.expHeap, e)
+  s.expIndex[k] = e
+}
+
+//从堆顶开始清理在 now 之前过期的数据项，最多清理 limit 个，返回清理的数量和是否还有剩余
+func (s *shard) sweep(now int64, limit int) (int, bool) {
+  n := 0
+  for len(s.expHeap) > 0 && s.expHeap[0].exp < now {
+    if n == limit {
+      return n, true
+    }
+    s.remove(s.expHeap[0].key, EventExpire)
+    n++
+  }
+  return n, false
+}
+
+func (s *shard) resetExpHeap() {
+  s.expHeap = nil
+  s.expIndex = map[string]*expEntry{}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+  "expvar"
+  "fmt"
+)
+
+//把缓存的统计数据以 name 注册到 expvar，在 /debug/vars 中可以看到。
+//heap_bytes 只在设置了 Sizer 时给出，为数据项占用字节数的估计。name 已被注册时返回错误
+func (c *Cache) PublishExpvar(name string) error {
+  if expvar.Get(name) != nil {
+    return fmt.Errorf("Expvar %s already exists.", name)
+  }
+  expvar.Publish(name, expvar.Func(func() interface{} {
+    st := c.Stats()
+    vars := map[string]interface{}{
+      "items": st.Items,
+      "hits": st.Hits,
+      "misses": st.Misses,
+      "sets": st.Sets,
+      "deletes": st.Deletes,
+      "expired": st.Expired,
+      "evictions": st.Evictions,
+      "cost": st.Cost,
+    }
+    c.shards[0].mu.RLock()
+    sized := c.sizer != nil
+    c.shards[0].mu.RUnlock()
+    if sized {
+      vars["heap_bytes"] = st.Cost
+    }
+    return vars
+  }))
+  return nil
+}
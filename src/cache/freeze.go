@@ -0,0 +1,41 @@
+package cache
+
+import (
+  "errors"
+)
+
+//缓存被冻结时写入和删除返回的错误，没有返回值的方法不生效
+var ErrReadOnly = errors.New("Cache is read-only.")
+
+//冻结缓存，之后的写入、删除和清空返回 ErrReadOnly 或不生效，读取照常进行，
+//可以在期间保存一致的快照或交接流量。过期清理和滑动过期不受影响，加载的结果返回给调用者但不保存
+func (c *Cache) Freeze() {
+  c.lockAll()
+  defer c.unlockAll()
+  c.frozen = true
+}
+
+//解除冻结
+func (c *Cache) Thaw() {
+  c.lockAll()
+  defer c.unlockAll()
+  c.frozen = false
+}
+
+//返回缓存是否被冻结
+func (c *Cache) Frozen() bool {
+  c.shards[0].mu.RLock()
+  defer c.shards[0].mu.RUnlock()
+  return c.frozen
+}
+
+//检查是否可以写入，关闭时返回 ErrClosed，冻结时返回 ErrReadOnly，需要持有分片的锁
+func (c *Cache) writable() error {
+  switch {
+  case c.closed:
+    return ErrClosed
+  case c.frozen:
+    return ErrReadOnly
+  }
+  return nil
+}
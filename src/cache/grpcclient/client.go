@@ -0,0 +1,153 @@
+package grpcclient
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "google.golang.org/grpc"
+  "google.golang.org/protobuf/types/known/durationpb"
+
+  "cache"
+  "cache/cachepb"
+)
+
+//gRPC 缓存服务的客户端，ctx 的截止时间会随请求传递给服务端
+type Client struct {
+  pb      cachepb.CacheClient
+  conn    *grpc.ClientConn
+  timeout time.Duration
+}
+
+//连接 target 上的缓存服务
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+  conn, err := grpc.NewClient(target, opts...)
+  if err != nil {
+    return nil, err
+  }
+  c := NewClient(conn)
+  c.conn = conn
+  return c, nil
+}
+
+//使用已有的连接创建客户端，Close 不会关闭该连接
+func NewClient(cc grpc.ClientConnInterface) *Client {
+  return &Client{pb: cachepb.NewCacheClient(cc)}
+}
+
+//ctx 没有截止时间时使用的超时时间，0 表示不设置，在使用客户端前调用
+func (c *Client) SetDefaultTimeout(d time.Duration) {
+  c.timeout = d
+}
+
+//关闭 Dial 建立的连接
+func (c *Client) Close() error {
+  if c.conn == nil {
+    return nil
+  }
+  return c.conn.Close()
+}
+
+func (c *Client) context(ctx context.Context) (context.Context, context.CancelFunc) {
+  if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+    return ctx, func() {}
+  }
+  return context.WithTimeout(ctx, c.timeout)
+}
+
+//获取数据项，不存在时第二个返回值为 false
+func (c *Client) Get(ctx context.Context, k string) ([]byte, bool, error) {
+  ctx, cancel := c.context(ctx)
+  defer cancel()
+  res, err := c.pb.Get(ctx, &cachepb.GetRequest{Key: k})
+  if err != nil {
+    return nil, false, err
+  }
+  return res.Value, res.Found, nil
+}
+
+//设置数据项，d 的含义与 Cache.Set 相同
+func (c *Client) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+  ctx, cancel := c.context(ctx)
+  defer cancel()
+  _, err := c.pb.Set(ctx, setRequest(k, v, d))
+  return err
+}
+
+//删除数据项，返回删除前数据项是否存在
+func (c *Client) Delete(ctx context.Context, k string) (bool, error) {
+  ctx, cancel := c.context(ctx)
+  defer cancel()
+  res, err := c.pb.Delete(ctx, &cachepb.DeleteRequest{Key: k})
+  if err != nil {
+    return false, err
+  }
+  return res.Deleted, nil
+}
+
+//一次请求中执行的一组操作
+type Batch struct {
+  ops []*cachepb.Operation
+}
+
+func (b *Batch) Get(k string) {
+  b.ops = append(b.ops, &cachepb.Operation{Op: &cachepb.Operation_Get{Get: &cachepb.GetRequest{Key: k}}})
+}
+
+func (b *Batch) Set(k string, v []byte, d time.Duration) {
+  b.ops = append(b.ops, &cachepb.Operation{Op: &cachepb.Operation_Set{Set: setRequest(k, v, d)}})
+}
+
+func (b *Batch) Delete(k string) {
+  b.ops = append(b.ops, &cachepb.Operation{Op: &cachepb.Operation_Delete{Delete: &cachepb.DeleteRequest{Key: k}}})
+}
+
+//执行一组操作，返回与操作一一对应的结果
+func (c *Client) Batch(ctx context.Context, b *Batch) ([]*cachepb.Result, error) {
+  ctx, cancel := c.context(ctx)
+  defer cancel()
+  res, err := c.pb.Batch(ctx, &cachepb.BatchRequest{Ops: b.ops})
+  if err != nil {
+    return nil, err
+  }
+  if len(res.Results) != len(b.ops) {
+    return nil, fmt.Errorf("Batch returned %d results for %d operations.", len(res.Results), len(b.ops))
+  }
+  return res.Results, nil
+}
+
+//订阅键匹配 pattern 的数据项的变化，ctx 结束或连接出错时关闭返回的 channel。
+//不使用默认的超时时间
+func (c *Client) Watch(ctx context.Context, pattern string) (<-chan *cachepb.WatchEvent, error) {
+  stream, err := c.pb.Watch(ctx, &cachepb.WatchRequest{Pattern: pattern})
+  if err != nil {
+    return nil, err
+  }
+  ch := make(chan *cachepb.WatchEvent)
+  go func() {
+    defer close(ch)
+    for {
+      e, err := stream.Recv()
+      if err != nil {
+        return
+      }
+      select {
+      case ch <- e:
+      case <-ctx.Done():
+        return
+      }
+    }
+  }()
+  return ch, nil
+}
+
+func setRequest(k string, v []byte, d time.Duration) *cachepb.SetRequest {
+  req := &cachepb.SetRequest{Key: k, Value: v}
+  switch {
+  case d == cache.NoExpiration:
+    req.Ttl = durationpb.New(-1)
+  case d > 0:
+    req.Ttl = durationpb.New(d)
+  }
+  return req
+}
@@ -0,0 +1,139 @@
+package grpcclient
+
+import (
+  "context"
+  "net"
+  "testing"
+  "time"
+
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/credentials/insecure"
+  "google.golang.org/grpc/status"
+  "google.golang.org/grpc/test/bufconn"
+
+  "cache"
+  "cache/cachepb"
+  "cache/grpcserver"
+)
+
+//启动服务并返回连接到它的客户端，测试结束时关闭服务
+func startServer(t *testing.T, c *cache.Cache) *Client {
+  t.Helper()
+  ln := bufconn.Listen(1 << 20)
+  gs := grpc.NewServer()
+  grpcserver.Register(gs, c)
+  go gs.Serve(ln)
+  client, err := Dial("passthrough:///bufnet",
+    grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+    grpc.WithTransportCredentials(insecure.NewCredentials()))
+  if err != nil {
+    t.Fatal(err)
+  }
+  client.SetDefaultTimeout(5 * time.Second)
+  t.Cleanup(func() {
+    client.Close()
+    gs.Stop()
+  })
+  return client
+}
+
+func TestGetSetDelete(t *testing.T) {
+  c := cache.New()
+  client := startServer(t, c)
+  ctx := context.Background()
+  if err := client.Set(ctx, "a", []byte("x"), time.Minute); err != nil {
+    t.Fatal(err)
+  }
+  if v, found, err := client.Get(ctx, "a"); err != nil || !found || string(v) != "x" {
+    t.Fatalf("Get(a) = %q, %v, %v, want x", v, found, err)
+  }
+  if _, e, _ := c.GetWithExpiration("a"); e.IsZero() {
+    t.Fatal("the ttl was not passed to the cache")
+  }
+  c.Set("n", 42)
+  if v, _, err := client.Get(ctx, "n"); err != nil || string(v) != "42" {
+    t.Fatalf("Get(n) = %q, %v, want 42", v, err)
+  }
+  if deleted, err := client.Delete(ctx, "a"); err != nil || !deleted {
+    t.Fatalf("Delete(a) = %v, %v, want true", deleted, err)
+  }
+  if deleted, err := client.Delete(ctx, "a"); err != nil || deleted {
+    t.Fatalf("second Delete(a) = %v, %v, want false", deleted, err)
+  }
+  if _, found, err := client.Get(ctx, "a"); err != nil || found {
+    t.Fatalf("Get(a) after Delete = %v, %v, want a miss", found, err)
+  }
+}
+
+func TestBatch(t *testing.T) {
+  client := startServer(t, cache.New())
+  b := &Batch{}
+  b.Set("a", []byte("1"), cache.NoExpiration)
+  b.Get("a")
+  b.Delete("a")
+  b.Get("a")
+  results, err := client.Batch(context.Background(), b)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if get := results[1].GetGet(); !get.Found || string(get.Value) != "1" {
+    t.Fatalf("Get after Set in a batch = %v", get)
+  }
+  if !results[2].GetDelete().Deleted {
+    t.Fatal("Delete in a batch did not find the item")
+  }
+  if results[3].GetGet().Found {
+    t.Fatal("Get after Delete in a batch found the item")
+  }
+}
+
+func TestDeadline(t *testing.T) {
+  client := startServer(t, cache.New())
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  if _, _, err := client.Get(ctx, "a"); status.Code(err) != codes.Canceled {
+    t.Fatalf("Get with a canceled context = %v, want Canceled", err)
+  }
+}
+
+func TestWatch(t *testing.T) {
+  c := cache.New()
+  client := startServer(t, c)
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  ch, err := client.Watch(ctx, "w*")
+  if err != nil {
+    t.Fatal(err)
+  }
+  //服务端在流建立后才订阅，重复写入直到收到事件
+  var e *cachepb.WatchEvent
+  for deadline := time.Now().Add(5 * time.Second); e == nil; {
+    if time.Now().After(deadline) {
+      t.Fatal("no event")
+    }
+    c.Set("other", 1)
+    c.Set("w1", "v")
+    select {
+    case e = <-ch:
+    case <-time.After(50 * time.Millisecond):
+    }
+  }
+  if e.Type != cachepb.WatchEvent_SET || e.Key != "w1" || string(e.Value) != "v" {
+    t.Fatalf("event %v, want SET w1 v", e)
+  }
+  c.Delete("w1")
+  var deleted *cachepb.WatchEvent
+  for e = range ch {
+    if e.Type == cachepb.WatchEvent_DELETE {
+      deleted = e
+      break
+    }
+  }
+  if deleted == nil || deleted.Key != "w1" {
+    t.Fatalf("delete event %v, want DELETE w1", deleted)
+  }
+  cancel()
+  for range ch {
+  }
+}
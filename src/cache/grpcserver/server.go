@@ -0,0 +1,160 @@
+package grpcserver
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "google.golang.org/grpc"
+  "google.golang.org/grpc/codes"
+  "google.golang.org/grpc/status"
+
+  "cache"
+  "cache/cachepb"
+)
+
+//以 gRPC 对外提供缓存服务，值以字节形式读写
+type Server struct {
+  cachepb.UnimplementedCacheServer
+  c *cache.Cache
+}
+
+//创建一个对外提供 c 的服务
+func NewServer(c *cache.Cache) *Server {
+  return &Server{c: c}
+}
+
+//把服务注册到 gs
+func Register(gs *grpc.Server, c *cache.Cache) *Server {
+  s := NewServer(c)
+  cachepb.RegisterCacheServer(gs, s)
+  return s
+}
+
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, status.FromContextError(err).Err()
+  }
+  return s.get(req), nil
+}
+
+func (s *Server) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, status.FromContextError(err).Err()
+  }
+  return s.set(req), nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, status.FromContextError(err).Err()
+  }
+  return s.delete(req), nil
+}
+
+//按顺序执行，截止时间到达时停止执行剩余的操作并返回错误
+func (s *Server) Batch(ctx context.Context, req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+  res := &cachepb.BatchResponse{Results: make([]*cachepb.Result, 0, len(req.Ops))}
+  for i, op := range req.Ops {
+    if err := ctx.Err(); err != nil {
+      return nil, status.FromContextError(err).Err()
+    }
+    r := &cachepb.Result{}
+    switch op := op.Op.(type) {
+    case *cachepb.Operation_Get:
+      r.Result = &cachepb.Result_Get{Get: s.get(op.Get)}
+    case *cachepb.Operation_Set:
+      r.Result = &cachepb.Result_Set{Set: s.set(op.Set)}
+    case *cachepb.Operation_Delete:
+      r.Result = &cachepb.Result_Delete{Delete: s.delete(op.Delete)}
+    default:
+      return nil, status.Errorf(codes.InvalidArgument, "Operation %d is empty.", i)
+    }
+    res.Results = append(res.Results, r)
+  }
+  return res, nil
+}
+
+//推送键的变化，客户端取消或缓存关闭时结束
+func (s *Server) Watch(req *cachepb.WatchRequest, stream cachepb.Cache_WatchServer) error {
+  ch := s.c.Subscribe(req.Pattern)
+  defer s.c.Unsubscribe(ch)
+  ctx := stream.Context()
+  for {
+    select {
+    case e, ok := <-ch:
+      if !ok {
+        return status.Error(codes.Unavailable, "Cache has been closed.")
+      }
+      err := stream.Send(&cachepb.WatchEvent {
+        Type: eventType(e.Type),
+        Key: e.Key,
+        Value: bytesOf(e.Value),
+        Seq: e.Seq,
+        Time: e.Time,
+      })
+      if err != nil {
+        return err
+      }
+    case <-ctx.Done():
+      return status.FromContextError(ctx.Err()).Err()
+    }
+  }
+}
+
+func (s *Server) get(req *cachepb.GetRequest) *cachepb.GetResponse {
+  v, e, found := s.c.GetWithExpiration(req.Key)
+  if !found {
+    return &cachepb.GetResponse{}
+  }
+  res := &cachepb.GetResponse{Found: true, Value: bytesOf(v)}
+  if !e.IsZero() {
+    res.Expiration = e.UnixNano()
+  }
+  return res
+}
+
+func (s *Server) set(req *cachepb.SetRequest) *cachepb.SetResponse {
+  d := cache.DefaultExpiration
+  if req.Ttl != nil {
+    if d = req.Ttl.AsDuration(); d <= 0 {
+      d = cache.NoExpiration
+    }
+  }
+  s.c.Set(req.Key, req.Value, cache.WithTTL(d))
+  return &cachepb.SetResponse{}
+}
+
+func (s *Server) delete(req *cachepb.DeleteRequest) *cachepb.DeleteResponse {
+  _, found := s.c.GetAndDelete(req.Key)
+  return &cachepb.DeleteResponse{Deleted: found}
+}
+
+func eventType(t cache.EventType) cachepb.WatchEvent_Type {
+  switch t {
+  case cache.EventDelete:
+    return cachepb.WatchEvent_DELETE
+  case cache.EventExpire:
+    return cachepb.WatchEvent_EXPIRE
+  case cache.EventEvict:
+    return cachepb.WatchEvent_EVICT
+  case cache.EventFlush:
+    return cachepb.WatchEvent_FLUSH
+  }
+  return cachepb.WatchEvent_SET
+}
+
+//把数据项转换为字节，不是字节或字符串的值按 fmt.Sprint 格式化
+func bytesOf(v interface{}) []byte {
+  switch v := v.(type) {
+  case nil:
+    return nil
+  case []byte:
+    return v
+  case string:
+    return []byte(v)
+  case time.Time:
+    return []byte(v.Format(time.RFC3339Nano))
+  }
+  return []byte(fmt.Sprint(v))
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+  "container/heap"
+  "sort"
+  "sync"
+  "time"
+)
+
+//一个热点键及其在统计窗口内的估计访问次数
+type KeyStats struct {
+  Key   string
+  Count uint64  // 估计的命中次数，是真实次数的上界
+}
+
+//分片内的热点键统计，用 Space-Saving 算法保留命中次数最多的 capacity 个键。
+//统计分为当前和上一个窗口，报告的是两个窗口之和，窗口的长度为 window
+type hotKeys struct {
+  mu       sync.Mutex
+  capacity int
+  window   int64
+  start    int64  // 当前窗口开始的时间
+  cur      *spaceSaving
+  prev     *spaceSaving
+}
+
+type hotEntry struct {
+  key   string
+  count uint64
+  index int
+}
+
+//按次数排序的最小堆
+type hotHeap []*hotEntry
+
+func (h hotHeap) Len() int { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h hotHeap) Swap(i, j int) {
+  h[i], h[j] = h[j], h[i]
+  h[i].index = i
+  h[j].index = j
+}
+
+func (h *hotHeap) Push(x interface{}) {
+  e := x.(*hotEntry)
+  e.index = len(*h)
+  *h = append(*h, e)
+}
+
+func (h *hotHeap) Pop() interface{} {
+  old := *h
+  e := old[len(old)-1]
+  *h = old[:len(old)-1]
+  return e
+}
+
+type spaceSaving struct {
+  entries map[string]*hotEntry
+  heap    hotHeap
+}
+
+func newSpaceSaving() *spaceSaving {
+  return &spaceSaving{entries: map[string]*hotEntry{}}
+}
+
+//已满时替换次数最少的键，新键继承它的次数，因此估计值只会偏大
+func (ss *spaceSaving) add(k string, capacity int) {
+  if e, found := ss.entries[k]; found {
+    e.count++
+    heap.Fix(&ss.heap, e.index)
+    return
+  }
+  if len(ss.heap) < capacity {
+    e := &hotEntry{key: k, count: 1}
+    heap.Push(&ss.heap, e)
+    ss.entries[k] = e
+    return
+  }
+  e := ss.heap[0]
+  delete(ss.entries, e.key)
+  e.key = k
+  e.count++
+  ss.entries[k] = e
+  heap.Fix(&ss.heap, 0)
+}
+
+func newHotKeys(capacity int, window time.Duration, now int64) *hotKeys {
+  return &hotKeys {
+    capacity: capacity,
+    window: int64(window),
+    start: now,
+    cur: newSpaceSaving(),
+    prev: newSpaceSaving(),
+  }
+}
+
+//窗口结束时轮换，超过两个窗口没有访问时清空，需要持有 mu
+func (h *hotKeys) rotate(now int64) {
+  if now - h.start < h.window {
+    return
+  }
+  if now - h.start < 2 * h.window {
+    h.prev = h.cur
+  } else {
+    h.prev = newSpaceSaving()
+  }
+  h.cur = newSpaceSaving()
+  h.start = now
+}
+
+func (h *hotKeys) add(k string, now int64) {
+  h.mu.Lock()
+  h.rotate(now)
+  h.cur.add(k, h.capacity)
+  h.mu.Unlock()
+}
+
+//把两个窗口的估计次数相加后追加到 stats
+func (h *hotKeys) collect(now int64, stats []KeyStats) []KeyStats {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  h.rotate(now)
+  for k, e := range h.cur.entries {
+    n := e.count
+    if p, found := h.prev.entries[k]; found {
+      n += p.count
+    }
+    stats = append(stats, KeyStats{k, n})
+  }
+  for k, p := range h.prev.entries {
+    if _, found := h.cur.entries[k]; !found {
+      stats = append(stats, KeyStats{k, p.count})
+    }
+  }
+  return stats
+}
+
+//开启热点键统计，统计最近一到两个 window 内的命中次数，capacity 为大致保留的键的数量。
+//window 小于等于 0 时关闭，开启后每次命中都需要短暂地加分片内的一个锁
+func (c *Cache) SetHotKeys(window time.Duration, capacity int) {
+  c.lockAll()
+  defer c.unlockAll()
+  now := c.now()
+  //每个分片多保留一倍，减少热点键集中在同一个分片时的误差
+  per := max((capacity + len(c.shards) - 1) / len(c.shards) * 2, 4)
+  for _, s := range c.shards {
+    if window > 0 {
+      s.hot.Store(newHotKeys(per, window, now))
+    } else {
+      s.hot.Store(nil)
+    }
+  }
+}
+
+//返回统计窗口内命中次数最多的 n 个键，按次数从多到少排列，没有开启统计时返回 nil
+func (c *Cache) TopKeys(n int) []KeyStats {
+  now := c.now()
+  var stats []KeyStats
+  for _, s := range c.shards {
+    s.mu.RLock()
+    if h := s.hot.Load(); h != nil {
+      stats = h.collect(now, stats)
+    }
+    s.mu.RUnlock()
+  }
+  sort.Slice(stats, func(i, j int) bool {
+    if stats[i].Count != stats[j].Count {
+      return stats[i].Count > stats[j].Count
+    }
+    return stats[i].Key < stats[j].Key
+  })
+  if len(stats) > n {
+    stats = stats[:max(n, 0)]
+  }
+  return stats
+}
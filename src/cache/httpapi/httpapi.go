@@ -0,0 +1,126 @@
+package httpapi
+
+import (
+  "crypto/subtle"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "time"
+
+  "cache"
+)
+
+//PUT 请求体的大小上限
+const maxValueSize = 1 << 20
+
+//通过 HTTP 查看和修改缓存的管理接口
+type Handler struct {
+  c     *cache.Cache
+  token string
+  mux   *http.ServeMux
+}
+
+//创建一个管理 c 的 http.Handler。token 不为空时请求需要带上 Authorization: Bearer <token>。
+//GET/PUT/DELETE /keys/{key} 读写单个数据项，PUT 的请求体作为字符串保存，可以用 ttl 参数指定生存时间，
+//GET /stats 返回统计数据，POST /flush 清空缓存，GET /dump 返回所有未过期的数据项
+func NewHandler(c *cache.Cache, token string) *Handler {
+  h := &Handler {
+    c: c,
+    token: token,
+    mux: http.NewServeMux(),
+  }
+  h.mux.HandleFunc("GET /keys/{key...}", h.get)
+  h.mux.HandleFunc("PUT /keys/{key...}", h.put)
+  h.mux.HandleFunc("DELETE /keys/{key...}", h.delete)
+  h.mux.HandleFunc("GET /stats", h.stats)
+  h.mux.HandleFunc("POST /flush", h.flush)
+  h.mux.HandleFunc("GET /dump", h.dump)
+  return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  if h.token != "" {
+    want := "Bearer " + h.token
+    if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+      http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+      return
+    }
+  }
+  h.mux.ServeHTTP(w, r)
+}
+
+//单个数据项的响应
+type item struct {
+  Key        string      `json:"key"`
+  Value      interface{} `json:"value"`
+  Expiration *time.Time  `json:"expiration,omitempty"`  // 永不过期时省略
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+  k := r.PathValue("key")
+  v, e, found := h.c.GetWithExpiration(k)
+  if !found {
+    http.Error(w, fmt.Sprintf("Item %s not found.", k), http.StatusNotFound)
+    return
+  }
+  it := item{Key: k, Value: v}
+  if !e.IsZero() {
+    it.Expiration = &e
+  }
+  writeJSON(w, it)
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+  k := r.PathValue("key")
+  d := cache.DefaultExpiration
+  if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+    var err error
+    if d, err = time.ParseDuration(ttl); err != nil || d <= 0 {
+      http.Error(w, fmt.Sprintf("Invalid ttl %s.", ttl), http.StatusBadRequest)
+      return
+    }
+  }
+  body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+    return
+  }
+  h.c.Set(k, string(body), cache.WithTTL(d))
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+  h.c.Delete(r.PathValue("key"))
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+  writeJSON(w, h.c.Stats())
+}
+
+func (h *Handler) flush(w http.ResponseWriter, r *http.Request) {
+  h.c.Flush()
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) dump(w http.ResponseWriter, r *http.Request) {
+  items := h.c.Items()
+  out := make([]item, 0, len(items))
+  for k, v := range items {
+    it := item{Key: k, Value: v.Object}
+    if v.Expiration > 0 {
+      e := time.Unix(0, v.Expiration)
+      it.Expiration = &e
+    }
+    out = append(out, it)
+  }
+  writeJSON(w, out)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  if err := json.NewEncoder(w).Encode(v); err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+  }
+}
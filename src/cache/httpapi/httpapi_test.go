@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+
+  "cache"
+)
+
+//发送请求并返回响应
+func request(t *testing.T, h http.Handler, method, target, body, token string) *httptest.ResponseRecorder {
+  t.Helper()
+  r := httptest.NewRequest(method, target, strings.NewReader(body))
+  if token != "" {
+    r.Header.Set("Authorization", "Bearer "+token)
+  }
+  w := httptest.NewRecorder()
+  h.ServeHTTP(w, r)
+  return w
+}
+
+func TestKeys(t *testing.T) {
+  c := cache.New()
+  h := NewHandler(c, "")
+  if w := request(t, h, "PUT", "/keys/a/b", "hello", ""); w.Code != http.StatusNoContent {
+    t.Fatalf("PUT = %d, want %d", w.Code, http.StatusNoContent)
+  }
+  w := request(t, h, "GET", "/keys/a/b", "", "")
+  var it item
+  if err := json.NewDecoder(w.Body).Decode(&it); err != nil {
+    t.Fatal(err)
+  }
+  if w.Code != http.StatusOK || it.Key != "a/b" || it.Value != "hello" || it.Expiration != nil {
+    t.Fatalf("GET = %d %+v, want 200 with value hello and no expiration", w.Code, it)
+  }
+  if w := request(t, h, "DELETE", "/keys/a/b", "", ""); w.Code != http.StatusNoContent {
+    t.Fatalf("DELETE = %d, want %d", w.Code, http.StatusNoContent)
+  }
+  if w := request(t, h, "GET", "/keys/a/b", "", ""); w.Code != http.StatusNotFound {
+    t.Fatalf("GET after DELETE = %d, want %d", w.Code, http.StatusNotFound)
+  }
+}
+
+func TestPutTTL(t *testing.T) {
+  c := cache.New()
+  h := NewHandler(c, "")
+  if w := request(t, h, "PUT", "/keys/x?ttl=1m", "v", ""); w.Code != http.StatusNoContent {
+    t.Fatalf("PUT with ttl = %d, want %d", w.Code, http.StatusNoContent)
+  }
+  if _, e, found := c.GetWithExpiration("x"); !found || e.IsZero() {
+    t.Fatal("the item has no expiration")
+  }
+  for _, ttl := range []string{"soon", "-1s", "0s"} {
+    if w := request(t, h, "PUT", "/keys/y?ttl="+ttl, "v", ""); w.Code != http.StatusBadRequest {
+      t.Fatalf("PUT with ttl %s = %d, want %d", ttl, w.Code, http.StatusBadRequest)
+    }
+  }
+  if _, found := c.Get("y"); found {
+    t.Fatal("an invalid ttl stored the item")
+  }
+  if w := request(t, h, "PUT", "/keys/big", strings.Repeat("x", maxValueSize+1), ""); w.Code != http.StatusRequestEntityTooLarge {
+    t.Fatalf("PUT of an oversized body = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+  }
+}
+
+func TestToken(t *testing.T) {
+  h := NewHandler(cache.New(), "secret")
+  for _, token := range []string{"", "wrong"} {
+    if w := request(t, h, "GET", "/stats", "", token); w.Code != http.StatusUnauthorized {
+      t.Fatalf("GET /stats with token %q = %d, want %d", token, w.Code, http.StatusUnauthorized)
+    }
+  }
+  if w := request(t, h, "GET", "/stats", "", "secret"); w.Code != http.StatusOK {
+    t.Fatalf("GET /stats with the token = %d, want %d", w.Code, http.StatusOK)
+  }
+}
+
+func TestDumpFlush(t *testing.T) {
+  c := cache.New()
+  c.Set("a", "1")
+  c.Set("b", "2")
+  h := NewHandler(c, "")
+  var items []item
+  if err := json.NewDecoder(request(t, h, "GET", "/dump", "", "").Body).Decode(&items); err != nil {
+    t.Fatal(err)
+  }
+  if len(items) != 2 {
+    t.Fatalf("GET /dump returned %d items, want 2", len(items))
+  }
+  if w := request(t, h, "POST", "/flush", "", ""); w.Code != http.StatusNoContent {
+    t.Fatalf("POST /flush = %d, want %d", w.Code, http.StatusNoContent)
+  }
+  if c.Count() != 0 {
+    t.Fatalf("Count() = %d after flush, want 0", c.Count())
+  }
+}
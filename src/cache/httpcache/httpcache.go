@@ -0,0 +1,187 @@
+package httpcache
+
+import (
+  "bytes"
+  "net/http"
+  "sort"
+  "strings"
+  "time"
+
+  "cache"
+)
+
+//请求带有该头部时不读取也不写入缓存
+const BypassHeader = "X-Cache-Bypass"
+
+//响应中标记是否命中缓存的头部，值为 HIT 或 MISS
+const StatusHeader = "X-Cache"
+
+//超过该大小的响应体不缓存
+const MaxBodySize = 1 << 20
+
+//缓存的完整响应
+type Response struct {
+  Status int
+  Header http.Header
+  Body   []byte
+}
+
+//响应带有 Vary 时，基础键下保存的是参与区分的请求头部，响应保存在加上这些头部的值的键下
+type variants struct {
+  Names []string
+}
+
+//返回缓存 GET 请求响应的中间件，命中时直接返回缓存的状态码、头部和响应体。
+//keyFn 为 nil 时使用请求的主机和 URI 作为键。响应的 Vary 头部参与键的计算，
+//带有 Set-Cookie、Cache-Control: no-store 或 private 的响应不缓存
+func Middleware(c *cache.Cache, keyFn func(*http.Request) string, ttl time.Duration) func(http.Handler) http.Handler {
+  if keyFn == nil {
+    keyFn = defaultKey
+  }
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      if r.Method != http.MethodGet || r.Header.Get(BypassHeader) != "" {
+        next.ServeHTTP(w, r)
+        return
+      }
+      base := keyFn(r)
+      if resp, found := lookup(c, base, r); found {
+        serve(w, resp)
+        return
+      }
+      rec := &recorder{w: w, status: http.StatusOK}
+      w.Header().Set(StatusHeader, "MISS")
+      next.ServeHTTP(rec, r)
+      if rec.tooLarge || !cacheable(rec.status, w.Header()) {
+        return
+      }
+      header := w.Header().Clone()
+      header.Del(StatusHeader)
+      store(c, base, r, &Response{rec.status, header, rec.body.Bytes()}, ttl)
+    })
+  }
+}
+
+func defaultKey(r *http.Request) string {
+  return "http:" + r.Host + r.URL.RequestURI()
+}
+
+//按响应的 Vary 头部计算请求对应的键
+func variantKey(base string, names []string, r *http.Request) string {
+  var b strings.Builder
+  b.WriteString(base)
+  for _, name := range names {
+    b.WriteByte(0)
+    b.WriteString(strings.Join(r.Header.Values(name), ","))
+  }
+  return b.String()
+}
+
+func lookup(c *cache.Cache, base string, r *http.Request) (*Response, bool) {
+  v, found := c.Get(base)
+  if !found {
+    return nil, false
+  }
+  if vr, ok := v.(*variants); ok {
+    if v, found = c.Get(variantKey(base, vr.Names, r)); !found {
+      return nil, false
+    }
+  }
+  resp, ok := v.(*Response)
+  return resp, ok
+}
+
+func store(c *cache.Cache, base string, r *http.Request, resp *Response, ttl time.Duration) {
+  var names []string
+  for _, v := range resp.Header.Values("Vary") {
+    for _, name := range strings.Split(v, ",") {
+      if name = strings.TrimSpace(name); name != "" {
+        names = append(names, http.CanonicalHeaderKey(name))
+      }
+    }
+  }
+  if len(names) == 0 {
+    c.Set(base, resp, cache.WithTTL(ttl))
+    return
+  }
+  sort.Strings(names)
+  c.Set(base, &variants{names}, cache.WithTTL(ttl))
+  c.Set(variantKey(base, names, r), resp, cache.WithTTL(ttl))
+}
+
+//只缓存默认可缓存的状态码，并遵守响应的 Cache-Control
+func cacheable(status int, h http.Header) bool {
+  switch status {
+  case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+    http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+  default:
+    return false
+  }
+  if h.Get("Set-Cookie") != "" {
+    return false
+  }
+  for _, v := range h.Values("Vary") {
+    if strings.TrimSpace(v) == "*" {
+      return false
+    }
+  }
+  for _, v := range h.Values("Cache-Control") {
+    for _, d := range strings.Split(v, ",") {
+      switch strings.ToLower(strings.TrimSpace(d)) {
+      case "no-store", "private", "no-cache":
+        return false
+      }
+    }
+  }
+  return true
+}
+
+func serve(w http.ResponseWriter, resp *Response) {
+  h := w.Header()
+  for k, v := range resp.Header {
+    h[k] = append([]string(nil), v...)
+  }
+  h.Set(StatusHeader, "HIT")
+  w.WriteHeader(resp.Status)
+  w.Write(resp.Body)
+}
+
+//把响应写给客户端的同时记下状态码和响应体
+type recorder struct {
+  w           http.ResponseWriter
+  status      int
+  wroteHeader bool
+  body        bytes.Buffer
+  tooLarge    bool
+}
+
+func (rec *recorder) Header() http.Header {
+  return rec.w.Header()
+}
+
+func (rec *recorder) WriteHeader(status int) {
+  if rec.wroteHeader {
+    return
+  }
+  rec.wroteHeader = true
+  rec.status = status
+  rec.w.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+  rec.WriteHeader(http.StatusOK)
+  if !rec.tooLarge {
+    if rec.body.Len() + len(p) > MaxBodySize {
+      rec.tooLarge = true
+      rec.body = bytes.Buffer{}
+    } else {
+      rec.body.Write(p)
+    }
+  }
+  return rec.w.Write(p)
+}
+
+//让 http.ResponseController 可以访问底层的 ResponseWriter
+func (rec *recorder) Unwrap() http.ResponseWriter {
+  return rec.w
+}
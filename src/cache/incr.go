@@ -0,0 +1,107 @@
+package cache
+
+import (
+  "fmt"
+  "sync/atomic"
+)
+
+//将整数类型的数据项加上 n，保持原有类型和过期时间，返回新的值。数据项不存在或不是整数时返回错误
+func (c *Cache) Increment(k string, n int64) (int64, error) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if err := c.writable(); err != nil {
+    return 0, err
+  }
+  item, found := s.items[k]
+  if !found || c.expired(item) || item.Negative {
+    return 0, fmt.Errorf("Item %s not found.", k)
+  }
+  var nv int64
+  switch v := item.Object.(type) {
+  case int:
+    item.Object = v + int(n)
+    nv = int64(v + int(n))
+  case int8:
+    item.Object = v + int8(n)
+    nv = int64(v + int8(n))
+  case int16:
+    item.Object = v + int16(n)
+    nv = int64(v + int16(n))
+  case int32:
+    item.Object = v + int32(n)
+    nv = int64(v + int32(n))
+  case int64:
+    item.Object = v + n
+    nv = v + n
+  case uint:
+    item.Object = v + uint(n)
+    nv = int64(v + uint(n))
+  case uint8:
+    item.Object = v + uint8(n)
+    nv = int64(v + uint8(n))
+  case uint16:
+    item.Object = v + uint16(n)
+    nv = int64(v + uint16(n))
+  case uint32:
+    item.Object = v + uint32(n)
+    nv = int64(v + uint32(n))
+  case uint64:
+    item.Object = v + uint64(n)
+    nv = int64(v + uint64(n))
+  case uintptr:
+    item.Object = v + uintptr(n)
+    nv = int64(v + uintptr(n))
+  default:
+    return 0, fmt.Errorf("The value for %s is not an integer.", k)
+  }
+  s.update(k, item)
+  return nv, nil
+}
+
+//将整数类型的数据项减去 n，返回新的值
+func (c *Cache) Decrement(k string, n int64) (int64, error) {
+  return c.Increment(k, -n)
+}
+
+//将浮点类型的数据项加上 n，保持原有类型和过期时间，返回新的值。数据项不存在或不是浮点数时返回错误
+func (c *Cache) IncrementFloat(k string, n float64) (float64, error) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if err := c.writable(); err != nil {
+    return 0, err
+  }
+  item, found := s.items[k]
+  if !found || c.expired(item) || item.Negative {
+    return 0, fmt.Errorf("Item %s not found.", k)
+  }
+  var nv float64
+  switch v := item.Object.(type) {
+  case float32:
+    item.Object = v + float32(n)
+    nv = float64(v + float32(n))
+  case float64:
+    item.Object = v + n
+    nv = v + n
+  default:
+    return 0, fmt.Errorf("The value for %s is not a float.", k)
+  }
+  s.update(k, item)
+  return nv, nil
+}
+
+//将浮点类型的数据项减去 n，返回新的值
+func (c *Cache) DecrementFloat(k string, n float64) (float64, error) {
+  return c.IncrementFloat(k, -n)
+}
+
+//原地更新已存在的数据项的值或过期时间，成本不变，需要持有写锁
+func (s *shard) update(k string, item Item) {
+  item.Version = atomic.AddUint64(&s.c.version, 1)
+  s.items[k] = item
+  s.publish(k, &item)
+  s.touch(k)
+  s.schedule(k, item.Expiration)
+  s.c.record(EventSet, k, item)
+}
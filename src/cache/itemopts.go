@@ -0,0 +1,76 @@
+package cache
+
+import (
+  "time"
+)
+
+//Set 的选项
+type ItemOption func(*itemConfig)
+
+type itemConfig struct {
+  ttl      time.Duration
+  tags     []string
+  priority Priority
+  pinned   bool
+  noCopy   bool
+  cost     int64  // 小于 0 表示由 Sizer 计算
+}
+
+//生存时间，含义与 NewCache 的 defaultExpiration 相同，默认为 DefaultExpiration
+func WithTTL(d time.Duration) ItemOption {
+  return func(o *itemConfig) { o.ttl = d }
+}
+
+//数据项的标签，之后可以用 InvalidateTag 按标签删除，与 SetWithTags 相同
+func WithTags(tags ...string) ItemOption {
+  return func(o *itemConfig) { o.tags = append(o.tags, tags...) }
+}
+
+//淘汰优先级，默认为 PriorityNormal
+func WithPriority(p Priority) ItemOption {
+  return func(o *itemConfig) { o.priority = p }
+}
+
+//固定的数据项不会因容量限制被淘汰，与 SetWithOptions 的 Pinned 相同
+func WithPinned() ItemOption {
+  return func(o *itemConfig) { o.pinned = true }
+}
+
+//原样保存值，即使开启了压缩也不压缩，开启 WithCopyOnRead 时读取也不复制。调用方之后不能再修改值
+func WithNoCopy() ItemOption {
+  return func(o *itemConfig) { o.noCopy = true }
+}
+
+//数据项的成本，不使用 Sizer 计算，小于 0 时忽略。超过成本上限的数据项不会被放入
+func WithCost(cost int64) ItemOption {
+  return func(o *itemConfig) { o.cost = cost }
+}
+
+//按选项设置数据项，需要持有写锁
+func (s *shard) setWith(k string, v interface{}, o itemConfig) error {
+  var item Item
+  if o.noCopy {
+    item = s.newItem(nil, o.ttl, 0)
+    item.Object = v
+  } else {
+    item = s.newItem(v, o.ttl, 0)
+  }
+  if o.cost >= 0 {
+    if s.c.maxCost > 0 && o.cost > s.c.maxCost {
+      return nil
+    }
+    item.Cost = o.cost
+  } else {
+    item.Cost = s.c.costOf(k, item.Object)
+  }
+  item.Priority = min(max(o.priority, PriorityLow), PriorityHigh)
+  item.Pinned = o.pinned
+  item.NoCopy = o.noCopy
+  if err := s.setItem(k, item); err != nil {
+    return err
+  }
+  if _, found := s.items[k]; found && len(o.tags) > 0 {
+    s.tag(k, o.tags)
+  }
+  return nil
+}
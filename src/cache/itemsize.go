@@ -0,0 +1,87 @@
+package cache
+
+import (
+  "fmt"
+  "strings"
+)
+
+//数据项超过 WithMaxItemBytes 的上限
+type ItemTooLargeError struct {
+  Key  string
+  Size int64  // 估算的字节数
+  Max  int64
+}
+
+func (e *ItemTooLargeError) Error() string {
+  return fmt.Sprintf("Item %s is %d bytes, exceeding the max item size %d.", e.Key, e.Size, e.Max)
+}
+
+//数据项超过大小上限时的处理方式
+type OversizePolicy int
+
+const (
+  OversizeReject   OversizePolicy = iota  // 不写入，返回 *ItemTooLargeError
+  OversizeTruncate                        // 未压缩的字符串和 []byte 截断到上限后写入，其他值仍然拒绝
+)
+
+//单个数据项的字节数上限，与 SetMaxItemBytes(n, OversizeReject) 相同
+func WithMaxItemBytes(n int64) Option {
+  return func(o *options) { o.maxItemBytes = n }
+}
+
+//数据项超过 WithMaxItemBytes 上限时的处理方式，默认为 OversizeReject
+func WithOversizePolicy(p OversizePolicy) Option {
+  return func(o *options) { o.oversize = p }
+}
+
+//设置单个数据项的字节数上限，0 表示不限制。字节数由 SetSizeEstimator 设置的函数估算，
+//未设置时字符串和 []byte 按长度计算，其他值使用 EstimateSize，压缩的值按压缩后的长度计算。
+//超过上限的值不会替换已有的数据项，Set 等没有错误返回值的方法忽略这次写入
+func (c *Cache) SetMaxItemBytes(n int64, p OversizePolicy) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.maxItemBytes = n
+  c.oversize = p
+}
+
+//估算保存的值的字节数，需要持有分片的锁
+func (c *Cache) itemBytes(k string, v interface{}) int64 {
+  if c.estimator != nil {
+    return c.estimator(k, v)
+  }
+  switch v := v.(type) {
+  case string:
+    return int64(len(v))
+  case []byte:
+    return int64(len(v))
+  }
+  return EstimateSize(v)
+}
+
+//检查数据项的大小，按策略截断后重新计算成本，需要持有分片的锁
+func (s *shard) checkSize(k string, item *Item) error {
+  c := s.c
+  if c.maxItemBytes <= 0 {
+    return nil
+  }
+  size := c.itemBytes(k, item.Object)
+  if size <= c.maxItemBytes {
+    return nil
+  }
+  if c.oversize == OversizeTruncate && item.Compressed == 0 {
+    switch v := item.Object.(type) {
+    case string:
+      item.Object = strings.Clone(v[:min(int64(len(v)), c.maxItemBytes)])
+    case []byte:
+      item.Object = append([]byte(nil), v[:min(int64(len(v)), c.maxItemBytes)]...)
+    }
+    if c.itemBytes(k, item.Object) <= c.maxItemBytes {
+      if c.sizer != nil {
+        item.Cost = c.costOf(k, item.Object)
+      }
+      return nil
+    }
+  }
+  c.log().Warnf("Item %s is %d bytes, exceeding the max item size %d.", k, size, c.maxItemBytes)
+  return &ItemTooLargeError{k, size, c.maxItemBytes}
+}
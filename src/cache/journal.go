@@ -0,0 +1,239 @@
+package cache
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "os"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+//数据项生命周期事件的类型
+type EventType int
+
+const (
+  //数据项被设置
+  EventSet EventType = iota
+  //数据项被删除
+  EventDelete
+  //数据项过期被清理
+  EventExpire
+  //数据项因容量限制被淘汰
+  EventEvict
+  //缓存被清空
+  EventFlush
+)
+
+func (t EventType) String() string {
+  switch t {
+  case EventSet:
+    return "set"
+  case EventDelete:
+    return "delete"
+  case EventExpire:
+    return "expire"
+  case EventEvict:
+    return "evict"
+  case EventFlush:
+    return "flush"
+  }
+  return "unknown"
+}
+
+//一条生命周期事件
+type Event struct {
+  Seq  uint64     // 从 1 开始递增的序号
+  Type EventType
+  Key  string
+  Time int64      // 事件发生的时间
+  Value interface{} `json:"-"`  // 设置的值或被移除的值，只在订阅中给出，不写入日志
+}
+
+//只追加的事件日志，内存中保留最近的事件，可选地同时写入文件
+type Journal struct {
+  mu      sync.Mutex
+  seq     uint64
+  ring    []Event
+  next    int
+  size    int
+  file    *os.File
+  path    string
+  pending []Event  // 等待写入文件的事件
+  writing bool  // 是否有 goroutine 正在写入文件
+  idle    *sync.Cond  // 写入文件的 goroutine 退出时通知
+}
+
+//创建一个只保存在内存中的事件日志，size 为保留的事件数
+func NewJournal(size int) *Journal {
+  if size <= 0 {
+    size = 1
+  }
+  j := &Journal{size: size}
+  j.idle = sync.NewCond(&j.mu)
+  return j
+}
+
+//打开一个写入文件的事件日志，已有文件中的事件会被读入并继续编号
+func OpenJournal(size int, path string) (*Journal, error) {
+  j := NewJournal(size)
+  j.path = path
+  err := j.scan(0, func(e Event) {
+    j.seq = e.Seq
+    j.push(e)
+  })
+  if err != nil && !os.IsNotExist(err) {
+    return nil, err
+  }
+  j.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+  if err != nil {
+    return nil, err
+  }
+  return j, nil
+}
+
+func (j *Journal) push(e Event) {
+  if len(j.ring) < j.size {
+    j.ring = append(j.ring, e)
+    return
+  }
+  j.ring[j.next] = e
+  j.next = (j.next + 1) % j.size
+}
+
+//追加一条事件，返回带有序号的事件。在缓存的分片锁内调用，文件由另一个 goroutine 按顺序写入
+func (j *Journal) append(t EventType, k string) Event {
+  j.mu.Lock()
+  defer j.mu.Unlock()
+  j.seq++
+  e := Event{Seq: j.seq, Type: t, Key: k, Time: time.Now().UnixNano()}
+  j.push(e)
+  if j.file != nil {
+    j.pending = append(j.pending, e)
+    if !j.writing {
+      j.writing = true
+      go j.write()
+    }
+  }
+  return e
+}
+
+//把等待的事件写入文件，写完后退出
+func (j *Journal) write() {
+  j.mu.Lock()
+  defer j.mu.Unlock()
+  for len(j.pending) > 0 {
+    events, f := j.pending, j.file
+    j.pending = nil
+    j.mu.Unlock()
+    w := bufio.NewWriter(f)
+    for _, e := range events {
+      //写入失败时仍保留内存中的事件
+      if b, err := json.Marshal(e); err == nil {
+        w.Write(append(b, '\n'))
+      }
+    }
+    w.Flush()
+    j.mu.Lock()
+  }
+  j.writing = false
+  j.idle.Broadcast()
+}
+
+//返回最近一条事件的序号
+func (j *Journal) LastSeq() uint64 {
+  j.mu.Lock()
+  defer j.mu.Unlock()
+  return j.seq
+}
+
+//按顺序回放序号不小于 fromSeq 的事件，内存中已丢弃的事件从文件中读取
+func (j *Journal) Replay(fromSeq uint64, f func(Event)) error {
+  j.mu.Lock()
+  events := make([]Event, 0, len(j.ring))
+  events = append(events, j.ring[j.next:]...)
+  events = append(events, j.ring[:j.next]...)
+  j.mu.Unlock()
+
+  //回调在锁外执行，回调中可以继续操作缓存
+  if len(events) > 0 && fromSeq < events[0].Seq && j.path != "" {
+    oldest := events[0].Seq
+    err := j.scan(fromSeq, func(e Event) {
+      if e.Seq < oldest {
+        f(e)
+      }
+    })
+    if err != nil {
+      return err
+    }
+  }
+  for _, e := range events {
+    if e.Seq >= fromSeq {
+      f(e)
+    }
+  }
+  return nil
+}
+
+//从文件中顺序读取序号不小于 fromSeq 的事件
+func (j *Journal) scan(fromSeq uint64, f func(Event)) error {
+  file, err := os.Open(j.path)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  sc := bufio.NewScanner(file)
+  for sc.Scan() {
+    var e Event
+    if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+      return fmt.Errorf("Error decoding journal %s: %v", j.path, err)
+    }
+    if e.Seq >= fromSeq {
+      f(e)
+    }
+  }
+  return sc.Err()
+}
+
+//等待已记录的事件写入后关闭事件日志的文件
+func (j *Journal) Close() error {
+  j.mu.Lock()
+  defer j.mu.Unlock()
+  for j.writing {
+    j.idle.Wait()
+  }
+  if j.file == nil {
+    return nil
+  }
+  err := j.file.Close()
+  j.file = nil
+  return err
+}
+
+//设置缓存的事件日志，nil 表示不再记录
+func (c *Cache) SetJournal(j *Journal) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.journal = j
+}
+
+//记录事件并交给订阅者，需要持有分片的锁。锁内只分配序号，解压和发送在锁外进行
+func (c *Cache) record(t EventType, k string, item Item) {
+  if c.aof != nil {
+    c.logAOF(t, k, item)
+  }
+  subscribed := atomic.LoadInt32(&c.subscribers) > 0
+  if c.journal == nil && !subscribed {
+    return
+  }
+  var e Event
+  if c.journal != nil {
+    e = c.journal.append(t, k)
+  } else {
+    e = Event{Type: t, Key: k, Time: time.Now().UnixNano()}
+  }
+  if subscribed {
+    c.queueEvent(e, item)
+  }
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+//写入文件的事件在 Close 后完整保留，重新打开后继续编号
+func TestJournalFile(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "journal")
+  j, err := OpenJournal(4, path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  c := New()
+  c.SetJournal(j)
+  for i := 0; i < 100; i++ {
+    c.Set("k", i)
+  }
+  c.Delete("k")
+  if err := j.Close(); err != nil {
+    t.Fatal(err)
+  }
+  j, err = OpenJournal(4, path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer j.Close()
+  if j.LastSeq() != 101 {
+    t.Fatalf("LastSeq() = %d, want 101", j.LastSeq())
+  }
+  var seqs []uint64
+  var last EventType
+  err = j.Replay(1, func(e Event) {
+    seqs = append(seqs, e.Seq)
+    last = e.Type
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i, seq := range seqs {
+    if seq != uint64(i+1) {
+      t.Fatalf("event %d has seq %d", i, seq)
+    }
+  }
+  if len(seqs) != 101 || last != EventDelete {
+    t.Fatalf("replayed %d events ending with %v, want 101 ending with delete", len(seqs), last)
+  }
+}
+
+//订阅者按写入的顺序收到事件，压缩的值已经解压
+func TestSubscribeOrder(t *testing.T) {
+  c := New(WithCompression(64))
+  ch := c.Subscribe("k")
+  long := strings.Repeat("compressible ", 100)
+  for i := 0; i < 100; i++ {
+    c.Set("k", i)
+  }
+  c.Set("k", long)
+  for i := 0; i <= 100; i++ {
+    select {
+    case e := <-ch:
+      if i < 100 && e.Value != i {
+        t.Fatalf("event %d has value %v", i, e.Value)
+      }
+      if i == 100 && e.Value != long {
+        t.Fatalf("the compressed value was published as %T", e.Value)
+      }
+    case <-time.After(5 * time.Second):
+      t.Fatalf("event %d was not published", i)
+    }
+  }
+}
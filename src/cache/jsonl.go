@@ -0,0 +1,99 @@
+package cache
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "io"
+  "time"
+)
+
+//JSON Lines 格式中的一行，expires_at 为 null 表示永不过期
+type jsonlRecord struct {
+  Key       string      `json:"key"`
+  Value     interface{} `json:"value"`
+  ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+//每行一个 {"key", "value", "expires_at"} 对象写入未过期的数据项，可以用 jq 等工具查看。
+//压缩的值写入解压后的内容，[]byte 按 encoding/json 的规则写成 base64。
+//每次只复制一个分片，SetSortedIteration 开启时复制全部数据项后按键的顺序写入
+func (c *Cache) ExportJSONL(w io.Writer) error {
+  bw := bufio.NewWriter(w)
+  enc := json.NewEncoder(bw)
+  if c.sortedIteration() {
+    items := c.snapshot()
+    for _, k := range sortedKeys(items) {
+      if err := c.exportJSONL(enc, k, items[k]); err != nil {
+        return err
+      }
+    }
+    return bw.Flush()
+  }
+  items := map[string]Item{}
+  for _, s := range c.shards {
+    c.copyShard(s, items)
+    for k, item := range items {
+      if err := c.exportJSONL(enc, k, item); err != nil {
+        return err
+      }
+    }
+    clear(items)
+  }
+  return bw.Flush()
+}
+
+//压缩的数据无法解压的数据项不导出
+func (c *Cache) exportJSONL(enc *json.Encoder, k string, item Item) error {
+  if item.Negative {
+    return nil
+  }
+  v, err := item.decode()
+  if err != nil {
+    c.corrupt(k, item, err)
+    return nil
+  }
+  rec := jsonlRecord{Key: k, Value: v}
+  if item.Expiration > 0 {
+    t := time.Unix(0, item.Expiration).UTC()
+    rec.ExpiresAt = &t
+  }
+  if err := enc.Encode(&rec); err != nil {
+    return fmt.Errorf("Exporting item %s failed: %v", k, err)
+  }
+  return nil
+}
+
+//读取 ExportJSONL 格式的数据项，值为 encoding/json 的默认类型，已过期的数据项被忽略，
+//与缓存中未过期的数据项冲突时按 SetMergePolicy 处理。每读满一批就放入缓存，格式错误时返回出错的行号，之前的行已经读入
+func (c *Cache) ImportJSONL(r io.Reader) error {
+  dec := json.NewDecoder(r)
+  now := c.now()
+  batch := map[string]Item{}
+  for line := 1; ; line++ {
+    var rec jsonlRecord
+    err := dec.Decode(&rec)
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      c.load(batch, nil)
+      return fmt.Errorf("Line %d of JSON Lines input is invalid: %v", line, err)
+    }
+    item := Item{Object: rec.Value}
+    if rec.ExpiresAt != nil {
+      item.Expiration = rec.ExpiresAt.UnixNano()
+      if now > item.Expiration {
+        continue
+      }
+    }
+    batch[rec.Key] = item
+    if len(batch) >= streamBatch {
+      if err := c.load(batch, nil); err != nil {
+        return err
+      }
+      clear(batch)
+    }
+  }
+  return c.load(batch, nil)
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+  "sync"
+)
+
+//锁的分组数量，不同分组的键互不影响
+const keyMutexStripes = 64
+
+//单个键的锁，refs 为持有和等待的调用数量，为 0 时从 map 中删除
+type keyLock struct {
+  mu   sync.Mutex
+  refs int
+}
+
+type keyStripe struct {
+  mu    sync.Mutex
+  locks map[string]*keyLock
+}
+
+//按键加锁的互斥锁，不同的键可以同时持有，零值可以直接使用。
+//只为正在使用的键保留锁，空闲的键不占用内存
+type KeyMutex struct {
+  stripes [keyMutexStripes]keyStripe
+}
+
+func (m *KeyMutex) stripe(k string) *keyStripe {
+  return &m.stripes[keyHash(k)%keyMutexStripes]
+}
+
+//锁住键 k，返回解锁的函数，解锁函数只能调用一次
+func (m *KeyMutex) Lock(k string) func() {
+  st := m.stripe(k)
+  st.mu.Lock()
+  if st.locks == nil {
+    st.locks = map[string]*keyLock{}
+  }
+  l, found := st.locks[k]
+  if !found {
+    l = &keyLock{}
+    st.locks[k] = l
+  }
+  l.refs++
+  st.mu.Unlock()
+  l.mu.Lock()
+  return st.unlocker(k, l)
+}
+
+//键 k 未被锁住时锁住并返回解锁的函数，否则返回 nil
+func (m *KeyMutex) TryLock(k string) func() {
+  st := m.stripe(k)
+  st.mu.Lock()
+  defer st.mu.Unlock()
+  if _, found := st.locks[k]; found {
+    return nil
+  }
+  if st.locks == nil {
+    st.locks = map[string]*keyLock{}
+  }
+  l := &keyLock{refs: 1}
+  l.mu.Lock()
+  st.locks[k] = l
+  return st.unlocker(k, l)
+}
+
+//返回释放 l 的函数，没有其他调用等待时删除 l
+func (st *keyStripe) unlocker(k string, l *keyLock) func() {
+  return func() {
+    l.mu.Unlock()
+    st.mu.Lock()
+    if l.refs--; l.refs == 0 {
+      delete(st.locks, k)
+    }
+    st.mu.Unlock()
+  }
+}
+
+//锁住键 k 并返回解锁的函数，用于串行化同一个键的“读取、计算、写入”，不影响其他键，
+//也不阻塞对缓存本身的读写
+func (c *Cache) LockKey(k string) func() {
+  return c.keys.Lock(k)
+}
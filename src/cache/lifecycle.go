@@ -0,0 +1,68 @@
+package cache
+
+import (
+  "errors"
+  "runtime"
+  "sync"
+  "sync/atomic"
+  "weak"
+)
+
+//缓存关闭后写入返回的错误
+var ErrClosed = errors.New("Cache has been closed.")
+
+//停止过期清理 goroutine 的信号，不引用 Cache，缓存被回收时也能使用
+type gcStopper struct {
+  once sync.Once
+  stop chan struct{}
+  done chan struct{}  // goroutine 退出后关闭
+}
+
+func (g *gcStopper) signal() {
+  g.once.Do(func() { close(g.stop) })
+}
+
+//启动过期清理。goroutine 只持有缓存的弱引用，
+//缓存未调用 Close 就被丢弃时，垃圾回收后也会停止 goroutine
+func (c *Cache) startGc() {
+  c.gc = &gcStopper{stop: make(chan struct{}), done: make(chan struct{})}
+  go gcLoop(weak.Make(c), c.clock.NewTicker(c.gcInterval), c.gc)
+  runtime.AddCleanup(c, (*gcStopper).signal, c.gc)
+}
+
+func gcLoop(p weak.Pointer[Cache], ticker Ticker, g *gcStopper) {
+  defer close(g.done)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C():
+      c := p.Value()
+      if c == nil {
+        return
+      }
+      c.gcSweep()
+      atomic.StoreInt64(&c.lastSweep, c.now())
+    case <-g.stop:
+      return
+    }
+  }
+}
+
+//关闭缓存，停止过期清理和自动保存，关闭所有订阅。关闭后写入会被忽略，有错误返回值的写入返回 ErrClosed，
+//已有的数据项仍然可以读取和保存。可以重复调用
+func (c *Cache) Close() error {
+  c.lockAll()
+  closed := c.closed
+  c.closed = true
+  c.unlockAll()
+  if closed {
+    return nil
+  }
+  c.StopGc()
+  c.StopAutoSave()
+  c.unsubscribeAll()
+  if c.aof != nil {
+    return c.aof.close()
+  }
+  return nil
+}
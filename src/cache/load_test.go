@@ -0,0 +1,62 @@
+package cache
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+//导入的数据项按当前的设置重新计算成本，超过大小上限的被跳过
+func TestImportJSONLLimits(t *testing.T) {
+  c := New(WithMaxBytes(1<<20, nil), WithMaxItemBytes(16))
+  in := `{"key":"small","value":"0123456789","expires_at":null}
+{"key":"large","value":"` + strings.Repeat("x", 100) + `","expires_at":null}
+`
+  if err := c.ImportJSONL(strings.NewReader(in)); err != nil {
+    t.Fatal(err)
+  }
+  if _, found := c.Get("large"); found {
+    t.Fatal("an item over the max item size was imported")
+  }
+  if v, found := c.Get("small"); !found || v != "0123456789" {
+    t.Fatalf("Get(small) = %v, %v", v, found)
+  }
+  if want := DefaultSizer("small", "0123456789"); c.Cost() != want {
+    t.Fatalf("Cost() = %d, want %d", c.Cost(), want)
+  }
+}
+
+//读入的数据项的成本不使用文件中保存的值
+func TestLoadRecomputesCost(t *testing.T) {
+  var buf bytes.Buffer
+  items := map[string]Item{"a": {Object: "abc", Cost: 0}, "b": {Object: "defg", Cost: 1000}}
+  if err := (GobSerializer{}).Encode(&buf, items); err != nil {
+    t.Fatal(err)
+  }
+  c := New(WithMaxBytes(1<<20, nil))
+  if err := c.Load(&buf); err != nil {
+    t.Fatal(err)
+  }
+  if want := DefaultSizer("a", "abc") + DefaultSizer("b", "defg"); c.Cost() != want {
+    t.Fatalf("Cost() = %d, want %d", c.Cost(), want)
+  }
+}
+
+//读入时按当前的设置压缩
+func TestLoadCompresses(t *testing.T) {
+  long := strings.Repeat("compressible ", 100)
+  var buf bytes.Buffer
+  if err := (GobSerializer{}).Encode(&buf, map[string]Item{"s": {Object: long}}); err != nil {
+    t.Fatal(err)
+  }
+  c := New(WithCompression(64))
+  if err := c.Load(&buf); err != nil {
+    t.Fatal(err)
+  }
+  if item := c.shard("s").items["s"]; item.Compressed == 0 {
+    t.Fatal("the loaded value was not compressed")
+  }
+  if v, _ := c.Get("s"); v != long {
+    t.Fatal("the loaded value changed")
+  }
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+  "context"
+  "math"
+  "math/rand"
+  "time"
+)
+
+//根据键加载数据项的函数
+type LoaderFunc func(ctx context.Context, key string) (interface{}, error)
+
+//自动加载的缓存，未命中时调用 loader 加载，同一个键的并发加载合并为一次调用
+type LoadingCache struct {
+  *Cache
+  loader LoaderFunc
+}
+
+//创建一个自动加载的缓存系统，加载的数据项使用 defaultExpiration 作为生存时间
+func NewLoadingCache(loader LoaderFunc, defaultExpiration, gcInterval time.Duration) *LoadingCache {
+  return &LoadingCache {
+    Cache: NewCache(defaultExpiration, gcInterval),
+    loader: loader,
+  }
+}
+
+//按配置项创建一个自动加载的缓存系统，加载的数据项使用默认的过期时间
+func NewLoadingCacheWith(loader LoaderFunc, opts ...Option) *LoadingCache {
+  return &LoadingCache {
+    Cache: New(opts...),
+    loader: loader,
+  }
+}
+
+//获取数据项，未命中时加载。加载使用第一个发起调用的 ctx，
+//其余等待中的调用在自己的 ctx 结束时提前返回
+func (l *LoadingCache) Get(ctx context.Context, k string) (interface{}, error) {
+  v, _, err := l.GetWithStale(ctx, k)
+  return v, err
+}
+
+//与 Get 相同，使用 WithStaleWhileRevalidate 时过期不久的数据项会被直接返回，
+//第二个返回值为 true，同时在后台重新加载
+func (l *LoadingCache) GetWithStale(ctx context.Context, k string) (interface{}, bool, error) {
+  ctx, end := l.Cache.trace(ctx, OpGet, k)
+  if item, stale, found := l.Cache.getStale(k); found {
+    v, err := l.Cache.read(item)
+    if err == nil {
+      if stale || l.early(item) {
+        l.refresh(k)
+      }
+      end(!stale, nil)
+      return v, stale, nil
+    }
+    l.Cache.corrupt(k, item, err)
+  }
+  v, computed, err := l.Cache.getOrCompute(ctx, k, DefaultExpiration, func() (interface{}, error) {
+    return l.Cache.traceLoad(ctx, k, l.loader)
+  })
+  endGet(end, computed, err)
+  return v, false, err
+}
+
+//在后台重新加载数据项，已经在加载时不做任何事
+func (l *LoadingCache) refresh(k string) {
+  s := l.Cache.shard(k)
+  s.mu.RLock()
+  _, busy := s.calls[k]
+  s.mu.RUnlock()
+  if busy {
+    return
+  }
+  go l.Cache.compute(context.Background(), k, DefaultExpiration, func() (interface{}, error) {
+    return l.Cache.traceLoad(context.Background(), k, l.loader)
+  }, true)
+}
+
+//XFetch：加载越慢、越接近过期，越可能提前刷新，避免大量实例在同一时刻一起重新加载。
+//当 now - ComputeTime * beta * ln(rand) 超过过期时间时刷新
+func (l *LoadingCache) early(item Item) bool {
+  beta := l.Cache.xfetchBeta
+  if beta <= 0 || item.Expiration == 0 || item.ComputeTime <= 0 {
+    return false
+  }
+  gap := -float64(item.ComputeTime) * beta * math.Log(1-rand.Float64())
+  return float64(l.Cache.now())+gap >= float64(item.Expiration)
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+  "errors"
+  "sort"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+//计算耗时的分位数只使用最近的这么多次计算
+const latencyWindow = 1024
+
+//计算耗时的分位数
+type LoadLatency struct {
+  P50 time.Duration
+  P90 time.Duration
+  P99 time.Duration
+}
+
+//最近 latencyWindow 次计算的耗时，环形保存
+type latencies struct {
+  mu  sync.Mutex
+  buf [latencyWindow]int64
+  n   int  // 已记录的次数，超过 latencyWindow 后覆盖最早的
+}
+
+func (l *latencies) add(d time.Duration) {
+  l.mu.Lock()
+  l.buf[l.n % latencyWindow] = int64(d)
+  l.n++
+  l.mu.Unlock()
+}
+
+func (l *latencies) reset() {
+  l.mu.Lock()
+  l.n = 0
+  l.mu.Unlock()
+}
+
+func (l *latencies) percentiles() LoadLatency {
+  l.mu.Lock()
+  sample := append([]int64(nil), l.buf[:min(l.n, latencyWindow)]...)
+  l.mu.Unlock()
+  if len(sample) == 0 {
+    return LoadLatency{}
+  }
+  sort.Slice(sample, func(i, j int) bool { return sample[i] < sample[j] })
+  at := func(q float64) time.Duration {
+    return time.Duration(sample[int(q * float64(len(sample) - 1))])
+  }
+  return LoadLatency{at(0.5), at(0.9), at(0.99)}
+}
+
+//开始一次计算，返回的函数在计算结束时调用
+func (c *Cache) loadStarted() func(err error) {
+  atomic.AddInt64(&c.stats.loadsInFlight, 1)
+  start := time.Now()
+  return func(err error) {
+    d := time.Since(start)
+    atomic.AddInt64(&c.stats.loadsInFlight, -1)
+    atomic.AddUint64(&c.stats.loads, 1)
+    atomic.AddInt64(&c.stats.loadTime, int64(d))
+    if err != nil && !errors.Is(err, ErrNotFound) {
+      atomic.AddUint64(&c.stats.loadErrors, 1)
+    }
+    c.loadLatency.add(d)
+  }
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+  "sync/atomic"
+)
+
+//无锁读取时索引未命中多少次后重建索引，实际阈值不小于分片内数据项的数量
+const roPromoteMin = 64
+
+//无锁读取的索引中一个键的当前值，值为 nil 表示已被删除
+type roEntry struct {
+  p atomic.Pointer[Item]
+}
+
+//无锁读取的索引，发布后键的集合不再改变，写入在持有写锁时原子地替换已有键的值，
+//新加入的键要等到重建索引后才能无锁读取
+type roMap map[string]*roEntry
+
+//开启或关闭无锁读取。开启后 Get 先查只读索引，不需要加锁，
+//写入仍然加锁并同步更新索引，新的键在索引未命中达到一定次数后批量加入。
+//使用 LRU、ARC、TinyLFU 或滑动过期的数据项时 Get 仍然需要加锁
+func (c *Cache) SetLockFreeReads(on bool) {
+  c.lockAll()
+  defer c.unlockAll()
+  for _, s := range c.shards {
+    if on && !s.ordered() && s.freq == nil {
+      s.rebuildRO()
+    } else {
+      s.ro.Store(nil)
+    }
+  }
+}
+
+//用当前的数据项重建索引，已有的键沿用原来的 roEntry，需要持有写锁
+func (s *shard) rebuildRO() {
+  var old roMap
+  if p := s.ro.Load(); p != nil {
+    old = *p
+  }
+  m := make(roMap, len(s.items))
+  for k, item := range s.items {
+    e := old[k]
+    if e == nil {
+      e = &roEntry{}
+    }
+    item := item
+    e.p.Store(&item)
+    m[k] = e
+  }
+  s.ro.Store(&m)
+  atomic.StoreInt64(&s.roMisses, 0)
+}
+
+//数据项被写入或删除后同步更新索引，item 为 nil 表示删除，需要持有写锁
+func (s *shard) publish(k string, item *Item) {
+  p := s.ro.Load()
+  if p == nil {
+    return
+  }
+  if e := (*p)[k]; e != nil {
+    e.p.Store(item)
+  }
+}
+
+//清空后使用空的索引，需要持有写锁
+func (s *shard) resetRO() {
+  if s.ro.Load() != nil {
+    s.rebuildRO()
+  }
+}
+
+//不加锁读取数据项，最后一个返回值为 false 表示需要加锁读取
+func (s *shard) getLockFree(k string) (interface{}, bool, bool) {
+  p := s.ro.Load()
+  if p == nil {
+    return nil, false, false
+  }
+  e := (*p)[k]
+  if e == nil {
+    atomic.AddInt64(&s.roMisses, 1)
+    return nil, false, false
+  }
+  item := e.p.Load()
+  if item == nil || item.Negative {
+    return nil, false, true
+  }
+  now := s.c.now()
+  if item.Expiration != 0 && now > item.Expiration {
+    return nil, false, true
+  }
+  if item.Sliding > 0 {
+    return nil, false, false
+  }
+  s.accessedAt(k, *item, now)
+  v, err := s.c.read(*item)
+  if err != nil {
+    s.c.corrupt(k, *item, err)
+    return nil, false, true
+  }
+  return v, true, true
+}
+
+//索引未命中的次数超过阈值时重建索引，在解锁后调用
+func (s *shard) promoteRO() {
+  if s.ro.Load() == nil || atomic.LoadInt64(&s.roMisses) < roPromoteMin {
+    return
+  }
+  s.mu.Lock()
+  if p := s.ro.Load(); p != nil && atomic.LoadInt64(&s.roMisses) >= int64(max(len(s.items), roPromoteMin)) {
+    s.rebuildRO()
+  }
+  s.mu.Unlock()
+}
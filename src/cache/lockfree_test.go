@@ -0,0 +1,105 @@
+package cache
+
+import (
+  "fmt"
+  "sync"
+  "testing"
+  "time"
+)
+
+//开启后索引中的键无锁读取，写入和删除立即可见
+func TestLockFreeReads(t *testing.T) {
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := New(WithClock(clock), WithShards(1), WithGCInterval(0))
+  c.Set("a", 1)
+  c.Set("b", 2, WithTTL(time.Second))
+  c.SetLockFreeReads(true)
+  s := c.shards[0]
+  if v, found, ok := s.getLockFree("a"); !ok || !found || v != 1 {
+    t.Fatalf("getLockFree(a) = %v, %v, %v, want 1 without locking", v, found, ok)
+  }
+  c.Set("a", 3)
+  if v, _ := c.Get("a"); v != 3 {
+    t.Fatalf("Get(a) = %v after overwriting it, want 3", v)
+  }
+  c.Delete("a")
+  if _, found, ok := s.getLockFree("a"); !ok || found {
+    t.Fatal("a deleted key is still visible without locking")
+  }
+  clock.Advance(2 * time.Second)
+  if _, found := c.Get("b"); found {
+    t.Fatal("an expired item was returned")
+  }
+  c.Flush()
+  if _, found := c.Get("b"); found {
+    t.Fatal("an item was returned after Flush")
+  }
+}
+
+//新的键先加锁读取，未命中足够多次后加入索引
+func TestLockFreePromote(t *testing.T) {
+  c := New(WithShards(1))
+  c.SetLockFreeReads(true)
+  c.Set("new", 1)
+  s := c.shards[0]
+  if _, _, ok := s.getLockFree("new"); ok {
+    t.Fatal("a key written after enabling was in the index")
+  }
+  for i := 0; i < roPromoteMin; i++ {
+    if v, found := c.Get("new"); !found || v != 1 {
+      t.Fatalf("Get(new) = %v, %v, want 1", v, found)
+    }
+  }
+  if v, found, ok := s.getLockFree("new"); !ok || !found || v != 1 {
+    t.Fatalf("getLockFree(new) = %v, %v, %v after repeated misses, want 1", v, found, ok)
+  }
+}
+
+//维护访问顺序时不使用无锁读取
+func TestLockFreeOrdered(t *testing.T) {
+  c := New(WithShards(1), WithMaxEntries(10), WithLRU())
+  c.SetLockFreeReads(true)
+  if c.shards[0].ro.Load() != nil {
+    t.Fatal("lock-free reads were enabled on an LRU cache")
+  }
+  c = New(WithShards(1))
+  c.SetLockFreeReads(true)
+  c.SetLockFreeReads(false)
+  if c.shards[0].ro.Load() != nil {
+    t.Fatal("lock-free reads are still enabled after turning them off")
+  }
+}
+
+//无锁读取与写入并发时读到的总是某次写入的值
+func TestLockFreeConcurrent(t *testing.T) {
+  c := New(WithShards(4))
+  for i := 0; i < 100; i++ {
+    c.Set(fmt.Sprintf("k%d", i), 0)
+  }
+  c.SetLockFreeReads(true)
+  var wg sync.WaitGroup
+  for g := 0; g < 4; g++ {
+    wg.Add(2)
+    go func() {
+      defer wg.Done()
+      for n := 1; n <= 200; n++ {
+        c.Set(fmt.Sprintf("k%d", n%100), n)
+      }
+    }()
+    go func() {
+      defer wg.Done()
+      for n := 0; n < 1000; n++ {
+        v, found := c.Get(fmt.Sprintf("k%d", n%100))
+        if !found {
+          t.Error("a key that was never deleted was missing")
+          return
+        }
+        if _, ok := v.(int); !ok {
+          t.Errorf("read %v, want an int", v)
+          return
+        }
+      }
+    }()
+  }
+  wg.Wait()
+}
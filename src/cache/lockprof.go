@@ -0,0 +1,178 @@
+package cache
+
+import (
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+//锁等待时间直方图的分桶上限，最后一个桶统计超过 1 秒的等待
+var LockWaitBounds = [...]time.Duration {
+  time.Microsecond,
+  10 * time.Microsecond,
+  100 * time.Microsecond,
+  time.Millisecond,
+  10 * time.Millisecond,
+  100 * time.Millisecond,
+  time.Second,
+}
+
+//锁等待时间的直方图
+type LockHistogram struct {
+  Count     uint64  // 加锁次数
+  Contended uint64  // 需要等待的次数
+  Wait      time.Duration  // 等待时间总和
+  Buckets   [len(LockWaitBounds) + 1]uint64  // Buckets[i] 是等待时间在 LockWaitBounds[i-1] 之后、不超过 LockWaitBounds[i] 的次数
+}
+
+//按操作类型统计的锁等待时间
+type LockStats struct {
+  Read   LockHistogram  // 分片的读锁
+  Write  LockHistogram  // 分片的写锁
+  Global LockHistogram  // 锁住全部分片，例如修改配置和 Flush，每次计一次。按分片统计时为零值
+}
+
+//原子访问的直方图
+type lockHist struct {
+  count     uint64
+  contended uint64
+  wait      int64
+  buckets   [len(LockWaitBounds) + 1]uint64
+}
+
+func (h *lockHist) observe(d time.Duration) {
+  atomic.AddUint64(&h.count, 1)
+  if d <= 0 {
+    return
+  }
+  atomic.AddUint64(&h.contended, 1)
+  atomic.AddInt64(&h.wait, int64(d))
+  i := 0
+  for i < len(LockWaitBounds) && d > LockWaitBounds[i] {
+    i++
+  }
+  atomic.AddUint64(&h.buckets[i], 1)
+}
+
+func (h *lockHist) snapshot() LockHistogram {
+  s := LockHistogram {
+    Count: atomic.LoadUint64(&h.count),
+    Contended: atomic.LoadUint64(&h.contended),
+    Wait: time.Duration(atomic.LoadInt64(&h.wait)),
+  }
+  for i := range h.buckets {
+    s.Buckets[i] = atomic.LoadUint64(&h.buckets[i])
+  }
+  return s
+}
+
+//累加另一个直方图
+func (h *LockHistogram) add(o LockHistogram) {
+  h.Count += o.Count
+  h.Contended += o.Contended
+  h.Wait += o.Wait
+  for i := range h.Buckets {
+    h.Buckets[i] += o.Buckets[i]
+  }
+}
+
+//一个分片的锁等待统计
+type lockProfile struct {
+  read  lockHist
+  write lockHist
+}
+
+//分片的读写锁，开启统计时记录等待时间，没有竞争时不读取时钟
+type shardMutex struct {
+  sync.RWMutex
+  prof atomic.Pointer[lockProfile]  // 为 nil 时不统计
+}
+
+func (m *shardMutex) Lock() {
+  p := m.prof.Load()
+  if p == nil {
+    m.RWMutex.Lock()
+    return
+  }
+  if m.RWMutex.TryLock() {
+    p.write.observe(0)
+    return
+  }
+  start := time.Now()
+  m.RWMutex.Lock()
+  p.write.observe(time.Since(start))
+}
+
+func (m *shardMutex) RLock() {
+  p := m.prof.Load()
+  if p == nil {
+    m.RWMutex.RLock()
+    return
+  }
+  if m.RWMutex.TryRLock() {
+    p.read.observe(0)
+    return
+  }
+  start := time.Now()
+  m.RWMutex.RLock()
+  p.read.observe(time.Since(start))
+}
+
+//开启或关闭锁等待时间的统计，开启时清空之前的统计。统计结果通过 Stats 的 Locks 字段和 ShardLockStats 读取，
+//开启后每次加锁多一次原子操作，有竞争时多两次读取时钟
+func (c *Cache) SetLockProfiling(on bool) {
+  for _, s := range c.shards {
+    if on {
+      s.mu.prof.Store(&lockProfile{})
+    } else {
+      s.mu.prof.Store(nil)
+    }
+  }
+  if on {
+    c.lockGlobal.Store(&lockHist{})
+  } else {
+    c.lockGlobal.Store(nil)
+  }
+}
+
+//与 SetLockProfiling(true) 相同
+func WithLockProfiling() Option {
+  return func(o *options) { o.lockProfiling = true }
+}
+
+//清空锁等待时间的统计，没有开启时不做任何事
+func (c *Cache) resetLockStats() {
+  if c.lockGlobal.Load() != nil {
+    c.SetLockProfiling(true)
+  }
+}
+
+//返回全部分片合计的锁等待时间，没有开启统计时为零值
+func (c *Cache) lockStats() LockStats {
+  var st LockStats
+  g := c.lockGlobal.Load()
+  if g == nil {
+    return st
+  }
+  st.Global = g.snapshot()
+  for _, s := range c.ShardLockStats() {
+    st.Read.add(s.Read)
+    st.Write.add(s.Write)
+  }
+  return st
+}
+
+//返回每个分片的锁等待时间，用于发现集中在少数分片上的热点，没有开启统计时返回 nil
+func (c *Cache) ShardLockStats() []LockStats {
+  if c.lockGlobal.Load() == nil {
+    return nil
+  }
+  stats := make([]LockStats, len(c.shards))
+  for i, s := range c.shards {
+    if p := s.mu.prof.Load(); p != nil {
+      stats[i].Read = p.read.snapshot()
+      stats[i].Write = p.write.snapshot()
+    }
+  }
+  return stats
+}
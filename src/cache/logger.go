@@ -0,0 +1,47 @@
+package cache
+
+import (
+  "sync/atomic"
+  "time"
+)
+
+//日志接口，用于报告后台任务中没有调用方可以返回的错误和事件
+type Logger interface {
+  Debugf(format string, args ...interface{})
+  Warnf(format string, args ...interface{})
+  Errorf(format string, args ...interface{})
+}
+
+//两次定期清理之间淘汰的数据项达到该数量且超过当前数量的十分之一时视为淘汰风暴
+const evictionStorm = 1000
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Warnf(string, ...interface{}) {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+//返回设置的日志，没有设置时返回不输出任何内容的日志
+func (c *Cache) log() Logger {
+  if c.logger == nil {
+    return nopLogger{}
+  }
+  return c.logger
+}
+
+//记录一次定期清理的结果，两次清理之间淘汰过多时发出警告
+func (c *Cache) logSweep(removed int, d time.Duration) {
+  if removed > 0 {
+    c.log().Debugf("Expiration sweep removed %d items in %s.", removed, d)
+  }
+  evictions := atomic.LoadUint64(&c.stats.evictions)
+  last := atomic.SwapUint64(&c.evictionsSeen, evictions)
+  //统计数据被重置过
+  if evictions < last {
+    return
+  }
+  n := evictions - last
+  if n >= evictionStorm && n > uint64(c.Count()/10) {
+    c.log().Warnf("Evicted %d items since the last sweep, the capacity limit may be too small.", n)
+  }
+}
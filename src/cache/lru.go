@@ -0,0 +1,98 @@
+package cache
+
+import (
+  "container/list"
+  "time"
+)
+
+//创建一个 LRU 缓存系统，数据项数量超过 maxEntries 时淘汰最久未使用的数据项。
+//访问顺序在分片内维护，这里只使用一个分片以保证严格的 LRU 顺序
+func NewCacheWithLRU(maxEntries int, defaultExpiration, gcInterval time.Duration) *Cache {
+  return NewShardedCacheWithLRU(1, maxEntries, defaultExpiration, gcInterval)
+}
+
+//创建一个分片的 LRU 缓存系统，每个分片按各自的访问顺序淘汰，整体只是近似的 LRU
+func NewShardedCacheWithLRU(shards, maxEntries int, defaultExpiration, gcInterval time.Duration) *Cache {
+  return New(
+    WithShards(shards),
+    WithMaxEntries(maxEntries),
+    WithLRU(),
+    WithDefaultExpiration(defaultExpiration),
+    WithGCInterval(gcInterval),
+  )
+}
+
+//清空访问顺序，需要持有写锁
+func (s *shard) resetLRU() {
+  if s.arc != nil {
+    s.arc = newARC()
+  }
+  if s.lru == nil {
+    return
+  }
+  s.lru = list.New()
+  s.lruIndex = map[string]*list.Element{}
+}
+
+//将数据项标记为最近使用，持有读锁或写锁时均可调用
+func (s *shard) touch(k string) {
+  if s.arc != nil {
+    s.lruMu.Lock()
+    s.arc.access(k)
+    s.lruMu.Unlock()
+    return
+  }
+  if s.lru == nil {
+    return
+  }
+  s.lruMu.Lock()
+  if e, found := s.lruIndex[k]; found {
+    s.lru.MoveToFront(e)
+  } else {
+    s.lruIndex[k] = s.lru.PushFront(k)
+  }
+  s.lruMu.Unlock()
+}
+
+//从访问顺序中移除数据项，需要持有写锁
+func (s *shard) untouch(k string) {
+  if s.arc != nil {
+    s.arc.remove(k)
+  }
+  if s.lru == nil {
+    return
+  }
+  if e, found := s.lruIndex[k]; found {
+    s.lru.Remove(e)
+    delete(s.lruIndex, k)
+  }
+}
+
+//是否维护访问顺序
+func (s *shard) ordered() bool {
+  return s.lru != nil || s.arc != nil
+}
+
+//按淘汰顺序遍历数据项，LRU 模式下从最久未使用的开始，ARC 模式下按 ARC 的顺序，f 返回 false 时停止。需要持有写锁
+func (s *shard) victims(f func(k string, v Item) bool) {
+  if s.arc != nil {
+    s.arc.victims(func(k string) bool { return f(k, s.items[k]) })
+    return
+  }
+  if s.lru == nil {
+    for k, v := range s.items {
+      if !f(k, v) {
+        return
+      }
+    }
+    return
+  }
+  for e := s.lru.Back(); e != nil; {
+    prev := e.Prev()
+    k := e.Value.(string)
+    if !f(k, s.items[k]) {
+      return
+    }
+    e = prev
+  }
+}
@@ -0,0 +1,405 @@
+package memcached
+
+import (
+  "bufio"
+  "context"
+  "fmt"
+  "net"
+  "os"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "cache"
+)
+
+//以 memcached 文本协议对外提供缓存服务
+type Server struct {
+  c      *cache.Cache
+  start  time.Time
+  mu     sync.Mutex
+  ln     net.Listener
+  conns  map[net.Conn]struct{}
+  wg     sync.WaitGroup
+  closed bool
+}
+
+//创建一个对外提供 c 的服务
+func NewServer(c *cache.Cache) *Server {
+  return &Server {
+    c: c,
+    start: time.Now(),
+    conns: map[net.Conn]struct{}{},
+  }
+}
+
+//在 addr 上监听并以 memcached 文本协议提供 c，直到出错返回
+func ListenAndServe(addr string, c *cache.Cache) error {
+  return NewServer(c).ListenAndServe(addr)
+}
+
+//在 addr 上监听并提供服务，Shutdown 后返回 nil
+func (s *Server) ListenAndServe(addr string) error {
+  ln, err := net.Listen("tcp", addr)
+  if err != nil {
+    return err
+  }
+  return s.Serve(ln)
+}
+
+//在 ln 上接受连接并提供服务，Shutdown 后返回 nil
+func (s *Server) Serve(ln net.Listener) error {
+  s.mu.Lock()
+  if s.closed {
+    s.mu.Unlock()
+    ln.Close()
+    return fmt.Errorf("Server has been shut down.")
+  }
+  s.ln = ln
+  s.mu.Unlock()
+  for {
+    conn, err := ln.Accept()
+    if err != nil {
+      s.mu.Lock()
+      closed := s.closed
+      s.mu.Unlock()
+      if closed {
+        return nil
+      }
+      return err
+    }
+    s.mu.Lock()
+    s.conns[conn] = struct{}{}
+    s.wg.Add(1)
+    s.mu.Unlock()
+    go s.serveConn(conn)
+  }
+}
+
+//停止接受新连接，正在执行的命令完成后关闭连接，ctx 结束时强制关闭剩余的连接
+func (s *Server) Shutdown(ctx context.Context) error {
+  s.mu.Lock()
+  s.closed = true
+  if s.ln != nil {
+    s.ln.Close()
+  }
+  //唤醒阻塞在读取上的空闲连接
+  for conn := range s.conns {
+    conn.SetReadDeadline(time.Now())
+  }
+  s.mu.Unlock()
+
+  done := make(chan struct{})
+  go func() {
+    s.wg.Wait()
+    close(done)
+  }()
+  select {
+  case <-done:
+    return nil
+  case <-ctx.Done():
+    s.mu.Lock()
+    for conn := range s.conns {
+      conn.Close()
+    }
+    s.mu.Unlock()
+    return ctx.Err()
+  }
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+  defer func() {
+    s.mu.Lock()
+    delete(s.conns, conn)
+    s.mu.Unlock()
+    conn.Close()
+    s.wg.Done()
+  }()
+  r := bufio.NewReader(conn)
+  w := bufio.NewWriter(conn)
+  for {
+    line, err := readLine(r)
+    if err != nil {
+      return
+    }
+    args := strings.Fields(line)
+    if len(args) == 0 {
+      w.WriteString("ERROR\r\n")
+      w.Flush()
+      continue
+    }
+    quit, err := s.exec(r, w, args)
+    if err != nil {
+      //数据块格式错误时无法继续解析后续命令
+      w.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+      w.Flush()
+      return
+    }
+    //管道中的后续命令已经在缓冲区时合并写出
+    if r.Buffered() == 0 || quit {
+      if w.Flush() != nil {
+        return
+      }
+    }
+    s.mu.Lock()
+    closed := s.closed
+    s.mu.Unlock()
+    if quit || closed {
+      w.Flush()
+      return
+    }
+  }
+}
+
+//执行一条命令，返回是否需要关闭连接，读取数据块出错时返回错误
+func (s *Server) exec(r *bufio.Reader, w *bufio.Writer, args []string) (bool, error) {
+  name := args[0]
+  args = args[1:]
+  //noreply 时不返回结果
+  noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+  if noreply {
+    args = args[:len(args)-1]
+  }
+  reply := func(msg string) {
+    if !noreply {
+      w.WriteString(msg + "\r\n")
+    }
+  }
+  switch name {
+  case "get", "gets":
+    if len(args) == 0 {
+      w.WriteString("ERROR\r\n")
+      break
+    }
+    for _, k := range args {
+      s.writeValue(w, k, name == "gets")
+    }
+    w.WriteString("END\r\n")
+  case "set", "add", "replace", "cas":
+    return false, s.store(r, name, args, reply)
+  case "delete":
+    if len(args) != 1 {
+      w.WriteString("ERROR\r\n")
+      break
+    }
+    if _, found := s.c.GetAndDelete(args[0]); found {
+      reply("DELETED")
+    } else {
+      reply("NOT_FOUND")
+    }
+  case "incr", "decr":
+    s.incr(name, args, reply)
+  case "touch":
+    if len(args) != 2 {
+      w.WriteString("ERROR\r\n")
+      break
+    }
+    exptime, err := strconv.ParseInt(args[1], 10, 64)
+    if err != nil {
+      reply("CLIENT_ERROR invalid exptime argument")
+      break
+    }
+    var touched bool
+    switch d, alive := expiration(exptime, time.Now()); {
+    case !alive:
+      _, touched = s.c.GetAndDelete(args[0])
+    case d == cache.NoExpiration:
+      touched = s.c.Persist(args[0])
+    default:
+      touched = s.c.Expire(args[0], d)
+    }
+    if touched {
+      reply("TOUCHED")
+    } else {
+      reply("NOT_FOUND")
+    }
+  case "flush_all":
+    delay := int64(0)
+    if len(args) > 0 {
+      var err error
+      if delay, err = strconv.ParseInt(args[0], 10, 64); err != nil || delay < 0 {
+        reply("CLIENT_ERROR bad command line format")
+        break
+      }
+    }
+    if delay == 0 {
+      s.c.Flush()
+    } else {
+      time.AfterFunc(time.Duration(delay)*time.Second, s.c.Flush)
+    }
+    reply("OK")
+  case "stats":
+    s.stats(w)
+  case "version":
+    w.WriteString("VERSION 1.6.0\r\n")
+  case "verbosity":
+    reply("OK")
+  case "quit":
+    return true, nil
+  default:
+    w.WriteString("ERROR\r\n")
+  }
+  return false, nil
+}
+
+//写出一个数据项，不存在时不写任何内容
+func (s *Server) writeValue(w *bufio.Writer, k string, withCas bool) {
+  v, version, found := s.c.GetWithVersion(k)
+  if !found {
+    return
+  }
+  data, flags := format(v)
+  if withCas {
+    fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", k, flags, len(data), version)
+  } else {
+    fmt.Fprintf(w, "VALUE %s %d %d\r\n", k, flags, len(data))
+  }
+  w.WriteString(data)
+  w.WriteString("\r\n")
+}
+
+//set/add/replace <key> <flags> <exptime> <bytes> [noreply]
+//cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (s *Server) store(r *bufio.Reader, name string, args []string, reply func(string)) error {
+  n := 4
+  if name == "cas" {
+    n = 5
+  }
+  if len(args) != n {
+    return fmt.Errorf("bad command line format")
+  }
+  k := args[0]
+  flags, ferr := strconv.ParseUint(args[1], 10, 32)
+  exptime, eerr := strconv.ParseInt(args[2], 10, 64)
+  size, serr := strconv.Atoi(args[3])
+  if ferr != nil || eerr != nil || serr != nil || size < 0 || size > maxValueLen {
+    return fmt.Errorf("bad command line format")
+  }
+  data, err := readData(r, size)
+  if err != nil {
+    return err
+  }
+  if !checkKey(k) {
+    reply("CLIENT_ERROR bad command line format")
+    return nil
+  }
+  v := value(data, uint32(flags))
+  d, alive := expiration(exptime, time.Now())
+  if !alive {
+    //已经过期的数据项相当于删除
+    s.c.Delete(k)
+    reply("STORED")
+    return nil
+  }
+  switch name {
+  case "set":
+    s.c.Set(k, v, cache.WithTTL(d))
+    reply("STORED")
+  case "add":
+    if s.c.Add(k, v, d) != nil {
+      reply("NOT_STORED")
+      return nil
+    }
+    reply("STORED")
+  case "replace":
+    if s.c.Replace(k, v, d) != nil {
+      reply("NOT_STORED")
+      return nil
+    }
+    reply("STORED")
+  case "cas":
+    unique, err := strconv.ParseUint(args[4], 10, 64)
+    if err != nil || unique == 0 {
+      reply("CLIENT_ERROR bad command line format")
+      return nil
+    }
+    switch err := s.c.CompareAndSwap(k, unique, v, d); err {
+    case nil:
+      reply("STORED")
+    case cache.ErrNotFound:
+      reply("NOT_FOUND")
+    case cache.ErrVersionMismatch:
+      reply("EXISTS")
+    default:
+      reply("SERVER_ERROR " + err.Error())
+    }
+  }
+  return nil
+}
+
+//incr/decr <key> <value> [noreply]，与 memcached 一样 decr 不会小于 0
+func (s *Server) incr(name string, args []string, reply func(string)) {
+  if len(args) != 2 {
+    reply("ERROR")
+    return
+  }
+  n, err := strconv.ParseInt(args[1], 10, 64)
+  if err != nil || n < 0 {
+    reply("CLIENT_ERROR invalid numeric delta argument")
+    return
+  }
+  //按版本号比较后写入，保留原有的过期时间，期间被其他连接修改时重试
+  for {
+    v, version, found := s.c.GetWithVersion(args[0])
+    if !found {
+      reply("NOT_FOUND")
+      return
+    }
+    cur, ok := v.(int64)
+    if !ok {
+      reply("CLIENT_ERROR cannot increment or decrement non-numeric value")
+      return
+    }
+    _, exp, found := s.c.GetWithExpiration(args[0])
+    d := cache.NoExpiration
+    if !exp.IsZero() {
+      d = time.Until(exp)
+    }
+    if !found || (!exp.IsZero() && d <= 0) {
+      reply("NOT_FOUND")
+      return
+    }
+    nv := cur + n
+    if name == "decr" {
+      nv = cur - n
+      if nv < 0 {
+        nv = 0
+      }
+    }
+    //只有被其他连接修改时重试
+    switch err := s.c.CompareAndSwap(args[0], version, nv, d); err {
+    case nil:
+      reply(strconv.FormatInt(nv, 10))
+      return
+    case cache.ErrNotFound:
+      reply("NOT_FOUND")
+      return
+    case cache.ErrVersionMismatch:
+    default:
+      reply("SERVER_ERROR " + err.Error())
+      return
+    }
+  }
+}
+
+func (s *Server) stats(w *bufio.Writer) {
+  st := s.c.Stats()
+  stat := func(name string, v interface{}) {
+    fmt.Fprintf(w, "STAT %s %v\r\n", name, v)
+  }
+  stat("pid", os.Getpid())
+  stat("uptime", int64(time.Since(s.start)/time.Second))
+  stat("time", time.Now().Unix())
+  stat("version", "1.6.0")
+  stat("curr_items", st.Items)
+  stat("total_items", st.Sets)
+  stat("get_hits", st.Hits)
+  stat("get_misses", st.Misses)
+  stat("cmd_get", st.Hits+st.Misses)
+  stat("cmd_set", st.Sets)
+  stat("delete_hits", st.Deletes)
+  stat("evictions", st.Evictions)
+  stat("expired_unfetched", st.Expired)
+  w.WriteString("END\r\n")
+}
@@ -0,0 +1,178 @@
+package memcached
+
+import (
+  "bufio"
+  "context"
+  "net"
+  "strings"
+  "testing"
+  "time"
+
+  "cache"
+)
+
+//启动服务并返回一个已连接的客户端，测试结束时关闭服务
+func startServer(t *testing.T, c *cache.Cache) (net.Conn, *bufio.Reader) {
+  t.Helper()
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  s := NewServer(c)
+  go s.Serve(ln)
+  conn, err := net.Dial("tcp", ln.Addr().String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() {
+    conn.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := s.Shutdown(ctx); err != nil {
+      t.Errorf("Shutdown: %v", err)
+    }
+  })
+  return conn, bufio.NewReader(conn)
+}
+
+//发送命令并读取 n 行回复
+func command(t *testing.T, conn net.Conn, r *bufio.Reader, cmd string, n int) []string {
+  t.Helper()
+  conn.SetDeadline(time.Now().Add(5 * time.Second))
+  if _, err := conn.Write([]byte(cmd + "\r\n")); err != nil {
+    t.Fatal(err)
+  }
+  lines := make([]string, n)
+  for i := range lines {
+    line, err := r.ReadString('\n')
+    if err != nil {
+      t.Fatalf("%q: %v", cmd, err)
+    }
+    lines[i] = strings.TrimSuffix(line, "\r\n")
+  }
+  return lines
+}
+
+//返回 gets 得到的 cas 值
+func casUnique(t *testing.T, conn net.Conn, r *bufio.Reader, k string) string {
+  t.Helper()
+  lines := command(t, conn, r, "gets "+k, 3)
+  fields := strings.Fields(lines[0])
+  if len(fields) != 5 {
+    t.Fatalf("gets %s = %q", k, lines)
+  }
+  return fields[4]
+}
+
+func TestIncr(t *testing.T) {
+  conn, r := startServer(t, cache.New())
+  command(t, conn, r, "set x 0 0 1\r\n5", 1)
+  if got := command(t, conn, r, "incr x 3", 1)[0]; got != "8" {
+    t.Fatalf("incr x 3 = %q, want 8", got)
+  }
+  if got := command(t, conn, r, "decr x 10", 1)[0]; got != "0" {
+    t.Fatalf("decr x 10 = %q, want 0", got)
+  }
+  if got := command(t, conn, r, "incr missing 1", 1)[0]; got != "NOT_FOUND" {
+    t.Fatalf("incr missing 1 = %q, want NOT_FOUND", got)
+  }
+}
+
+func TestIncrFrozen(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  command(t, conn, r, "set x 0 0 1\r\n5", 1)
+  c.Freeze()
+  if got := command(t, conn, r, "incr x 1", 1)[0]; !strings.HasPrefix(got, "SERVER_ERROR") {
+    t.Fatalf("incr x 1 on a frozen cache = %q, want SERVER_ERROR", got)
+  }
+}
+
+func TestCas(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  command(t, conn, r, "set x 0 0 1\r\na", 1)
+  unique := casUnique(t, conn, r, "x")
+  if got := command(t, conn, r, "cas x 0 0 1 "+unique+"\r\nb", 1)[0]; got != "STORED" {
+    t.Fatalf("cas with the current unique = %q, want STORED", got)
+  }
+  if got := command(t, conn, r, "cas x 0 0 1 "+unique+"\r\nc", 1)[0]; got != "EXISTS" {
+    t.Fatalf("cas with a stale unique = %q, want EXISTS", got)
+  }
+  unique = casUnique(t, conn, r, "x")
+  c.Freeze()
+  if got := command(t, conn, r, "cas x 0 0 1 "+unique+"\r\nd", 1)[0]; !strings.HasPrefix(got, "SERVER_ERROR") {
+    t.Fatalf("cas on a frozen cache = %q, want SERVER_ERROR", got)
+  }
+}
+
+func TestStore(t *testing.T) {
+  conn, r := startServer(t, cache.New())
+  if got := command(t, conn, r, "set a 5 0 3\r\nfoo", 1)[0]; got != "STORED" {
+    t.Fatalf("set = %q, want STORED", got)
+  }
+  if got := command(t, conn, r, "get a", 3); got[0] != "VALUE a 5 3" || got[1] != "foo" || got[2] != "END" {
+    t.Fatalf("get a = %q", got)
+  }
+  if got := command(t, conn, r, "add a 0 0 3\r\nbar", 1)[0]; got != "NOT_STORED" {
+    t.Fatalf("add of an existing key = %q, want NOT_STORED", got)
+  }
+  if got := command(t, conn, r, "replace b 0 0 3\r\nbar", 1)[0]; got != "NOT_STORED" {
+    t.Fatalf("replace of a missing key = %q, want NOT_STORED", got)
+  }
+  if got := command(t, conn, r, "add b 0 0 3\r\nbar", 1)[0]; got != "STORED" {
+    t.Fatalf("add of a missing key = %q, want STORED", got)
+  }
+  if got := command(t, conn, r, "replace a 0 0 3\r\nbaz", 1)[0]; got != "STORED" {
+    t.Fatalf("replace of an existing key = %q, want STORED", got)
+  }
+  want := []string{"VALUE a 0 3", "baz", "VALUE b 0 3", "bar", "END"}
+  if got := command(t, conn, r, "get a missing b", 5); strings.Join(got, "|") != strings.Join(want, "|") {
+    t.Fatalf("get a missing b = %q, want %q", got, want)
+  }
+  if got := command(t, conn, r, "set a 0 -1 1\r\nx", 1)[0]; got != "STORED" {
+    t.Fatalf("set with a negative exptime = %q, want STORED", got)
+  }
+  if got := command(t, conn, r, "get a", 1)[0]; got != "END" {
+    t.Fatalf("get of an item stored already expired = %q, want END", got)
+  }
+}
+
+func TestDeleteTouch(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  command(t, conn, r, "set a 0 0 1\r\nx", 1)
+  if got := command(t, conn, r, "touch a 100", 1)[0]; got != "TOUCHED" {
+    t.Fatalf("touch = %q, want TOUCHED", got)
+  }
+  if _, e, _ := c.GetWithExpiration("a"); e.IsZero() {
+    t.Fatal("touch did not set an expiration")
+  }
+  if got := command(t, conn, r, "touch a 0", 1)[0]; got != "TOUCHED" {
+    t.Fatalf("touch a 0 = %q, want TOUCHED", got)
+  }
+  if _, e, _ := c.GetWithExpiration("a"); !e.IsZero() {
+    t.Fatal("touch with exptime 0 did not remove the expiration")
+  }
+  if got := command(t, conn, r, "delete a", 1)[0]; got != "DELETED" {
+    t.Fatalf("delete = %q, want DELETED", got)
+  }
+  if got := command(t, conn, r, "delete a", 1)[0]; got != "NOT_FOUND" {
+    t.Fatalf("delete of a missing key = %q, want NOT_FOUND", got)
+  }
+  if got := command(t, conn, r, "touch a 100", 1)[0]; got != "NOT_FOUND" {
+    t.Fatalf("touch of a missing key = %q, want NOT_FOUND", got)
+  }
+}
+
+//noreply 的命令没有回复，下一条命令的回复紧接着返回
+func TestNoreply(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  if got := command(t, conn, r, "set a 0 0 1 noreply\r\nx\r\ndelete missing noreply\r\nflush_all noreply\r\nversion", 1)[0]; got != "VERSION 1.6.0" {
+    t.Fatalf("reply after noreply commands = %q, want VERSION 1.6.0", got)
+  }
+  if c.Count() != 0 {
+    t.Fatalf("Count() = %d after flush_all, want 0", c.Count())
+  }
+}
@@ -0,0 +1,103 @@
+package memcached
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "time"
+
+  "cache"
+)
+
+//单个数据项的最大长度，与 memcached 的 item_size_max 默认值一致
+const maxValueLen = 1 << 20
+
+//键的最大长度，与 memcached 一致
+const maxKeyLen = 250
+
+//超过 30 天的 exptime 按 Unix 时间戳处理，与 memcached 一致
+const relativeExpLimit = 30 * 24 * 60 * 60
+
+//flags 不为 0 时保存的数据项，flags 为 0 时直接保存值以便与其他前端共享
+type Value struct {
+  Flags uint32
+  Data  string
+}
+
+//让 gob 格式的 Save 和 Load 能保存和还原 Value
+func init() {
+  cache.RegisterType(Value{})
+}
+
+//读取一行命令，去掉结尾的 \r\n
+func readLine(r *bufio.Reader) (string, error) {
+  line, err := r.ReadString('\n')
+  if err != nil {
+    return "", err
+  }
+  return strings.TrimRight(line, "\r\n"), nil
+}
+
+//读取 n 字节的数据块及其后的 \r\n
+func readData(r *bufio.Reader, n int) (string, error) {
+  buf := make([]byte, n+2)
+  if _, err := io.ReadFull(r, buf); err != nil {
+    return "", err
+  }
+  if buf[n] != '\r' || buf[n+1] != '\n' {
+    return "", fmt.Errorf("bad data chunk")
+  }
+  return string(buf[:n]), nil
+}
+
+//把 exptime 换算成生存时间，第二个返回值为 false 表示已经过期
+func expiration(exptime int64, now time.Time) (time.Duration, bool) {
+  switch {
+  case exptime == 0:
+    return cache.NoExpiration, true
+  case exptime < 0:
+    return 0, false
+  case exptime <= relativeExpLimit:
+    return time.Duration(exptime) * time.Second, true
+  }
+  d := time.Unix(exptime, 0).Sub(now)
+  return d, d > 0
+}
+
+func checkKey(k string) bool {
+  if len(k) == 0 || len(k) > maxKeyLen {
+    return false
+  }
+  for i := 0; i < len(k); i++ {
+    if k[i] <= ' ' || k[i] == 0x7f {
+      return false
+    }
+  }
+  return true
+}
+
+//规范格式的非负整数按 int64 保存以支持 incr，其余按字符串保存
+func value(s string, flags uint32) interface{} {
+  if flags != 0 {
+    return Value{Flags: flags, Data: s}
+  }
+  if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 && strconv.FormatInt(n, 10) == s {
+    return n
+  }
+  return s
+}
+
+//把数据项格式化为返回给客户端的数据和 flags
+func format(v interface{}) (string, uint32) {
+  switch v := v.(type) {
+  case Value:
+    return v.Data, v.Flags
+  case string:
+    return v, 0
+  case []byte:
+    return string(v), 0
+  }
+  return fmt.Sprint(v), 0
+}
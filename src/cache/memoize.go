@@ -0,0 +1,50 @@
+package cache
+
+import (
+  "fmt"
+  "sync/atomic"
+  "time"
+)
+
+//每次 Memoize 分配一个编号作为键的前缀，不同函数的结果互不覆盖
+var memoSeq uint64
+
+//缓存中保存的失败结果
+type memoErr struct {
+  err error
+}
+
+//缓存的失败结果不持久化，持久化时视为不存在
+func transient(item Item) bool {
+  _, ok := item.Object.(memoErr)
+  return ok
+}
+
+//包装 fn，以生存时间 ttl 缓存每个参数的结果，同一个参数的并发调用合并为一次。
+//失败的结果以 ttl 的十分之一缓存，避免持续失败时每次都调用 fn
+func Memoize[K comparable, V any](c *Cache, ttl time.Duration, fn func(K) (V, error)) func(K) (V, error) {
+  return MemoizeWithErrorTTL(c, ttl, ttl/10, fn)
+}
+
+//与 Memoize 相同，失败的结果以 errTTL 缓存，errTTL 小于等于 0 时不缓存失败的结果
+func MemoizeWithErrorTTL[K comparable, V any](c *Cache, ttl, errTTL time.Duration, fn func(K) (V, error)) func(K) (V, error) {
+  prefix := fmt.Sprintf("memo:%d:", atomic.AddUint64(&memoSeq, 1))
+  return func(arg K) (V, error) {
+    var zero V
+    k := prefix + typedKey(arg)
+    v, computed, err := c.GetOrSetWithStatus(k, ttl, func() (interface{}, error) {
+      return fn(arg)
+    })
+    if err != nil {
+      if computed && errTTL > 0 {
+        c.Set(k, memoErr{err}, WithTTL(errTTL))
+      }
+      return zero, err
+    }
+    if e, ok := v.(memoErr); ok {
+      return zero, e.err
+    }
+    r, _ := As[V](v, true)
+    return r, nil
+  }
+}
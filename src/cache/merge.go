@@ -0,0 +1,28 @@
+package cache
+
+//读取的数据项与缓存中未过期的同名数据项冲突时调用，返回 true 表示使用读取的数据项。
+//在持有分片的写锁时调用，不能在其中读写缓存
+type MergeFunc func(key string, existing, incoming Item) bool
+
+//保留缓存中的数据项，默认的合并方式
+func KeepExisting(key string, existing, incoming Item) bool {
+  return false
+}
+
+//总是使用读取的数据项
+func OverwriteAll(key string, existing, incoming Item) bool {
+  return true
+}
+
+//使用写入时间较晚的数据项，时间相同或读取的数据项没有写入时间时保留缓存中的数据项
+func KeepNewest(key string, existing, incoming Item) bool {
+  return incoming.Created > existing.Created
+}
+
+//设置 Load、LoadFile、LoadStream 和 ImportJSONL 遇到冲突时的合并方式，nil 表示 KeepExisting。
+//替换已有的数据项时 OnRemoved 收到 ReasonReplaced
+func (c *Cache) SetMergePolicy(f MergeFunc) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.merge = f
+}
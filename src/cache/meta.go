@@ -0,0 +1,64 @@
+package cache
+
+import (
+  "sync/atomic"
+  "time"
+)
+
+//数据项的访问记录，字段均为原子访问，持有读锁时也可以更新
+type accessInfo struct {
+  hits uint64
+  last int64
+}
+
+//数据项的元数据
+type ItemInfo struct {
+  CreatedAt      time.Time  // 写入的时间，修改过期时间或自增不会改变
+  LastAccessedAt time.Time  // 最近一次读取的时间，写入后没有被读取过时为零值
+  Hits           uint64     // 写入后被读取的次数
+  Expiration     time.Time  // 永不过期时为零值
+  Cost           int64
+  Version        uint64
+}
+
+//记下一次读取，持有读锁或写锁时均可调用
+func (s *shard) accessed(k string, item Item) {
+  s.accessedAt(k, item, s.c.now())
+}
+
+//已经读取了时钟时使用
+func (s *shard) accessedAt(k string, item Item, now int64) {
+  if a := item.access; a != nil {
+    atomic.AddUint64(&a.hits, 1)
+    atomic.StoreInt64(&a.last, now)
+  }
+  if h := s.hot.Load(); h != nil {
+    h.add(k, now)
+  }
+}
+
+//返回数据项的元数据，不算作一次读取
+func (c *Cache) Inspect(k string) (ItemInfo, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  item, found := s.items[k]
+  s.mu.RUnlock()
+  if !found || c.expired(item) {
+    return ItemInfo{}, false
+  }
+  info := ItemInfo {
+    CreatedAt: time.Unix(0, item.Created),
+    Cost: item.Cost,
+    Version: item.Version,
+  }
+  if item.Expiration != 0 {
+    info.Expiration = time.Unix(0, item.Expiration)
+  }
+  if a := item.access; a != nil {
+    info.Hits = atomic.LoadUint64(&a.hits)
+    if last := atomic.LoadInt64(&a.last); last != 0 {
+      info.LastAccessedAt = time.Unix(0, last)
+    }
+  }
+  return info, true
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+  "testing"
+  "time"
+)
+
+func TestInspect(t *testing.T) {
+  start := time.Unix(1000, 0)
+  clock := NewFakeClock(start)
+  c := New(WithClock(clock))
+  c.Set("a", int64(1), WithTTL(time.Hour), WithCost(3))
+  info, found := c.Inspect("a")
+  if !found {
+    t.Fatal("Inspect(a) found nothing")
+  }
+  if !info.CreatedAt.Equal(start) || !info.Expiration.Equal(start.Add(time.Hour)) || info.Cost != 3 {
+    t.Fatalf("Inspect(a) = %+v, want created at %v, expiring an hour later, cost 3", info, start)
+  }
+  if info.Hits != 0 || !info.LastAccessedAt.IsZero() {
+    t.Fatalf("Inspect(a) = %+v before any read, want no hits", info)
+  }
+  clock.Advance(time.Second)
+  c.Get("a")
+  clock.Advance(time.Second)
+  c.Get("a")
+  c.Inspect("a")
+  if info, _ = c.Inspect("a"); info.Hits != 2 || !info.LastAccessedAt.Equal(start.Add(2*time.Second)) {
+    t.Fatalf("Inspect(a) = %+v after two reads, want 2 hits, last at %v", info, start.Add(2*time.Second))
+  }
+  //修改过期时间和自增不改变写入时间
+  c.Expire("a", time.Minute)
+  if _, err := c.Increment("a", 1); err != nil {
+    t.Fatal(err)
+  }
+  if info, _ = c.Inspect("a"); !info.CreatedAt.Equal(start) {
+    t.Fatalf("CreatedAt = %v after Expire and Increment, want %v", info.CreatedAt, start)
+  }
+  //重新写入时重置
+  c.Set("a", int64(5))
+  if info, _ = c.Inspect("a"); !info.CreatedAt.Equal(start.Add(2*time.Second)) || info.Hits != 0 {
+    t.Fatalf("Inspect(a) = %+v after Set, want a new creation time and no hits", info)
+  }
+}
+
+func TestInspectMissing(t *testing.T) {
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := New(WithClock(clock), WithGCInterval(0))
+  if _, found := c.Inspect("missing"); found {
+    t.Fatal("Inspect of a missing key found an item")
+  }
+  c.Set("a", 1, WithTTL(time.Second))
+  clock.Advance(2 * time.Second)
+  if _, found := c.Inspect("a"); found {
+    t.Fatal("Inspect of an expired item found it")
+  }
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+  "cache"
+
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+//导出单个缓存统计数据的 Prometheus 采集器，name 作为 cache 标签区分不同的缓存
+type Collector struct {
+  c             *cache.Cache
+  hits          *prometheus.Desc
+  misses        *prometheus.Desc
+  hitRatio      *prometheus.Desc
+  items         *prometheus.Desc
+  evictions     *prometheus.Desc
+  expired       *prometheus.Desc
+  cost          *prometheus.Desc
+  sweepDuration *prometheus.Desc
+  loads         *prometheus.Desc
+  loadErrors    *prometheus.Desc
+  loadsInFlight *prometheus.Desc
+  coalesced     *prometheus.Desc
+  loadDuration  *prometheus.Desc
+  lockWait      *prometheus.Desc
+}
+
+//创建一个采集器，使用 prometheus.MustRegister 注册后生效
+func NewCollector(c *cache.Cache, name string) *Collector {
+  labels := prometheus.Labels{"cache": name}
+  desc := func(fqName, help string) *prometheus.Desc {
+    return prometheus.NewDesc(fqName, help, nil, labels)
+  }
+  return &Collector {
+    c: c,
+    hits: desc("cache_hits_total", "Number of lookups that found an item."),
+    misses: desc("cache_misses_total", "Number of lookups that found no item."),
+    hitRatio: desc("cache_hit_ratio", "Hits divided by lookups since the last stats reset."),
+    items: desc("cache_items", "Number of items currently stored, including expired ones not yet swept."),
+    evictions: desc("cache_evictions_total", "Number of items evicted by capacity limits."),
+    expired: desc("cache_expired_total", "Number of expired items removed."),
+    cost: desc("cache_cost", "Total cost of stored items; a memory estimate when costs are set in bytes."),
+    sweepDuration: desc("cache_gc_sweep_duration_seconds", "Duration of the last expiration sweep."),
+    loads: desc("cache_loads_total", "Number of completed loads and computations."),
+    loadErrors: desc("cache_load_errors_total", "Number of loads that failed or panicked, not counting ErrNotFound."),
+    loadsInFlight: desc("cache_loads_in_flight", "Number of loads currently running."),
+    coalesced: desc("cache_load_coalesced_total", "Number of lookups that waited for a load of the same key instead of loading."),
+    loadDuration: desc("cache_load_duration_seconds", "Load latency; quantiles cover the most recent loads."),
+    lockWait: prometheus.NewDesc("cache_lock_wait_seconds", "Time spent waiting for shard locks by lock type, recorded when lock profiling is on.", []string{"op"}, labels),
+  }
+}
+
+func (m *Collector) Describe(ch chan<- *prometheus.Desc) {
+  ch <- m.hits
+  ch <- m.misses
+  ch <- m.hitRatio
+  ch <- m.items
+  ch <- m.evictions
+  ch <- m.expired
+  ch <- m.cost
+  ch <- m.sweepDuration
+  ch <- m.loads
+  ch <- m.loadErrors
+  ch <- m.loadsInFlight
+  ch <- m.coalesced
+  ch <- m.loadDuration
+  ch <- m.lockWait
+}
+
+func (m *Collector) Collect(ch chan<- prometheus.Metric) {
+  s := m.c.Stats()
+  ratio := 0.0
+  if lookups := s.Hits + s.Misses; lookups > 0 {
+    ratio = float64(s.Hits) / float64(lookups)
+  }
+  ch <- prometheus.MustNewConstMetric(m.hits, prometheus.CounterValue, float64(s.Hits))
+  ch <- prometheus.MustNewConstMetric(m.misses, prometheus.CounterValue, float64(s.Misses))
+  ch <- prometheus.MustNewConstMetric(m.hitRatio, prometheus.GaugeValue, ratio)
+  ch <- prometheus.MustNewConstMetric(m.items, prometheus.GaugeValue, float64(s.Items))
+  ch <- prometheus.MustNewConstMetric(m.evictions, prometheus.CounterValue, float64(s.Evictions))
+  ch <- prometheus.MustNewConstMetric(m.expired, prometheus.CounterValue, float64(s.Expired))
+  ch <- prometheus.MustNewConstMetric(m.cost, prometheus.GaugeValue, float64(s.Cost))
+  ch <- prometheus.MustNewConstMetric(m.sweepDuration, prometheus.GaugeValue, s.SweepDuration.Seconds())
+  ch <- prometheus.MustNewConstMetric(m.loads, prometheus.CounterValue, float64(s.Loads))
+  ch <- prometheus.MustNewConstMetric(m.loadErrors, prometheus.CounterValue, float64(s.LoadErrors))
+  ch <- prometheus.MustNewConstMetric(m.loadsInFlight, prometheus.GaugeValue, float64(s.LoadsInFlight))
+  ch <- prometheus.MustNewConstMetric(m.coalesced, prometheus.CounterValue, float64(s.Coalesced))
+  ch <- prometheus.MustNewConstSummary(m.loadDuration, s.Loads, s.LoadTime.Seconds(), map[float64]float64 {
+    0.5: s.LoadLatency.P50.Seconds(),
+    0.9: s.LoadLatency.P90.Seconds(),
+    0.99: s.LoadLatency.P99.Seconds(),
+  })
+  ch <- lockHistogram(m.lockWait, s.Locks.Read, "read")
+  ch <- lockHistogram(m.lockWait, s.Locks.Write, "write")
+  ch <- lockHistogram(m.lockWait, s.Locks.Global, "global")
+}
+
+//转换为累计的 Prometheus 直方图，没有等待的加锁计入第一个桶
+func lockHistogram(d *prometheus.Desc, h cache.LockHistogram, op string) prometheus.Metric {
+  buckets := make(map[float64]uint64, len(cache.LockWaitBounds))
+  n := h.Count - h.Contended
+  for i, b := range cache.LockWaitBounds {
+    n += h.Buckets[i]
+    buckets[b.Seconds()] = n
+  }
+  return prometheus.MustNewConstHistogram(d, h.Count, h.Wait.Seconds(), buckets, op)
+}
@@ -0,0 +1,43 @@
+package msgpackcodec
+
+import (
+  "io"
+
+  "cache"
+
+  "github.com/vmihailenco/msgpack/v5"
+)
+
+//转储文件头中的编码
+const Codec byte = 3
+
+func init() {
+  if err := cache.RegisterDumpSerializer(Codec, Serializer{}); err != nil {
+    panic(err)
+  }
+}
+
+//基于 MessagePack 的序列化方式，便于其他语言读取，读回的 Object 为 msgpack 的默认类型。
+//导入这个包后 SaveToFileWith 和 LoadFile 也可以使用
+type Serializer struct{}
+
+func (Serializer) Encode(w io.Writer, items map[string]cache.Item) error {
+  return msgpack.NewEncoder(w).Encode(items)
+}
+
+func (Serializer) Decode(r io.Reader) (map[string]cache.Item, error) {
+  dec := msgpack.NewDecoder(r)
+  return cache.DecodeChunks(func(items *map[string]cache.Item) error { return dec.Decode(items) })
+}
+
+func (Serializer) NewEncoder(w io.Writer) cache.ChunkEncoder {
+  return encoder{msgpack.NewEncoder(w)}
+}
+
+type encoder struct {
+  enc *msgpack.Encoder
+}
+
+func (e encoder) Encode(items map[string]cache.Item) error {
+  return e.enc.Encode(items)
+}
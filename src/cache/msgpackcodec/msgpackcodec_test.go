@@ -0,0 +1,34 @@
+package msgpackcodec
+
+import (
+  "path/filepath"
+  "testing"
+
+  "cache"
+)
+
+func TestSaveLoadFile(t *testing.T) {
+  c := cache.New()
+  c.Set("a", "x")
+  c.Set("b", int8(2))
+  name := filepath.Join(t.TempDir(), "dump")
+  if err := c.SaveToFileWith(name, Serializer{}); err != nil {
+    t.Fatal(err)
+  }
+  d := cache.New()
+  if err := d.LoadFile(name); err != nil {
+    t.Fatal(err)
+  }
+  if v, ok := d.Get("a"); !ok || v != "x" {
+    t.Fatalf("a = %v, %v", v, ok)
+  }
+  if _, ok := d.Get("b"); !ok {
+    t.Fatal("b missing")
+  }
+}
+
+func TestRegisterTwice(t *testing.T) {
+  if err := cache.RegisterDumpSerializer(Codec, Serializer{}); err == nil {
+    t.Fatal("registered codec twice")
+  }
+}
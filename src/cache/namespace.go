@@ -0,0 +1,141 @@
+package cache
+
+import (
+  "strings"
+  "sync"
+  "time"
+)
+
+//命名空间内的键在底层缓存中以 "名称\x00" 为前缀
+const namespaceSep = "\x00"
+
+//缓存的命名空间视图，键与其他命名空间隔离，有独立的默认过期时间和 Flush
+type Namespace struct {
+  c                 *Cache
+  name              string
+  prefix            string
+  mu                sync.RWMutex
+  defaultExpiration time.Duration
+}
+
+//返回名为 name 的命名空间，同名的调用返回同一个视图，默认过期时间初始为缓存的默认值
+func (c *Cache) Namespace(name string) *Namespace {
+  c.nsMu.Lock()
+  defer c.nsMu.Unlock()
+  if n, found := c.namespaces[name]; found {
+    return n
+  }
+  n := &Namespace {
+    c: c,
+    name: name,
+    prefix: name + namespaceSep,
+    defaultExpiration: c.defaultExpiration,
+  }
+  if c.namespaces == nil {
+    c.namespaces = map[string]*Namespace{}
+  }
+  c.namespaces[name] = n
+  return n
+}
+
+//返回命名空间的名称
+func (n *Namespace) Name() string {
+  return n.name
+}
+
+//设置命名空间的默认过期时间
+func (n *Namespace) SetDefaultExpiration(d time.Duration) {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  n.defaultExpiration = d
+}
+
+//把 DefaultExpiration 换成命名空间自己的默认值
+func (n *Namespace) expiration(d time.Duration) time.Duration {
+  if d != DefaultExpiration {
+    return d
+  }
+  n.mu.RLock()
+  d = n.defaultExpiration
+  n.mu.RUnlock()
+  if d == DefaultExpiration {
+    return NoExpiration
+  }
+  return d
+}
+
+//设置数据项，命名空间有配额且策略为 QuotaReject 时，超出配额返回 ErrQuotaExceeded
+func (n *Namespace) Set(k string, v interface{}, d time.Duration) error {
+  if n.c.Frozen() {
+    return ErrReadOnly
+  }
+  key := n.prefix + k
+  if err := n.admit(key, v); err != nil {
+    return err
+  }
+  if err := n.c.trySet(key, v, n.expiration(d)); err != nil {
+    return err
+  }
+  n.trim(key)
+  return nil
+}
+
+func (n *Namespace) Get(k string) (interface{}, bool) {
+  return n.c.Get(n.prefix + k)
+}
+
+func (n *Namespace) Add(k string, v interface{}, d time.Duration) error {
+  key := n.prefix + k
+  if err := n.admit(key, v); err != nil {
+    return err
+  }
+  if err := n.c.Add(key, v, n.expiration(d)); err != nil {
+    return err
+  }
+  n.trim(key)
+  return nil
+}
+
+func (n *Namespace) Replace(k string, v interface{}, d time.Duration) error {
+  key := n.prefix + k
+  if err := n.admit(key, v); err != nil {
+    return err
+  }
+  if err := n.c.Replace(key, v, n.expiration(d)); err != nil {
+    return err
+  }
+  n.trim(key)
+  return nil
+}
+
+func (n *Namespace) Delete(k string) {
+  n.c.Delete(n.prefix + k)
+}
+
+//返回命名空间内所有未过期数据项的键，不包含前缀
+func (n *Namespace) Keys() []string {
+  var keys []string
+  for _, k := range n.c.Keys() {
+    if strings.HasPrefix(k, n.prefix) {
+      keys = append(keys, k[len(n.prefix):])
+    }
+  }
+  return keys
+}
+
+//返回命名空间内未过期数据项的数量
+func (n *Namespace) Count() int {
+  return len(n.Keys())
+}
+
+//清空命名空间内的数据项，不影响其他命名空间
+func (n *Namespace) Flush() {
+  n.c.deletePrefix(n.prefix, EventDelete)
+}
+
+//删除以 prefix 开头的数据项，返回删除的数量
+func (c *Cache) deletePrefix(prefix string, t EventType) int {
+  return c.deleteWhere(func(k string) bool {
+    return strings.HasPrefix(k, prefix)
+  }, t)
+}
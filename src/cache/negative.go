@@ -0,0 +1,51 @@
+package cache
+
+import (
+  "time"
+)
+
+//缓存键 k “不存在”的结果，之后 Get 视为未命中，GetWithNegative 可以区分两者。
+//d 为 DefaultExpiration 时使用 SetNegativeTTL 设置的时间，未设置时使用默认的过期时间
+func (c *Cache) SetNegative(k string, d time.Duration) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  s.setNegative(k, d)
+}
+
+//需要持有写锁
+func (s *shard) setNegative(k string, d time.Duration) {
+  if d == DefaultExpiration && s.c.negativeTTL != 0 {
+    d = s.c.negativeTTL
+  }
+  item := s.newItem(nil, d, 0)
+  item.Negative = true
+  item.Cost = s.c.costOf(k, nil)
+  s.setItem(k, item)
+}
+
+//获取数据项，第二个返回值为 true 表示缓存的是 SetNegative 设置的“不存在”结果，
+//此时第三个返回值也为 true，第三个返回值为 false 表示缓存中没有该键
+func (c *Cache) GetWithNegative(k string) (interface{}, bool, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  v, negative, found := s.lookup(k)
+  slide := found && s.items[k].Sliding > 0
+  s.mu.RUnlock()
+  c.hit(found)
+  if slide {
+    s.slide(k)
+  }
+  return v, negative, found
+}
+
+//设置 SetNegative 的默认生存时间。大于 0 时 GetOrSetWithStatus、LoadingCache 和 TieredCache
+//加载时返回 ErrNotFound 的键也会以该时间缓存为“不存在”，之后的读取直接返回 ErrNotFound 而不再加载
+func (c *Cache) SetNegativeTTL(d time.Duration) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.negativeTTL = d
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+  "testing"
+  "time"
+)
+
+//SetNegative 缓存的结果在各个读取路径上都视为未命中
+func TestNegativeIsMiss(t *testing.T) {
+  c := New()
+  c.SetNegative("n", time.Minute)
+  if _, found := c.Get("n"); found {
+    t.Fatal("Get found a negative item")
+  }
+  if _, found := c.GetAndDelete("n"); found {
+    t.Fatal("GetAndDelete found a negative item")
+  }
+  if _, negative, found := c.GetWithNegative("n"); !found || !negative {
+    t.Fatal("GetAndDelete removed the negative item")
+  }
+  if k, _, found := c.PopRandom(); found {
+    t.Fatalf("PopRandom returned negative item %s", k)
+  }
+  if _, err := c.Increment("n", 1); err == nil {
+    t.Fatal("Increment succeeded on a negative item")
+  }
+  if _, err := c.IncrementFloat("n", 1); err == nil {
+    t.Fatal("IncrementFloat succeeded on a negative item")
+  }
+  if err := c.CompareAndSwap("n", 0, 1, NoExpiration); err != nil {
+    t.Fatalf("CompareAndSwap with version 0 on a negative item: %v", err)
+  }
+  if v, found := c.Get("n"); !found || v != 1 {
+    t.Fatalf("Get after CompareAndSwap = %v, %v", v, found)
+  }
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+  "fmt"
+  "testing"
+  "time"
+)
+
+//删除、过期清理、淘汰和清空时调用 OnEvicted，被新值覆盖时不调用
+func TestOnEvicted(t *testing.T) {
+  clock := NewFakeClock(time.Unix(1000, 0))
+  c := New(WithClock(clock), WithShards(1), WithMaxEntries(3), WithGCInterval(0))
+  var got []string
+  c.OnEvicted(func(k string, v interface{}) {
+    got = append(got, fmt.Sprintf("%s=%v", k, v))
+  })
+  c.Set("a", 1)
+  c.Set("a", 2)
+  c.Set("b", 3, WithTTL(time.Second))
+  c.Delete("a")
+  clock.Advance(2 * time.Second)
+  c.DeleteExpired()
+  if fmt.Sprint(got) != "[a=2 b=3]" {
+    t.Fatalf("callbacks %v, want [a=2 b=3]", got)
+  }
+  got = nil
+  for i := 0; i < 4; i++ {
+    c.Set(fmt.Sprintf("x%d", i), i)
+  }
+  if len(got) != 1 {
+    t.Fatalf("callbacks %v after overflowing by one, want one eviction", got)
+  }
+  got = nil
+  c.Flush()
+  if len(got) != 3 {
+    t.Fatalf("callbacks %v after Flush, want three", got)
+  }
+  c.OnEvicted(nil)
+  c.Set("y", 1)
+  c.Delete("y")
+  if len(got) != 3 {
+    t.Fatalf("callback ran after being removed: %v", got)
+  }
+}
+
+//回调在锁外执行，可以在回调中访问缓存
+func TestOnEvictedReentrant(t *testing.T) {
+  c := New(WithShards(1))
+  done := make(chan struct{})
+  c.OnEvicted(func(k string, v interface{}) {
+    c.Set(k+"-copy", v)
+    if _, found := c.Get(k); found {
+      t.Errorf("%s is still present in its own callback", k)
+    }
+    close(done)
+  })
+  c.Set("a", 1)
+  c.Delete("a")
+  select {
+  case <-done:
+  case <-time.After(time.Second):
+    t.Fatal("callback did not run")
+  }
+  if v, found := c.Get("a-copy"); !found || v != 1 {
+    t.Fatalf("Get(a-copy) = %v, %v, want 1", v, found)
+  }
+}
@@ -0,0 +1,317 @@
+package cache
+
+import (
+  "bytes"
+  "container/list"
+  "math/rand"
+  "time"
+)
+
+//默认的过期清理间隔
+const DefaultGCInterval = time.Minute
+
+//创建缓存时的配置
+type options struct {
+  shards            int
+  defaultExpiration time.Duration
+  gcInterval        time.Duration
+  maxEntries        int64
+  maxCost           int64
+  lru               bool
+  arc               bool
+  tinyLFU           bool
+  sizer             Sizer
+  sliding           bool
+  onEvicted         func(string, interface{})
+  onRemoved         func(string, interface{}, EvictionReason)
+  journal           *Journal
+  reaper            *Reaper
+  clock             Clock
+  staleFor          time.Duration
+  xfetchBeta        float64
+  jitter            float64
+  gcMaxItems        int
+  gcMaxTime         time.Duration
+  aofRewrite        time.Duration
+  compressAt        int
+  persistKey        []byte
+  hotWindow         time.Duration
+  hotCapacity       int
+  negativeTTL       time.Duration
+  lockFree          bool
+  tracer            Tracer
+  logger            Logger
+  estimator         Sizer
+  disk              *DiskTier
+  merge             MergeFunc
+  sorted            bool
+  cloner            func(interface{}) interface{}
+  maxItemBytes      int64
+  oversize          OversizePolicy
+  reclaim           bool
+  lockProfiling     bool
+}
+
+//New 的配置项
+type Option func(*options)
+
+//默认的过期时间，默认为 DefaultExpiration，即永不过期
+func WithDefaultExpiration(d time.Duration) Option {
+  return func(o *options) { o.defaultExpiration = d }
+}
+
+//过期清理的间隔，默认为 DefaultGCInterval，小于等于 0 时不启动过期清理，过期的数据项只在访问时被忽略
+func WithGCInterval(d time.Duration) Option {
+  return func(o *options) { o.gcInterval = d }
+}
+
+//分片数量，默认为 DefaultShards
+func WithShards(n int) Option {
+  return func(o *options) { o.shards = n }
+}
+
+//数据项数量上限，0 表示不限制
+func WithMaxEntries(n int) Option {
+  return func(o *options) { o.maxEntries = int64(n) }
+}
+
+//成本上限，0 表示不限制
+func WithMaxCost(n int64) Option {
+  return func(o *options) { o.maxCost = n }
+}
+
+//最大字节数，sizer 为 nil 时使用 DefaultSizer，与 SetMaxBytes 相同
+func WithMaxBytes(n int64, sizer Sizer) Option {
+  if sizer == nil {
+    sizer = DefaultSizer
+  }
+  return func(o *options) {
+    o.maxCost = n
+    o.sizer = sizer
+  }
+}
+
+//超出上限时按最久未使用的顺序淘汰，访问顺序在分片内维护
+func WithLRU() Option {
+  return func(o *options) { o.lru = true }
+}
+
+//超出上限时按 ARC 策略淘汰，根据最近淘汰的键自动调整最近使用和访问频率的比重，
+//适合扫描和热点访问交替的负载。访问顺序在分片内维护，同时设置 WithLRU 时使用 ARC
+func WithARC() Option {
+  return func(o *options) { o.arc = true }
+}
+
+//使用 TinyLFU 准入策略，缓存已满时访问频率不高于被淘汰者的新数据项不会放入，
+//避免一次性扫描的数据把热点数据挤出去。配合 WithMaxCost 和 WithCost 使用时，
+//需要淘汰多个数据项才能放入的大数据项必须比其中每一个都热。需要同时设置数量或成本上限
+func WithTinyLFU() Option {
+  return func(o *options) { o.tinyLFU = true }
+}
+
+//所有带过期时间的数据项使用滑动过期，与 SetSlidingExpiration 相同
+func WithSlidingExpiration() Option {
+  return func(o *options) { o.sliding = true }
+}
+
+//数据项被删除、过期清理、淘汰或清空时调用的回调，与 OnEvicted 相同
+func WithOnEvicted(f func(key string, value interface{})) Option {
+  return func(o *options) { o.onEvicted = f }
+}
+
+//数据项被移除时调用的回调，与 OnRemoved 相同
+func WithOnRemoved(f func(key string, value interface{}, reason EvictionReason)) Option {
+  return func(o *options) { o.onRemoved = f }
+}
+
+//记录数据项生命周期事件的日志，与 SetJournal 相同
+func WithJournal(j *Journal) Option {
+  return func(o *options) { o.journal = j }
+}
+
+//由共享的清理器负责过期清理，不启动自己的 goroutine，清理间隔使用清理器的间隔
+func WithReaper(r *Reaper) Option {
+  return func(o *options) { o.reaper = r }
+}
+
+//判断过期和过期清理使用的时钟，默认为 RealClock。使用共享清理器时清理的间隔不受影响
+func WithClock(clock Clock) Option {
+  return func(o *options) { o.clock = clock }
+}
+
+//过期的数据项再保留 d 后才被清理，期间 Get 视为不存在，GetStale 仍然可以读取，
+//LoadingCache 在此期间返回旧值并在后台重新加载
+func WithStaleWhileRevalidate(d time.Duration) Option {
+  return func(o *options) { o.staleFor = d }
+}
+
+//LoadingCache 使用 XFetch 算法在过期前按概率提前在后台重新加载，beta 越大越早刷新，通常取 1
+func WithXFetch(beta float64) Option {
+  return func(o *options) { o.xfetchBeta = beta }
+}
+
+//每次 Set 的生存时间在 ±fraction 范围内随机变化，避免同时写入的大量数据项在同一次清理中过期，
+//与 SetTTLJitter(fraction, nil) 相同
+func WithTTLJitter(fraction float64) Option {
+  return func(o *options) { o.jitter = fraction }
+}
+
+//每次定期过期清理最多清理 maxItems 个数据项或耗时 maxTime，与 SetGCBudget 相同
+func WithGCBudget(maxItems int, maxTime time.Duration) Option {
+  return func(o *options) {
+    o.gcMaxItems = maxItems
+    o.gcMaxTime = maxTime
+  }
+}
+
+//OpenWithAOF 打开的缓存每隔 d 把追加日志重写为快照，默认只在打开时重写
+func WithAOFRewrite(d time.Duration) Option {
+  return func(o *options) { o.aofRewrite = d }
+}
+
+//长度不小于 threshold 的 []byte 和 string 值用 gzip 压缩后保存，与 SetCompression 相同
+func WithCompression(threshold int) Option {
+  return func(o *options) { o.compressAt = threshold }
+}
+
+//SaveToFile 和自动保存用 AES-GCM 加密写入的文件，LoadFile 解密并认证，key 的长度为 16、24 或 32 字节。
+//没有加密的文件仍然可以读取，Save 写入 io.Writer 的数据和 OpenWithAOF 的日志不加密
+func WithPersistenceKey(key []byte) Option {
+  return func(o *options) { o.persistKey = bytes.Clone(key) }
+}
+
+//统计最近一到两个 window 内命中次数最多的约 capacity 个键，与 SetHotKeys 相同
+func WithHotKeys(window time.Duration, capacity int) Option {
+  return func(o *options) {
+    o.hotWindow = window
+    o.hotCapacity = capacity
+  }
+}
+
+//SetNegative 的默认生存时间，与 SetNegativeTTL 相同
+func WithNegativeTTL(d time.Duration) Option {
+  return func(o *options) { o.negativeTTL = d }
+}
+
+//开启无锁读取，与 SetLockFreeReads(true) 相同
+func WithLockFreeReads() Option {
+  return func(o *options) { o.lockFree = true }
+}
+
+//带 ctx 的操作、LoadingCache 和 TieredCache 调用的追踪钩子，可以使用 otelcache 包接入 OpenTelemetry
+func WithTracer(t Tracer) Option {
+  return func(o *options) { o.tracer = t }
+}
+
+//报告过期清理、自动保存和追加日志的失败、加载错误和淘汰风暴的日志，默认不输出
+func WithLogger(l Logger) Option {
+  return func(o *options) { o.logger = l }
+}
+
+//SizeBytes 估算每个数据项的函数，与 SetSizeEstimator 相同
+func WithSizeEstimator(f Sizer) Option {
+  return func(o *options) { o.estimator = f }
+}
+
+//因容量上限被淘汰的数据项写入磁盘层，与 SetDiskTier 相同
+func WithDiskTier(d *DiskTier) Option {
+  return func(o *options) { o.disk = d }
+}
+
+//Load 等读取的数据项与已有数据项冲突时的合并方式，与 SetMergePolicy 相同
+func WithMergePolicy(f MergeFunc) Option {
+  return func(o *options) { o.merge = f }
+}
+
+//Save、ExportJSONL 和 Range 按键的顺序遍历，与 SetSortedIteration(true) 相同
+func WithSortedIteration() Option {
+  return func(o *options) { o.sorted = true }
+}
+
+//按配置项创建一个缓存系统
+func New(opts ...Option) *Cache {
+  o := options{
+    shards: DefaultShards,
+    defaultExpiration: DefaultExpiration,
+    gcInterval: DefaultGCInterval,
+    clock: RealClock,
+  }
+  for _, opt := range opts {
+    opt(&o)
+  }
+  if o.reaper != nil {
+    o.gcInterval = o.reaper.interval
+  }
+  c := newCache(o.shards, o.defaultExpiration, o.gcInterval)
+  c.clock = o.clock
+  c.tracer = o.tracer
+  c.logger = o.logger
+  c.xfetchBeta = o.xfetchBeta
+  if o.staleFor > 0 {
+    c.staleFor = o.staleFor
+  }
+  c.lastSweep = c.now()
+  c.maxEntries = o.maxEntries
+  c.maxCost = o.maxCost
+  c.sizer = o.sizer
+  c.estimator = o.estimator
+  c.disk.Store(o.disk)
+  c.merge = o.merge
+  c.sorted = o.sorted
+  c.cloner = o.cloner
+  c.maxItemBytes = o.maxItemBytes
+  c.oversize = o.oversize
+  c.reclaim = o.reclaim
+  if o.lockProfiling {
+    c.SetLockProfiling(true)
+  }
+  c.sliding = o.sliding
+  c.onEvicted = o.onEvicted
+  c.onRemoved = o.onRemoved
+  c.journal = o.journal
+  c.gcMaxItems = o.gcMaxItems
+  c.gcMaxTime = o.gcMaxTime
+  c.aofRewrite = o.aofRewrite
+  c.compressAt = o.compressAt
+  c.persistKey = o.persistKey
+  c.negativeTTL = o.negativeTTL
+  if o.jitter > 0 {
+    c.jitterFraction = o.jitter
+    c.jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+  }
+  if o.arc {
+    for _, s := range c.shards {
+      s.arc = newARC()
+    }
+  } else if o.lru {
+    for _, s := range c.shards {
+      s.lru = list.New()
+      s.resetLRU()
+    }
+  }
+  if o.tinyLFU {
+    width := 1024
+    if o.maxEntries > 0 {
+      width = int(o.maxEntries) * 4 / len(c.shards)
+    }
+    for _, s := range c.shards {
+      s.freq = newSketch(width)
+    }
+  }
+  if o.lockFree {
+    c.SetLockFreeReads(true)
+  }
+  if o.hotWindow > 0 {
+    c.SetHotKeys(o.hotWindow, o.hotCapacity)
+  }
+  switch {
+  case o.reaper != nil:
+    c.reaper = o.reaper
+    o.reaper.add(c)
+  case o.gcInterval > 0:
+    c.startGc()
+  default:
+    c.gcStopped = 1
+  }
+  return c
+}
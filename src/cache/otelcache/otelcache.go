@@ -0,0 +1,101 @@
+package otelcache
+
+import (
+  "context"
+  "time"
+
+  "cache"
+
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+)
+
+//使用的 Tracer 名称
+const instrumentationName = "cache/otelcache"
+
+//追踪的配置项
+type Option func(*config)
+
+type config struct {
+  keys bool
+}
+
+//在 span 和事件上记录键，键可能包含敏感信息，默认不记录
+func WithKeys() Option {
+  return func(c *config) { c.keys = true }
+}
+
+func newConfig(opts []Option) config {
+  var c config
+  for _, opt := range opts {
+    opt(&c)
+  }
+  return c
+}
+
+func (c config) attrs(op, key string) []attribute.KeyValue {
+  attrs := []attribute.KeyValue{attribute.String("cache.operation", op)}
+  if c.keys {
+    attrs = append(attrs, attribute.String("cache.key", key))
+  }
+  return attrs
+}
+
+//为每个操作创建名为 cache.<op> 的 span，OpGet 带有 cache.hit 属性，失败时记录错误。
+//tp 为 nil 时使用全局的 TracerProvider，返回值传给 cache.WithTracer
+func NewTracer(tp trace.TracerProvider, opts ...Option) cache.Tracer {
+  if tp == nil {
+    tp = otel.GetTracerProvider()
+  }
+  return &spanTracer{t: tp.Tracer(instrumentationName), c: newConfig(opts)}
+}
+
+type spanTracer struct {
+  t trace.Tracer
+  c config
+}
+
+func (st *spanTracer) Start(ctx context.Context, op, key string) (context.Context, func(bool, error)) {
+  ctx, span := st.t.Start(ctx, "cache."+op, trace.WithAttributes(st.c.attrs(op, key)...))
+  return ctx, func(hit bool, err error) {
+    if op == cache.OpGet {
+      span.SetAttributes(attribute.Bool("cache.hit", hit))
+    }
+    if err != nil {
+      span.RecordError(err)
+      span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+  }
+}
+
+//不创建新的 span，只在 ctx 中已有的 span 上为每个操作记录一个名为 cache.<op> 的事件，
+//事件带有 cache.hit 和以毫秒为单位的 cache.duration_ms 属性。ctx 中没有记录中的 span 时不做任何事
+func NewEventTracer(opts ...Option) cache.Tracer {
+  return &eventTracer{c: newConfig(opts)}
+}
+
+type eventTracer struct {
+  c config
+}
+
+func (et *eventTracer) Start(ctx context.Context, op, key string) (context.Context, func(bool, error)) {
+  span := trace.SpanFromContext(ctx)
+  if !span.IsRecording() {
+    return ctx, func(bool, error) {}
+  }
+  start := time.Now()
+  return ctx, func(hit bool, err error) {
+    attrs := et.c.attrs(op, key)
+    attrs = append(attrs, attribute.Float64("cache.duration_ms", float64(time.Since(start)) / float64(time.Millisecond)))
+    if op == cache.OpGet {
+      attrs = append(attrs, attribute.Bool("cache.hit", hit))
+    }
+    if err != nil {
+      attrs = append(attrs, attribute.String("cache.error", err.Error()))
+    }
+    span.AddEvent("cache."+op, trace.WithAttributes(attrs...))
+  }
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+  "time"
+)
+
+//数据项的淘汰优先级
+type Priority int8
+
+const (
+  //超出上限时最先被淘汰
+  PriorityLow Priority = iota - 1
+  //默认的优先级
+  PriorityNormal
+  //低优先级的数据项都被淘汰后才会被淘汰
+  PriorityHigh
+)
+
+//优先级的数量
+const priorities = int(PriorityHigh - PriorityLow) + 1
+
+//返回优先级从 0 开始的下标，超出范围时按最接近的优先级计算
+func (p Priority) index() int {
+  return int(min(max(p, PriorityLow), PriorityHigh) - PriorityLow)
+}
+
+func (p Priority) String() string {
+  switch p {
+  case PriorityLow:
+    return "low"
+  case PriorityNormal:
+    return "normal"
+  case PriorityHigh:
+    return "high"
+  }
+  return "unknown"
+}
+
+//设置数据项时的附加选项
+type ItemOptions struct {
+  Priority Priority
+  Pinned   bool  // 固定的数据项不会因容量限制被淘汰，只会过期或被删除
+}
+
+//按附加选项设置数据项。固定的数据项超出上限时也不会被淘汰，
+//全部数据项都被固定时缓存可能超出上限
+func (c *Cache) SetWithOptions(k string, v interface{}, d time.Duration, o ItemOptions) {
+  if o.Priority < PriorityLow {
+    o.Priority = PriorityLow
+  } else if o.Priority > PriorityHigh {
+    o.Priority = PriorityHigh
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  item := s.newItem(v, d, 0)
+  item.Cost = c.costOf(k, item.Object)
+  item.Priority = o.Priority
+  item.Pinned = o.Pinned
+  s.setItem(k, item)
+}
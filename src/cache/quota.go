@@ -0,0 +1,198 @@
+package cache
+
+import (
+  "container/list"
+  "errors"
+  "strings"
+  "sync"
+)
+
+//命名空间的配额已满且策略为 QuotaReject 时返回的错误
+var ErrQuotaExceeded = errors.New("Namespace quota exceeded.")
+
+//超出配额时的处理方式
+type QuotaPolicy int
+
+const (
+  QuotaReject QuotaPolicy = iota  // 拒绝写入，返回 ErrQuotaExceeded
+  QuotaEvict                      // 写入后按写入顺序淘汰本命名空间最早的数据项，不影响其他命名空间
+)
+
+//命名空间的配额，为 0 的字段表示不限制
+type Quota struct {
+  MaxEntries int64
+  MaxCost    int64
+  Policy     QuotaPolicy
+}
+
+//命名空间的用量，在分片写入和删除数据项时更新
+type nsQuota struct {
+  Quota
+  mu    sync.Mutex
+  count int64
+  cost  int64
+  order *list.List  // 按写入顺序排列的键，最早的在前
+  index map[string]*list.Element
+}
+
+func (q *nsQuota) added(k string, cost int64) {
+  q.mu.Lock()
+  q.count++
+  q.cost += cost
+  q.index[k] = q.order.PushBack(k)
+  q.mu.Unlock()
+}
+
+func (q *nsQuota) removed(k string, cost int64) {
+  q.mu.Lock()
+  q.count--
+  q.cost -= cost
+  if e, found := q.index[k]; found {
+    q.order.Remove(e)
+    delete(q.index, k)
+  }
+  q.mu.Unlock()
+}
+
+func (q *nsQuota) usage() (int64, int64) {
+  q.mu.Lock()
+  defer q.mu.Unlock()
+  return q.count, q.cost
+}
+
+func (q *nsQuota) exceeds(count, cost int64) bool {
+  return (q.MaxEntries > 0 && count > q.MaxEntries) || (q.MaxCost > 0 && cost > q.MaxCost)
+}
+
+//返回最早写入的键，keep 不参与淘汰
+func (q *nsQuota) oldest(keep string) (string, bool) {
+  q.mu.Lock()
+  defer q.mu.Unlock()
+  for e := q.order.Front(); e != nil; e = e.Next() {
+    if k := e.Value.(string); k != keep {
+      return k, true
+    }
+  }
+  return "", false
+}
+
+//返回键所属的有配额的命名空间的用量，没有时返回 nil
+func (c *Cache) quotaOf(k string) *nsQuota {
+  m := c.quotas.Load()
+  if m == nil {
+    return nil
+  }
+  i := strings.Index(k, namespaceSep)
+  if i < 0 {
+    return nil
+  }
+  return (*m)[k[:i]]
+}
+
+//设置命名空间的配额，两个上限都为 0 时取消配额。
+//配额只约束通过命名空间写入的数据项，并发写入时可能短暂超出
+func (n *Namespace) SetQuota(q Quota) {
+  c := n.c
+  c.lockAll()
+  defer c.unlockAll()
+  m := map[string]*nsQuota{}
+  if old := c.quotas.Load(); old != nil {
+    for k, v := range *old {
+      m[k] = v
+    }
+  }
+  if q.MaxEntries <= 0 && q.MaxCost <= 0 {
+    delete(m, n.name)
+  } else {
+    nq := &nsQuota{Quota: q, order: list.New(), index: map[string]*list.Element{}}
+    for _, s := range c.shards {
+      for k, v := range s.items {
+        if strings.HasPrefix(k, n.prefix) {
+          nq.added(k, v.Cost)
+        }
+      }
+    }
+    m[n.name] = nq
+  }
+  if len(m) == 0 {
+    c.quotas.Store(nil)
+  } else {
+    c.quotas.Store(&m)
+  }
+}
+
+//返回命名空间的配额和当前的数据项数量、成本总和，没有配额时第一个返回值为零值
+func (n *Namespace) Quota() (Quota, int64, int64) {
+  m := n.c.quotas.Load()
+  if m == nil {
+    return Quota{}, 0, 0
+  }
+  q, found := (*m)[n.name]
+  if !found {
+    return Quota{}, 0, 0
+  }
+  count, cost := q.usage()
+  return q.Quota, count, cost
+}
+
+func (n *Namespace) quota() *nsQuota {
+  if m := n.c.quotas.Load(); m != nil {
+    return (*m)[n.name]
+  }
+  return nil
+}
+
+//策略为 QuotaReject 时检查写入 key 后是否超出配额，替换已有的数据项时扣除旧的用量
+func (n *Namespace) admit(key string, v interface{}) error {
+  q := n.quota()
+  if q == nil || q.Policy != QuotaReject {
+    return nil
+  }
+  s := n.c.shard(key)
+  s.mu.RLock()
+  cost := n.c.costOf(key, v)
+  old, found := s.items[key]
+  s.mu.RUnlock()
+  count, total := q.usage()
+  if found {
+    count--
+    total -= old.Cost
+  }
+  if q.exceeds(count+1, total+cost) {
+    return ErrQuotaExceeded
+  }
+  return nil
+}
+
+//策略为 QuotaEvict 时淘汰本命名空间最早写入的数据项直到不超出配额，keep 为刚写入的键
+func (n *Namespace) trim(keep string) {
+  q := n.quota()
+  if q == nil || q.Policy != QuotaEvict {
+    return
+  }
+  for q.exceeds(q.usage()) {
+    k, found := q.oldest(keep)
+    if !found {
+      return
+    }
+    s := n.c.shard(k)
+    s.mu.Lock()
+    s.remove(k, EventEvict)
+    s.unlock()
+  }
+}
+
+//清空后命名空间的用量归零，需要持有全部分片的写锁
+func (c *Cache) resetQuotas() {
+  m := c.quotas.Load()
+  if m == nil {
+    return
+  }
+  for _, q := range *m {
+    q.mu.Lock()
+    q.count, q.cost = 0, 0
+    q.order.Init()
+    clear(q.index)
+    q.mu.Unlock()
+  }
+}
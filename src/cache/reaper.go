@@ -0,0 +1,92 @@
+package cache
+
+import (
+  "sync"
+  "sync/atomic"
+  "time"
+  "weak"
+)
+
+//多个缓存共享的过期清理器，只使用一个 goroutine。
+//清理器只持有缓存的弱引用，不会阻止已不再使用的缓存被回收
+type Reaper struct {
+  mu       sync.Mutex
+  interval time.Duration
+  caches   map[weak.Pointer[Cache]]struct{}
+  stop     chan struct{}
+  stopOnce sync.Once
+}
+
+//创建并启动一个共享的过期清理器
+func NewReaper(interval time.Duration) *Reaper {
+  r := &Reaper {
+    interval: interval,
+    caches: map[weak.Pointer[Cache]]struct{}{},
+    stop: make(chan struct{}),
+  }
+  go r.loop()
+  return r
+}
+
+func (r *Reaper) loop() {
+  ticker := time.NewTicker(r.interval)
+  for {
+    select {
+    case <-ticker.C:
+      r.sweep()
+    case <-r.stop:
+      ticker.Stop()
+      return
+    }
+  }
+}
+
+//清理所有已注册缓存中的过期数据项，并移除已被回收的缓存
+func (r *Reaper) sweep() {
+  r.mu.Lock()
+  ptrs := make([]weak.Pointer[Cache], 0, len(r.caches))
+  for p := range r.caches {
+    ptrs = append(ptrs, p)
+  }
+  r.mu.Unlock()
+  for _, p := range ptrs {
+    c := p.Value()
+    if c == nil {
+      r.mu.Lock()
+      delete(r.caches, p)
+      r.mu.Unlock()
+      continue
+    }
+    c.gcSweep()
+    atomic.StoreInt64(&c.lastSweep, c.now())
+  }
+}
+
+func (r *Reaper) add(c *Cache) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  r.caches[weak.Make(c)] = struct{}{}
+}
+
+func (r *Reaper) remove(c *Cache) {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  delete(r.caches, weak.Make(c))
+}
+
+//返回当前注册的缓存数量
+func (r *Reaper) Len() int {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return len(r.caches)
+}
+
+//停止共享的过期清理器，可以重复调用
+func (r *Reaper) Stop() {
+  r.stopOnce.Do(func() { close(r.stop) })
+}
+
+//创建一个由共享清理器负责过期清理的缓存系统，不会启动自己的 goroutine
+func NewCacheWithReaper(defaultExpiration time.Duration, r *Reaper) *Cache {
+  return New(WithDefaultExpiration(defaultExpiration), WithReaper(r))
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+  "testing"
+  "time"
+)
+
+//重复调用 Stop 不会阻塞
+func TestReaperStopTwice(t *testing.T) {
+  r := NewReaper(time.Millisecond)
+  done := make(chan struct{})
+  go func() {
+    r.Stop()
+    r.Stop()
+    close(done)
+  }()
+  select {
+  case <-done:
+  case <-time.After(5 * time.Second):
+    t.Fatal("second Stop blocked")
+  }
+}
+
+func TestReaperSweep(t *testing.T) {
+  r := NewReaper(time.Millisecond)
+  defer r.Stop()
+  c := NewCacheWithReaper(time.Millisecond, r)
+  c.Set("k", 1)
+  deadline := time.Now().Add(5 * time.Second)
+  for c.Count() > 0 {
+    if time.Now().After(deadline) {
+      t.Fatal("the reaper didn't remove the expired item")
+    }
+    time.Sleep(time.Millisecond)
+  }
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+  "runtime/debug"
+  "sync"
+)
+
+//后台回收器最多排队的任务数量，已满时在调用方归还内存
+const reclaimQueue = 16
+
+//一次清理删除的数据项不少于该数量且不少于剩余的数量时重建分片的 map
+const shrinkMin = 4096
+
+//交给后台回收器的旧 map
+type reclaimJob struct {
+  maps []map[string]Item
+  free bool  // 是否调用 debug.FreeOSMemory
+}
+
+//所有缓存共享的后台回收器，第一次使用时启动
+var reclaimer struct {
+  once sync.Once
+  jobs chan reclaimJob
+}
+
+//放入后台回收器的队列，队列已满时返回 false
+func reclaim(job reclaimJob) bool {
+  reclaimer.once.Do(func() {
+    reclaimer.jobs = make(chan reclaimJob, reclaimQueue)
+    go reclaimLoop(reclaimer.jobs)
+  })
+  select {
+  case reclaimer.jobs <- job:
+    return true
+  default:
+    return false
+  }
+}
+
+func reclaimLoop(jobs chan reclaimJob) {
+  for job := range jobs {
+    //清空旧表，断开对值的引用
+    for _, m := range job.maps {
+      clear(m)
+    }
+    if job.free {
+      debug.FreeOSMemory()
+    }
+  }
+}
+
+//开启后 Flush 和 DeleteExpired 丢弃的 map 交给共享的后台 goroutine 清空，
+//SetFreeOSMemory 设置的内存归还也在后台进行，调用方不再承担这部分耗时。
+//DeleteExpired 一次清理了分片中大部分数据项时，把剩余的数据项复制到新的 map，释放旧 map 占用的空间
+func (c *Cache) SetBackgroundReclaim(on bool) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.reclaim = on
+}
+
+//与 SetBackgroundReclaim(true) 相同
+func WithBackgroundReclaim() Option {
+  return func(o *options) { o.reclaim = true }
+}
+
+//Go 的 map 删除数据项后不会缩小，删除的数量足够多时把剩余的数据项复制到新的 map，
+//返回旧的 map，不需要重建时返回 nil。需要持有写锁
+func (s *shard) shrink(removed int) map[string]Item {
+  if removed < shrinkMin || removed < len(s.items) {
+    return nil
+  }
+  old := s.items
+  s.items = make(map[string]Item, len(old))
+  for k, v := range old {
+    s.items[k] = v
+  }
+  return old
+}
+
+//把旧的 map 交给后台回收器，未开启或队列已满时与 releaseMemory 相同，需要在锁外调用
+func (c *Cache) reclaimMaps(old []map[string]Item, removed int) {
+  c.shards[0].mu.RLock()
+  on, n := c.reclaim, c.freeOSMemoryAt
+  c.shards[0].mu.RUnlock()
+  if on && reclaim(reclaimJob{old, n > 0 && removed >= n}) {
+    return
+  }
+  c.releaseMemory(removed)
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+  "context"
+  "errors"
+  "path"
+  "strings"
+  "sync"
+  "time"
+)
+
+//在数据项过期前主动重新计算已注册的键，由固定数量的 goroutine 执行，
+//同一个键正在计算时与 GetOrSetWithStatus 等调用共享结果
+type Refresher struct {
+  c       *Cache
+  ctx     context.Context
+  cancel  context.CancelFunc
+  jobs    chan refreshJob
+  mu      sync.Mutex
+  pending map[string]bool  // 已排队或正在刷新的键
+  wg      sync.WaitGroup
+}
+
+//一次注册，pattern 不含通配符时只匹配同名的键
+type refreshReg struct {
+  pattern  string
+  exact    bool
+  interval time.Duration
+  f        LoaderFunc
+}
+
+type refreshJob struct {
+  reg *refreshReg
+  k   string
+}
+
+//创建并启动缓存 c 的刷新器，最多同时刷新 workers 个键，小于等于 0 时为 4
+func NewRefresher(c *Cache, workers int) *Refresher {
+  if workers <= 0 {
+    workers = 4
+  }
+  ctx, cancel := context.WithCancel(context.Background())
+  r := &Refresher {
+    c: c,
+    ctx: ctx,
+    cancel: cancel,
+    jobs: make(chan refreshJob, workers * 16),
+    pending: map[string]bool{},
+  }
+  for i := 0; i < workers; i++ {
+    r.wg.Add(1)
+    go r.work()
+  }
+  return r
+}
+
+//注册键或 path.Match 语法的模式，每隔 interval 检查一次，在之后两次检查内会过期的数据项用 f 重新计算，
+//按默认的过期时间保存。pattern 不含通配符时数据项不存在也会加载，含通配符时只刷新已存在的数据项，
+//永不过期的数据项不会被刷新。模式格式错误时返回错误，返回的函数取消这次注册
+func (r *Refresher) Register(pattern string, interval time.Duration, f LoaderFunc) (func(), error) {
+  if _, err := path.Match(pattern, ""); err != nil {
+    return nil, err
+  }
+  reg := &refreshReg {
+    pattern: pattern,
+    exact: !strings.ContainsAny(pattern, `*?[\`),
+    interval: interval,
+    f: f,
+  }
+  stop := make(chan struct{})
+  r.wg.Add(1)
+  go r.loop(reg, r.c.clock.NewTicker(interval), stop)
+  var once sync.Once
+  return func() { once.Do(func() { close(stop) }) }, nil
+}
+
+func (r *Refresher) loop(reg *refreshReg, ticker Ticker, stop chan struct{}) {
+  defer r.wg.Done()
+  defer ticker.Stop()
+  r.check(reg)
+  for {
+    select {
+    case <-ticker.C():
+      r.check(reg)
+    case <-stop:
+      return
+    case <-r.ctx.Done():
+      return
+    }
+  }
+}
+
+//把需要刷新的键放入队列，队列已满时留到下一次检查
+func (r *Refresher) check(reg *refreshReg) {
+  for _, k := range r.due(reg) {
+    r.mu.Lock()
+    if r.pending[k] {
+      r.mu.Unlock()
+      continue
+    }
+    select {
+    case r.jobs <- refreshJob{reg, k}:
+      r.pending[k] = true
+    default:
+      r.mu.Unlock()
+      r.c.log().Debugf("Refresh queue is full, item %s will be refreshed later.", k)
+      return
+    }
+    r.mu.Unlock()
+  }
+}
+
+//返回注册匹配的、需要刷新的键，每次只锁一个分片
+func (r *Refresher) due(reg *refreshReg) []string {
+  c := r.c
+  soon := func(item Item, now int64) bool {
+    return item.Expiration != 0 && item.Expiration - now < int64(2 * reg.interval)
+  }
+  if reg.exact {
+    s := c.shard(reg.pattern)
+    s.mu.RLock()
+    item, found := s.items[reg.pattern]
+    s.mu.RUnlock()
+    if !found || item.Negative || c.expired(item) || soon(item, c.now()) {
+      return []string{reg.pattern}
+    }
+    return nil
+  }
+  var keys []string
+  for _, s := range c.shards {
+    s.mu.RLock()
+    now := c.now()
+    for k, item := range s.items {
+      if !item.Negative && !c.expired(item) && soon(item, now) {
+        if ok, _ := path.Match(reg.pattern, k); ok {
+          keys = append(keys, k)
+        }
+      }
+    }
+    s.mu.RUnlock()
+  }
+  return keys
+}
+
+func (r *Refresher) work() {
+  defer r.wg.Done()
+  for {
+    select {
+    case job := <-r.jobs:
+      _, _, err := r.c.compute(r.ctx, job.k, DefaultExpiration, func() (interface{}, error) {
+        return job.reg.f(r.ctx, job.k)
+      }, true)
+      if err != nil && r.ctx.Err() == nil && !errors.Is(err, ErrClosed) {
+        r.c.log().Warnf("Refreshing item %s failed: %v", job.k, err)
+      }
+      r.mu.Lock()
+      delete(r.pending, job.k)
+      r.mu.Unlock()
+    case <-r.ctx.Done():
+      return
+    }
+  }
+}
+
+//停止所有注册和刷新，取消正在进行的计算的 ctx，等待 goroutine 退出后返回
+func (r *Refresher) Stop() {
+  r.cancel()
+  r.wg.Wait()
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+  "encoding/gob"
+  "fmt"
+  "reflect"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+)
+
+//通过 RegisterType 注册的类型，gob 编码的保存、追加日志和流式保存只接受这些类型和 gob 的基本类型
+var registry = struct {
+  mu    sync.RWMutex
+  types map[reflect.Type]bool
+}{types: map[reflect.Type]bool{}}
+
+//保存或读取时遇到没有注册的类型
+type UnregisteredTypeError struct {
+  Types []string
+}
+
+func (e *UnregisteredTypeError) Error() string {
+  return fmt.Sprintf("Item types %s are not registered, call cache.RegisterType before saving or loading.", strings.Join(e.Types, ", "))
+}
+
+//注册可以用 gob 保存和读取的值的类型，通常在 init 中调用，读取文件的程序也需要注册同样的类型。
+//布尔、数值、字符串以及它们的切片不需要注册，重复注册同一类型没有影响。
+//gob 无法注册该类型时返回错误，例如不同的类型使用了相同的名称
+func RegisterType(v interface{}) (err error) {
+  t := reflect.TypeOf(v)
+  if t == nil {
+    return fmt.Errorf("Can't register the type of nil.")
+  }
+  registry.mu.Lock()
+  defer registry.mu.Unlock()
+  if registry.types[t] {
+    return nil
+  }
+  defer func() {
+    if x := recover(); x != nil {
+      err = fmt.Errorf("Registering type %s failed: %v", t, x)
+    }
+  }()
+  gob.Register(v)
+  registry.types[t] = true
+  return nil
+}
+
+//gob 预先注册的类型
+func basicType(t reflect.Type) bool {
+  if t.Kind() == reflect.Slice {
+    t = t.Elem()
+  }
+  if t.PkgPath() != "" {
+    return false
+  }
+  switch t.Kind() {
+  case reflect.Bool, reflect.String,
+    reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+    reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+    reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+    return true
+  }
+  return false
+}
+
+//检查数据项的类型都已注册，返回列出全部未注册类型的 *UnregisteredTypeError
+func checkTypes(items map[string]Item) error {
+  var missing map[reflect.Type]bool
+  registry.mu.RLock()
+  for _, v := range items {
+    t := reflect.TypeOf(v.Object)
+    if t == nil || basicType(t) || registry.types[t] {
+      continue
+    }
+    if missing == nil {
+      missing = map[reflect.Type]bool{}
+    }
+    missing[t] = true
+  }
+  registry.mu.RUnlock()
+  if missing == nil {
+    return nil
+  }
+  names := make([]string, 0, len(missing))
+  for t := range missing {
+    names = append(names, t.String())
+  }
+  sort.Strings(names)
+  return &UnregisteredTypeError{names}
+}
+
+//把 gob 解码时遇到未注册类型的错误转换为 *UnregisteredTypeError
+func decodeError(err error) error {
+  const marker = "name not registered for interface: "
+  if err == nil {
+    return nil
+  }
+  i := strings.Index(err.Error(), marker)
+  if i < 0 {
+    return err
+  }
+  name := err.Error()[i+len(marker):]
+  if s, uerr := strconv.Unquote(name); uerr == nil {
+    name = s
+  }
+  return &UnregisteredTypeError{[]string{name}}
+}
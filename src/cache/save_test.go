@@ -0,0 +1,116 @@
+package cache
+
+import (
+  "bytes"
+  "fmt"
+  "path/filepath"
+  "sync"
+  "testing"
+)
+
+//写入者保存的值，K 总是等于所在的键
+type saveRecord struct {
+  K string
+  N int
+}
+
+//检查读回的数据：写入者不会修改的键全部存在且值不变，写入者修改的键的值完整
+func checkSnapshot(t *testing.T, d *Cache, stable int) {
+  t.Helper()
+  for i := 0; i < stable; i++ {
+    k := fmt.Sprintf("stable-%d", i)
+    if v, found := d.Get(k); !found || v != i {
+      t.Fatalf("Get(%s) = %v, %v, want %d", k, v, found, i)
+    }
+  }
+  for k, item := range d.Items() {
+    if r, ok := item.Object.(saveRecord); ok && r.K != k {
+      t.Fatalf("item %s holds the record of %s", k, r.K)
+    }
+  }
+}
+
+//Save 和 SaveToFile 与大量 Set、Delete 同时执行，需要用 -race 运行
+func TestSaveConcurrentWriters(t *testing.T) {
+  const stable, writers, keys = 1000, 4, 500
+  if err := RegisterType(saveRecord{}); err != nil {
+    t.Fatal(err)
+  }
+  c := New()
+  for i := 0; i < stable; i++ {
+    c.Set(fmt.Sprintf("stable-%d", i), i)
+  }
+  stop := make(chan struct{})
+  var wg sync.WaitGroup
+  for w := 0; w < writers; w++ {
+    wg.Add(1)
+    go func(w int) {
+      defer wg.Done()
+      for n := 0; ; n++ {
+        select {
+        case <-stop:
+          return
+        default:
+        }
+        k := fmt.Sprintf("w-%d", (n*writers+w)%keys)
+        if n%3 == 0 {
+          c.Delete(k)
+        } else {
+          c.Set(k, saveRecord{k, n})
+        }
+      }
+    }(w)
+  }
+  dir := t.TempDir()
+  var saves []*bytes.Buffer
+  var files []string
+  var sw sync.WaitGroup
+  var mu sync.Mutex
+  for i := 0; i < 4; i++ {
+    sw.Add(1)
+    go func(i int) {
+      defer sw.Done()
+      for j := 0; j < 5; j++ {
+        //重复注册与其他 goroutine 的编码同时进行
+        if err := RegisterType(saveRecord{}); err != nil {
+          t.Error(err)
+          return
+        }
+        var buf bytes.Buffer
+        if err := c.Save(&buf); err != nil {
+          t.Error(err)
+          return
+        }
+        file := filepath.Join(dir, fmt.Sprintf("dump-%d-%d", i, j))
+        if err := c.SaveToFile(file); err != nil {
+          t.Error(err)
+          return
+        }
+        mu.Lock()
+        saves = append(saves, &buf)
+        files = append(files, file)
+        mu.Unlock()
+      }
+    }(i)
+  }
+  sw.Wait()
+  close(stop)
+  wg.Wait()
+  if t.Failed() {
+    return
+  }
+  for _, buf := range saves {
+    d := New()
+    if err := d.Load(buf); err != nil {
+      t.Fatal(err)
+    }
+    checkSnapshot(t, d, stable)
+  }
+  for _, file := range files {
+    d := New()
+    if err := d.LoadFile(file); err != nil {
+      t.Fatal(err)
+    }
+    checkSnapshot(t, d, stable)
+  }
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+  "io"
+  "encoding/gob"
+  "encoding/json"
+)
+
+//数据项的序列化方式
+type Serializer interface {
+  Encode(w io.Writer, items map[string]Item) error
+  Decode(r io.Reader) (map[string]Item, error)
+}
+
+//可以分块编码的序列化方式，Save 逐块复制和编码，不需要整个缓存的副本。
+//Decode 需要读回由多个块连接而成的数据，后面的块覆盖前面的同名数据项
+type ChunkSerializer interface {
+  Serializer
+  NewEncoder(w io.Writer) ChunkEncoder
+}
+
+//把数据项分多次写入同一个 io.Writer
+type ChunkEncoder interface {
+  Encode(items map[string]Item) error
+}
+
+//依次解码 decode 读出的块并合并，后面的块覆盖前面的同名数据项，一个块都没有时返回 io.EOF。
+//供实现 Serializer.Decode 时读回 ChunkEncoder 写入的数据
+func DecodeChunks(decode func(*map[string]Item) error) (map[string]Item, error) {
+  items := map[string]Item{}
+  for n := 0; ; n++ {
+    var chunk map[string]Item
+    err := decode(&chunk)
+    if err == io.EOF && n > 0 {
+      return items, nil
+    }
+    if err != nil {
+      return items, err
+    }
+    if n == 0 {
+      items = chunk
+      continue
+    }
+    for k, v := range chunk {
+      items[k] = v
+    }
+  }
+}
+
+//基于 gob 的序列化方式，Save 和 Load 默认使用
+type GobSerializer struct{}
+
+func (GobSerializer) NewEncoder(w io.Writer) ChunkEncoder {
+  return gobEncoder{gob.NewEncoder(w)}
+}
+
+type gobEncoder struct {
+  enc *gob.Encoder
+}
+
+func (e gobEncoder) Encode(items map[string]Item) error {
+  if err := checkTypes(items); err != nil {
+    return err
+  }
+  return e.enc.Encode(&items)
+}
+
+//值的类型需要先用 RegisterType 注册，否则返回 *UnregisteredTypeError
+func (GobSerializer) Encode(w io.Writer, items map[string]Item) error {
+  if err := checkTypes(items); err != nil {
+    return err
+  }
+  return gob.NewEncoder(w).Encode(&items)
+}
+
+func (GobSerializer) Decode(r io.Reader) (map[string]Item, error) {
+  dec := gob.NewDecoder(r)
+  return DecodeChunks(func(items *map[string]Item) error { return decodeError(dec.Decode(items)) })
+}
+
+//基于 JSON 的序列化方式，读回的 Object 为 encoding/json 的默认类型
+type JSONSerializer struct{}
+
+func (JSONSerializer) Encode(w io.Writer, items map[string]Item) error {
+  return json.NewEncoder(w).Encode(items)
+}
+
+func (JSONSerializer) Decode(r io.Reader) (map[string]Item, error) {
+  dec := json.NewDecoder(r)
+  return DecodeChunks(func(items *map[string]Item) error { return dec.Decode(items) })
+}
+
+func (JSONSerializer) NewEncoder(w io.Writer) ChunkEncoder {
+  return jsonEncoder{json.NewEncoder(w)}
+}
+
+type jsonEncoder struct {
+  enc *json.Encoder
+}
+
+func (e jsonEncoder) Encode(items map[string]Item) error {
+  return e.enc.Encode(items)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+)
+
+//单个参数的最大长度，与 Redis 的 proto-max-bulk-len 默认值一致
+const maxBulkLen = 512 << 20
+
+//读取一条命令，支持 RESP 数组格式和 telnet 使用的内联格式
+func readCommand(r *bufio.Reader) ([]string, error) {
+  line, err := readLine(r)
+  if err != nil {
+    return nil, err
+  }
+  if len(line) == 0 {
+    return nil, nil
+  }
+  if line[0] != '*' {
+    return strings.Fields(line), nil
+  }
+  n, err := strconv.Atoi(line[1:])
+  if err != nil || n < 0 {
+    return nil, fmt.Errorf("Protocol error: invalid multibulk length")
+  }
+  args := make([]string, 0, n)
+  for i := 0; i < n; i++ {
+    line, err := readLine(r)
+    if err != nil {
+      return nil, err
+    }
+    if len(line) == 0 || line[0] != '$' {
+      return nil, fmt.Errorf("Protocol error: expected '$', got '%s'", line)
+    }
+    l, err := strconv.Atoi(line[1:])
+    if err != nil || l < 0 || l > maxBulkLen {
+      return nil, fmt.Errorf("Protocol error: invalid bulk length")
+    }
+    buf := make([]byte, l+2)
+    if _, err := io.ReadFull(r, buf); err != nil {
+      return nil, err
+    }
+    args = append(args, string(buf[:l]))
+  }
+  return args, nil
+}
+
+//读取一行并去掉结尾的 \r\n
+func readLine(r *bufio.Reader) (string, error) {
+  line, err := r.ReadString('\n')
+  if err != nil {
+    return "", err
+  }
+  return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+  w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, s string) {
+  w.WriteString("-" + s + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+  w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+  w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNull(w *bufio.Writer) {
+  w.WriteString("$-1\r\n")
+}
+
+func writeArray(w *bufio.Writer, items []string) {
+  w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+  for _, s := range items {
+    writeBulk(w, s)
+  }
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+  "bufio"
+  "context"
+  "errors"
+  "fmt"
+  "net"
+  "path"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "cache"
+)
+
+//以 Redis 协议对外提供缓存服务
+type Server struct {
+  c      *cache.Cache
+  mu     sync.Mutex
+  ln     net.Listener
+  conns  map[net.Conn]struct{}
+  wg     sync.WaitGroup
+  closed bool
+}
+
+//创建一个对外提供 c 的服务
+func NewServer(c *cache.Cache) *Server {
+  return &Server {
+    c: c,
+    conns: map[net.Conn]struct{}{},
+  }
+}
+
+//在 addr 上监听并以 Redis 协议提供 c，直到出错返回
+func ListenAndServe(addr string, c *cache.Cache) error {
+  return NewServer(c).ListenAndServe(addr)
+}
+
+//在 addr 上监听并提供服务，Shutdown 后返回 nil
+func (s *Server) ListenAndServe(addr string) error {
+  ln, err := net.Listen("tcp", addr)
+  if err != nil {
+    return err
+  }
+  return s.Serve(ln)
+}
+
+//在 ln 上接受连接并提供服务，Shutdown 后返回 nil
+func (s *Server) Serve(ln net.Listener) error {
+  s.mu.Lock()
+  if s.closed {
+    s.mu.Unlock()
+    ln.Close()
+    return fmt.Errorf("Server has been shut down.")
+  }
+  s.ln = ln
+  s.mu.Unlock()
+  for {
+    conn, err := ln.Accept()
+    if err != nil {
+      s.mu.Lock()
+      closed := s.closed
+      s.mu.Unlock()
+      if closed {
+        return nil
+      }
+      return err
+    }
+    s.mu.Lock()
+    s.conns[conn] = struct{}{}
+    s.wg.Add(1)
+    s.mu.Unlock()
+    go s.serveConn(conn)
+  }
+}
+
+//停止接受新连接，正在执行的命令完成后关闭连接，ctx 结束时强制关闭剩余的连接
+func (s *Server) Shutdown(ctx context.Context) error {
+  s.mu.Lock()
+  s.closed = true
+  if s.ln != nil {
+    s.ln.Close()
+  }
+  //唤醒阻塞在读取上的空闲连接
+  for conn := range s.conns {
+    conn.SetReadDeadline(time.Now())
+  }
+  s.mu.Unlock()
+
+  done := make(chan struct{})
+  go func() {
+    s.wg.Wait()
+    close(done)
+  }()
+  select {
+  case <-done:
+    return nil
+  case <-ctx.Done():
+    s.mu.Lock()
+    for conn := range s.conns {
+      conn.Close()
+    }
+    s.mu.Unlock()
+    return ctx.Err()
+  }
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+  defer func() {
+    s.mu.Lock()
+    delete(s.conns, conn)
+    s.mu.Unlock()
+    conn.Close()
+    s.wg.Done()
+  }()
+  r := bufio.NewReader(conn)
+  w := bufio.NewWriter(conn)
+  for {
+    args, err := readCommand(r)
+    if err != nil {
+      if strings.HasPrefix(err.Error(), "Protocol error") {
+        writeError(w, "ERR "+err.Error())
+        w.Flush()
+      }
+      return
+    }
+    if len(args) == 0 {
+      continue
+    }
+    quit := s.exec(w, args)
+    //管道中的后续命令已经在缓冲区时合并写出
+    if r.Buffered() == 0 || quit {
+      if w.Flush() != nil {
+        return
+      }
+    }
+    s.mu.Lock()
+    closed := s.closed
+    s.mu.Unlock()
+    if quit || closed {
+      w.Flush()
+      return
+    }
+  }
+}
+
+//执行一条命令，返回是否需要关闭连接
+func (s *Server) exec(w *bufio.Writer, args []string) bool {
+  name := strings.ToUpper(args[0])
+  args = args[1:]
+  wrongArgs := func() {
+    writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+  }
+  switch name {
+  case "PING":
+    if len(args) > 0 {
+      writeBulk(w, args[0])
+    } else {
+      writeSimple(w, "PONG")
+    }
+  case "QUIT":
+    writeSimple(w, "OK")
+    return true
+  case "GET":
+    if len(args) != 1 {
+      wrongArgs()
+      break
+    }
+    if v, found := s.c.Get(args[0]); found {
+      writeBulk(w, format(v))
+    } else {
+      writeNull(w)
+    }
+  case "SET":
+    if len(args) < 2 {
+      wrongArgs()
+      break
+    }
+    s.set(w, args)
+  case "DEL":
+    if len(args) == 0 {
+      wrongArgs()
+      break
+    }
+    var n int64
+    for _, k := range args {
+      if _, found := s.c.Get(k); found {
+        n++
+      }
+      s.c.Delete(k)
+    }
+    writeInt(w, n)
+  case "EXISTS":
+    if len(args) == 0 {
+      wrongArgs()
+      break
+    }
+    var n int64
+    for _, k := range args {
+      if _, found := s.c.Get(k); found {
+        n++
+      }
+    }
+    writeInt(w, n)
+  case "EXPIRE", "PEXPIRE":
+    if len(args) != 2 {
+      wrongArgs()
+      break
+    }
+    n, err := strconv.ParseInt(args[1], 10, 64)
+    if err != nil {
+      writeError(w, "ERR value is not an integer or out of range")
+      break
+    }
+    unit := time.Second
+    if name == "PEXPIRE" {
+      unit = time.Millisecond
+    }
+    if n <= 0 {
+      //过期时间不为正数时与 Redis 一样直接删除
+      _, found := s.c.Get(args[0])
+      s.c.Delete(args[0])
+      writeBool(w, found)
+      break
+    }
+    writeBool(w, s.c.Expire(args[0], time.Duration(n)*unit))
+  case "PERSIST":
+    if len(args) != 1 {
+      wrongArgs()
+      break
+    }
+    ttl, found := s.c.TTL(args[0])
+    writeBool(w, found && ttl != cache.NoExpiration && s.c.Persist(args[0]))
+  case "TTL", "PTTL":
+    if len(args) != 1 {
+      wrongArgs()
+      break
+    }
+    ttl, found := s.c.TTL(args[0])
+    switch {
+    case !found:
+      writeInt(w, -2)
+    case ttl == cache.NoExpiration:
+      writeInt(w, -1)
+    case name == "PTTL":
+      writeInt(w, int64(ttl/time.Millisecond))
+    default:
+      writeInt(w, int64((ttl+time.Second/2)/time.Second))
+    }
+  case "INCR", "DECR", "INCRBY", "DECRBY":
+    s.incr(w, name, args)
+  case "FLUSHDB", "FLUSHALL":
+    s.c.Flush()
+    writeSimple(w, "OK")
+  case "DBSIZE":
+    writeInt(w, int64(s.c.Count()))
+  case "KEYS":
+    if len(args) != 1 {
+      wrongArgs()
+      break
+    }
+    keys := []string{}
+    for _, k := range s.c.Keys() {
+      if ok, _ := path.Match(args[0], k); ok {
+        keys = append(keys, k)
+      }
+    }
+    writeArray(w, keys)
+  default:
+    writeError(w, "ERR unknown command '"+strings.ToLower(name)+"'")
+  }
+  return false
+}
+
+//SET key value [EX seconds|PX milliseconds] [NX|XX]
+func (s *Server) set(w *bufio.Writer, args []string) {
+  k, v := args[0], value(args[1])
+  d := cache.NoExpiration
+  nx, xx := false, false
+  for i := 2; i < len(args); i++ {
+    switch strings.ToUpper(args[i]) {
+    case "NX":
+      nx = true
+    case "XX":
+      xx = true
+    case "EX", "PX":
+      if i+1 >= len(args) {
+        writeError(w, "ERR syntax error")
+        return
+      }
+      n, err := strconv.ParseInt(args[i+1], 10, 64)
+      if err != nil || n <= 0 {
+        writeError(w, "ERR invalid expire time in 'set' command")
+        return
+      }
+      if strings.ToUpper(args[i]) == "EX" {
+        d = time.Duration(n) * time.Second
+      } else {
+        d = time.Duration(n) * time.Millisecond
+      }
+      i++
+    default:
+      writeError(w, "ERR syntax error")
+      return
+    }
+  }
+  switch {
+  case nx && xx:
+    writeError(w, "ERR syntax error")
+  case nx:
+    if s.c.Add(k, v, d) != nil {
+      writeNull(w)
+      return
+    }
+    writeSimple(w, "OK")
+  case xx:
+    if s.c.Replace(k, v, d) != nil {
+      writeNull(w)
+      return
+    }
+    writeSimple(w, "OK")
+  default:
+    s.c.Set(k, v, cache.WithTTL(d))
+    writeSimple(w, "OK")
+  }
+}
+
+//INCR/DECR/INCRBY/DECRBY，不存在的键从 0 开始计算
+func (s *Server) incr(w *bufio.Writer, name string, args []string) {
+  n := int64(1)
+  if name == "INCRBY" || name == "DECRBY" {
+    if len(args) != 2 {
+      writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+      return
+    }
+    var err error
+    if n, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+      writeError(w, "ERR value is not an integer or out of range")
+      return
+    }
+  } else if len(args) != 1 {
+    writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+    return
+  }
+  if strings.HasPrefix(name, "DECR") {
+    n = -n
+  }
+  for {
+    if err := s.c.Add(args[0], int64(0), cache.NoExpiration); fatal(err) {
+      writeError(w, "ERR "+err.Error())
+      return
+    }
+    nv, err := s.c.Increment(args[0], n)
+    if err == nil {
+      writeInt(w, nv)
+      return
+    }
+    if fatal(err) {
+      writeError(w, "ERR "+err.Error())
+      return
+    }
+    //数据项在 Add 和 Increment 之间被删除时重试
+    if _, found := s.c.Get(args[0]); found {
+      writeError(w, "ERR value is not an integer or out of range")
+      return
+    }
+  }
+}
+
+//缓存已关闭、已冻结或值超过大小上限，重试也不会成功
+func fatal(err error) bool {
+  var tooLarge *cache.ItemTooLargeError
+  return errors.Is(err, cache.ErrClosed) || errors.Is(err, cache.ErrReadOnly) || errors.As(err, &tooLarge)
+}
+
+func writeBool(w *bufio.Writer, b bool) {
+  if b {
+    writeInt(w, 1)
+  } else {
+    writeInt(w, 0)
+  }
+}
+
+//规范格式的整数按 int64 保存以支持 INCR，其余按字符串保存
+func value(s string) interface{} {
+  if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+    return n
+  }
+  return s
+}
+
+//把数据项格式化为字符串返回给客户端
+func format(v interface{}) string {
+  switch v := v.(type) {
+  case string:
+    return v
+  case []byte:
+    return string(v)
+  }
+  return fmt.Sprint(v)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+  "bufio"
+  "context"
+  "net"
+  "strings"
+  "testing"
+  "time"
+
+  "cache"
+)
+
+//启动服务并返回一个已连接的客户端，测试结束时关闭服务
+func startServer(t *testing.T, c *cache.Cache) (net.Conn, *bufio.Reader) {
+  t.Helper()
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  s := NewServer(c)
+  go s.Serve(ln)
+  conn, err := net.Dial("tcp", ln.Addr().String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() {
+    conn.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := s.Shutdown(ctx); err != nil {
+      t.Errorf("Shutdown: %v", err)
+    }
+  })
+  return conn, bufio.NewReader(conn)
+}
+
+func command(t *testing.T, conn net.Conn, r *bufio.Reader, cmd string) string {
+  t.Helper()
+  conn.SetDeadline(time.Now().Add(5 * time.Second))
+  if _, err := conn.Write([]byte(cmd + "\r\n")); err != nil {
+    t.Fatal(err)
+  }
+  line, err := r.ReadString('\n')
+  if err != nil {
+    t.Fatalf("%s: %v", cmd, err)
+  }
+  return strings.TrimSuffix(line, "\r\n")
+}
+
+func TestIncr(t *testing.T) {
+  conn, r := startServer(t, cache.New())
+  if got := command(t, conn, r, "INCR x"); got != ":1" {
+    t.Fatalf("INCR x = %q, want :1", got)
+  }
+  if got := command(t, conn, r, "INCRBY x 5"); got != ":6" {
+    t.Fatalf("INCRBY x 5 = %q, want :6", got)
+  }
+  command(t, conn, r, "SET s abc")
+  if got := command(t, conn, r, "INCR s"); !strings.HasPrefix(got, "-ERR") {
+    t.Fatalf("INCR s = %q, want an error", got)
+  }
+}
+
+func TestIncrFrozen(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  command(t, conn, r, "INCR x")
+  c.Freeze()
+  for _, k := range []string{"x", "missing"} {
+    if got := command(t, conn, r, "INCR "+k); !strings.HasPrefix(got, "-ERR") {
+      t.Fatalf("INCR %s on a frozen cache = %q, want an error", k, got)
+    }
+  }
+}
+
+func TestIncrClosed(t *testing.T) {
+  c := cache.New()
+  conn, r := startServer(t, c)
+  c.Close()
+  if got := command(t, conn, r, "INCR x"); !strings.HasPrefix(got, "-ERR") {
+    t.Fatalf("INCR x on a closed cache = %q, want an error", got)
+  }
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+  "container/list"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+//默认的分片数量
+const DefaultShards = 256
+
+//分片，每个分片有自己的锁、数据项和访问顺序
+type shard struct {
+  c             *Cache
+  mu            shardMutex
+  items         map[string]Item
+  calls         map[string]*call  // 正在计算中的数据项
+  lru           *list.List  // 访问顺序，最近使用的在前，为 nil 时不记录
+  lruIndex      map[string]*list.Element
+  lruMu         sync.Mutex  // 读锁下更新访问顺序和访问频率时使用
+  arc           *arc  // ARC 的访问顺序，为 nil 时不使用 ARC
+  freq          *sketch  // TinyLFU 的访问频率，为 nil 时不使用准入策略
+  expHeap       expHeap  // 按过期时间排序的数据项
+  expIndex      map[string]*expEntry
+  evictable     [priorities]int  // 各优先级未固定的数据项数量，淘汰时据此跳过没有数据项的优先级
+  tags          map[string]map[string]struct{}  // 标签到数据项的索引，没有标签时为 nil
+  keyTags       map[string][]string
+  dryRunPending []Eviction
+  evicted       []evictedItem
+  overflow      *budget  // 本分片淘汰完仍超出上限时，解锁后继续在其他分片淘汰
+  hot           atomic.Pointer[hotKeys]  // 热点键统计，为 nil 时不统计，修改时需要持有全部分片的写锁
+  ro            atomic.Pointer[roMap]  // 无锁读取的索引，为 nil 时关闭
+  roMisses      int64  // 无锁读取时索引未命中的次数，原子访问
+  spills        []spill  // 解锁后写入磁盘层的数据项
+  diskDrops     []string  // 解锁后从磁盘层删除的文件
+}
+
+//淘汰过程中的成本总和与数据项数量，演练模式下用于模拟淘汰后的结果
+type budget struct {
+  cost  int64
+  count int64
+}
+
+func newShard(c *Cache) *shard {
+  return &shard {
+    c: c,
+    items: map[string]Item{},
+    calls: map[string]*call{},
+    expIndex: map[string]*expEntry{},
+  }
+}
+
+//根据键的 FNV-1a 哈希选择分片
+func (c *Cache) shard(k string) *shard {
+  if len(c.shards) == 1 {
+    return c.shards[0]
+  }
+  h := uint32(2166136261)
+  for i := 0; i < len(k); i++ {
+    h ^= uint32(k[i])
+    h *= 16777619
+  }
+  return c.shards[h%uint32(len(c.shards))]
+}
+
+//按分片对键分组，同一分片的键只需加一次锁
+func (c *Cache) byShard(keys []string) map[*shard][]string {
+  groups := map[*shard][]string{}
+  for _, k := range keys {
+    s := c.shard(k)
+    groups[s] = append(groups[s], k)
+  }
+  return groups
+}
+
+//按顺序锁住全部分片，修改配置时使用
+func (c *Cache) lockAll() {
+  g := c.lockGlobal.Load()
+  if g == nil {
+    for _, s := range c.shards {
+      s.mu.Lock()
+    }
+    return
+  }
+  start := time.Now()
+  contended := false
+  for _, s := range c.shards {
+    if !s.mu.RWMutex.TryLock() {
+      s.mu.RWMutex.Lock()
+      contended = true
+    }
+  }
+  if contended {
+    g.observe(time.Since(start))
+  } else {
+    g.observe(0)
+  }
+}
+
+//释放全部分片后才触发回调，回调中访问其他分片不会死锁
+func (c *Cache) unlockAll() {
+  after := make([]afterUnlock, len(c.shards))
+  for i, s := range c.shards {
+    after[i] = s.release()
+  }
+  for _, a := range after {
+    a.run()
+  }
+}
+
+//释放写锁，在锁外触发期间积累的回调，并按需在其他分片继续淘汰
+func (s *shard) unlock() {
+  a := s.release()
+  a.run()
+}
+
+//解锁时取出的、需要在锁外执行的回调和淘汰
+type afterUnlock struct {
+  s         *shard
+  pending   []Eviction
+  onDryRun  func(string, EvictionReason)
+  evicted   []evictedItem
+  onEvicted func(string, interface{})
+  onRemoved func(string, interface{}, EvictionReason)
+  overflow  *budget
+  spills    []spill
+  diskDrops []string
+}
+
+func (s *shard) release() afterUnlock {
+  a := afterUnlock{
+    s: s,
+    pending: s.dryRunPending,
+    onDryRun: s.c.onDryRun,
+    evicted: s.evicted,
+    onEvicted: s.c.onEvicted,
+    onRemoved: s.c.onRemoved,
+    overflow: s.overflow,
+    spills: s.spills,
+    diskDrops: s.diskDrops,
+  }
+  s.dryRunPending = nil
+  s.evicted = nil
+  s.overflow = nil
+  s.spills, s.diskDrops = nil, nil
+  s.mu.Unlock()
+  return a
+}
+
+func (a *afterUnlock) run() {
+  if a.onDryRun != nil {
+    for _, e := range a.pending {
+      a.onDryRun(e.Key, e.Reason)
+    }
+  }
+  for _, e := range a.evicted {
+    //被新值覆盖不算移除，OnEvicted 保持原有的行为
+    if a.onEvicted != nil && e.reason != ReasonReplaced {
+      a.onEvicted(e.key, e.value)
+    }
+    if a.onRemoved != nil {
+      a.onRemoved(e.key, e.value, e.reason)
+    }
+  }
+  if len(a.spills) > 0 || len(a.diskDrops) > 0 {
+    a.s.c.writeDisk(a.spills, a.diskDrops)
+  }
+  if a.overflow != nil {
+    a.s.c.evictOthers(a.s, a.overflow)
+  }
+}
+
+//从 from 以外的分片中淘汰数据项，直到不超过上限
+func (c *Cache) evictOthers(from *shard, b *budget) {
+  for _, s := range c.shards {
+    if s == from {
+      continue
+    }
+    s.mu.Lock()
+    over := s.evict("", b)
+    s.unlock()
+    if !over {
+      return
+    }
+  }
+}
+
+//当前的成本总和与数据项数量
+func (c *Cache) budget() *budget {
+  return &budget{atomic.LoadInt64(&c.cost), atomic.LoadInt64(&c.entries)}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+  "fmt"
+  "sync"
+  "testing"
+)
+
+//键分散到各个分片，Count 和 Items 汇总所有分片
+func TestShardsDistribute(t *testing.T) {
+  c := New(WithShards(16))
+  for i := 0; i < 1000; i++ {
+    c.Set(fmt.Sprintf("k%d", i), i)
+  }
+  total := 0
+  for i, s := range c.shards {
+    if len(s.items) == 0 {
+      t.Fatalf("shard %d is empty", i)
+    }
+    total += len(s.items)
+  }
+  if total != 1000 || c.Count() != 1000 || len(c.Items()) != 1000 {
+    t.Fatalf("shards hold %d items, Count() = %d, Items() has %d, want 1000", total, c.Count(), len(c.Items()))
+  }
+  for i := 0; i < 1000; i++ {
+    k := fmt.Sprintf("k%d", i)
+    if _, found := c.shard(k).items[k]; !found {
+      t.Fatalf("%s is not in the shard it hashes to", k)
+    }
+  }
+  c.Flush()
+  for i, s := range c.shards {
+    if len(s.items) != 0 {
+      t.Fatalf("shard %d still has %d items after Flush", i, len(s.items))
+    }
+  }
+  if c.Count() != 0 {
+    t.Fatalf("Count() = %d after Flush, want 0", c.Count())
+  }
+}
+
+//不同分片的读写互不影响，并发写入后计数与分片中的数据项一致
+func TestShardsConcurrent(t *testing.T) {
+  c := New(WithShards(8))
+  var wg sync.WaitGroup
+  for g := 0; g < 8; g++ {
+    wg.Add(1)
+    go func(g int) {
+      defer wg.Done()
+      for i := 0; i < 500; i++ {
+        k := fmt.Sprintf("g%d-%d", g, i)
+        c.Set(k, i)
+        if v, found := c.Get(k); !found || v != i {
+          t.Errorf("Get(%s) = %v, %v, want %d", k, v, found, i)
+          return
+        }
+        if i%2 == 1 {
+          c.Delete(k)
+        }
+      }
+    }(g)
+  }
+  wg.Wait()
+  total := 0
+  for _, s := range c.shards {
+    total += len(s.items)
+  }
+  if c.Count() != 8*250 || total != 8*250 {
+    t.Fatalf("Count() = %d, shards hold %d, want %d", c.Count(), total, 8*250)
+  }
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+  "context"
+  "os"
+  "os/signal"
+  "sync"
+  "syscall"
+)
+
+//收到 SIGINT 或 SIGTERM 时关闭缓存并保存到 path，然后按信号原来的处理方式退出进程。
+//保存失败时通过 Logger 报告，返回的函数取消信号处理
+func (c *Cache) SaveOnShutdown(path string) (cancel func()) {
+  ch := make(chan os.Signal, 1)
+  signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+  quit := make(chan struct{})
+  go func() {
+    defer signal.Stop(ch)
+    select {
+    case sig := <-ch:
+      c.shutdownSave(path)
+      //没有其他接收者时 Stop 恢复默认处理，重新发送信号让进程照常退出
+      signal.Stop(ch)
+      if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+        os.Exit(1)
+      }
+    case <-quit:
+    }
+  }()
+  var once sync.Once
+  return func() { once.Do(func() { close(quit) }) }
+}
+
+//ctx 结束时关闭缓存并保存到 path，保存完成后返回的 channel 收到保存的结果后关闭。
+//可以配合 signal.NotifyContext 使用，由调用者在进程退出前等待保存完成
+func (c *Cache) SaveOnDone(ctx context.Context, path string) <-chan error {
+  done := make(chan error, 1)
+  go func() {
+    <-ctx.Done()
+    done <- c.shutdownSave(path)
+    close(done)
+  }()
+  return done
+}
+
+//先关闭缓存使之后的写入失败，再保存关闭时的数据项
+func (c *Cache) shutdownSave(path string) error {
+  c.Close()
+  err := c.saveFileAtomic(path)
+  if err != nil {
+    c.log().Errorf("Saving to %s on shutdown failed: %v", path, err)
+  }
+  return err
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+  "reflect"
+)
+
+//估算数据项占用的字节数，在分片锁内调用，不能再调用缓存的方法
+type Sizer func(key string, value interface{}) int64
+
+//每个数据项的固定开销估算，包括 map 槽位、Item 结构和接口头
+const itemOverhead = 64
+
+//默认的字节数估算，只识别常见的类型，其余类型按固定开销计算
+func DefaultSizer(key string, value interface{}) int64 {
+  n := int64(len(key) + itemOverhead)
+  switch v := value.(type) {
+  case string:
+    n += int64(len(v))
+  case []byte:
+    n += int64(len(v))
+  case []string:
+    for _, s := range v {
+      n += int64(len(s)) + 16
+    }
+  }
+  return n
+}
+
+//设置最大字节数，超出时按 LRU 或过期优先的顺序淘汰。sizer 为 nil 时使用 DefaultSizer。
+//字节数记作数据项的成本，与 SetMaxCost 共用同一个上限
+func (c *Cache) SetMaxBytes(n int64, sizer Sizer) {
+  if sizer == nil {
+    sizer = DefaultSizer
+  }
+  c.lockAll()
+  c.sizer = sizer
+  c.unlockAll()
+  c.SetMaxCost(n)
+}
+
+//按 sizer 计算数据项的成本，需要持有分片的锁
+func (c *Cache) costOf(k string, v interface{}) int64 {
+  if c.sizer == nil {
+    return 1
+  }
+  return c.sizer(k, v)
+}
+
+//估算嵌套结构时的最大深度，更深的部分不计入
+const maxSizeDepth = 32
+
+//用反射估算值占用的字节数，包括字符串、切片、map 和指针引用的内存，
+//同一块内存只计算一次。chan 和函数只计算头部，结果是近似值
+func EstimateSize(v interface{}) int64 {
+  if v == nil {
+    return 0
+  }
+  rv := reflect.ValueOf(v)
+  return int64(rv.Type().Size()) + indirectSize(rv, map[uintptr]bool{}, 0)
+}
+
+//返回 v 引用的内存，不包括 v 本身
+func indirectSize(v reflect.Value, seen map[uintptr]bool, depth int) int64 {
+  if depth > maxSizeDepth {
+    return 0
+  }
+  switch v.Kind() {
+  case reflect.String:
+    return int64(v.Len())
+  case reflect.Slice:
+    if v.IsNil() || seen[v.Pointer()] {
+      return 0
+    }
+    seen[v.Pointer()] = true
+    n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+    if hasIndirect(v.Type().Elem()) {
+      for i := 0; i < v.Len(); i++ {
+        n += indirectSize(v.Index(i), seen, depth+1)
+      }
+    }
+    return n
+  case reflect.Array:
+    var n int64
+    if hasIndirect(v.Type().Elem()) {
+      for i := 0; i < v.Len(); i++ {
+        n += indirectSize(v.Index(i), seen, depth+1)
+      }
+    }
+    return n
+  case reflect.Map:
+    if v.IsNil() || seen[v.Pointer()] {
+      return 0
+    }
+    seen[v.Pointer()] = true
+    //桶的开销按每个键值对多 8 字节估算
+    t := v.Type()
+    n := int64(48) + int64(v.Len()) * (int64(t.Key().Size()) + int64(t.Elem().Size()) + 8)
+    if hasIndirect(t.Key()) || hasIndirect(t.Elem()) {
+      it := v.MapRange()
+      for it.Next() {
+        n += indirectSize(it.Key(), seen, depth+1) + indirectSize(it.Value(), seen, depth+1)
+      }
+    }
+    return n
+  case reflect.Ptr:
+    if v.IsNil() || seen[v.Pointer()] {
+      return 0
+    }
+    seen[v.Pointer()] = true
+    return int64(v.Type().Elem().Size()) + indirectSize(v.Elem(), seen, depth+1)
+  case reflect.Interface:
+    if v.IsNil() {
+      return 0
+    }
+    e := v.Elem()
+    return int64(e.Type().Size()) + indirectSize(e, seen, depth+1)
+  case reflect.Struct:
+    var n int64
+    for i := 0; i < v.NumField(); i++ {
+      n += indirectSize(v.Field(i), seen, depth+1)
+    }
+    return n
+  }
+  return 0
+}
+
+//判断该类型的值是否可能引用其他内存
+func hasIndirect(t reflect.Type) bool {
+  switch t.Kind() {
+  case reflect.String, reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+    return true
+  case reflect.Array:
+    return hasIndirect(t.Elem())
+  case reflect.Struct:
+    for i := 0; i < t.NumField(); i++ {
+      if hasIndirect(t.Field(i).Type) {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+//设置 SizeBytes 估算每个数据项的函数，nil 表示使用键长、固定开销与 EstimateSize 之和
+func (c *Cache) SetSizeEstimator(f Sizer) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.estimator = f
+}
+
+//返回所有数据项占用内存的估算字节数。使用 SetMaxBytes 时成本就是字节数，直接返回成本总和，
+//否则逐个分片估算，耗时与数据项数量成正比，适合监控定期调用
+func (c *Cache) SizeBytes() int64 {
+  var total int64
+  for _, s := range c.shards {
+    s.mu.RLock()
+    if c.sizer != nil {
+      s.mu.RUnlock()
+      return c.Cost()
+    }
+    f := c.estimator
+    for k, item := range s.items {
+      if f != nil {
+        total += f(k, item.Object)
+      } else {
+        total += int64(len(k) + itemOverhead) + EstimateSize(item.Object)
+      }
+    }
+    s.mu.RUnlock()
+  }
+  return total
+}
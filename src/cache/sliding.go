@@ -0,0 +1,60 @@
+package cache
+
+import (
+  "time"
+)
+
+//设置使用滑动过期的数据项，每次成功读取后生存时间重新从 d 开始计算，
+//d 的含义与 Set 相同，永不过期的数据项不会滑动
+func (c *Cache) SetSliding(k string, v interface{}, d time.Duration) {
+  if d == DefaultExpiration {
+    d = c.defaultExpiration
+  }
+  var e, sliding int64
+  if d > 0 {
+    e = c.clock.Now().Add(d).UnixNano()
+    sliding = int64(d)
+  }
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  v, z := c.compress(v)
+  s.setItem(k, Item {
+    Object: v,
+    Expiration: e,
+    Cost: c.costOf(k, v),
+    Sliding: sliding,
+    Compressed: z,
+  })
+}
+
+//开启后，通过 Set 等方法设置的带过期时间的数据项都使用滑动过期，已有的数据项不受影响
+func (c *Cache) SetSlidingExpiration(on bool) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.sliding = on
+}
+
+//延长滑动过期数据项的生存时间，读取时只持有读锁，需要在解锁后调用
+func (s *shard) slide(keys ...string) {
+  if len(keys) == 0 {
+    return
+  }
+  now := s.c.now()
+  s.mu.Lock()
+  for _, k := range keys {
+    //解锁期间可能已被删除或重新设置，需要再次确认
+    item, found := s.items[k]
+    if !found || item.Sliding <= 0 || s.c.expired(item) {
+      continue
+    }
+    item.Expiration = now + item.Sliding
+    s.items[k] = item
+    s.publish(k, &item)
+    s.schedule(k, item.Expiration)
+  }
+  s.unlock()
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+  "sort"
+)
+
+//Save、ExportJSONL 和 Range 按键的顺序遍历，内容相同的缓存写出相同的转储，便于比较不同环境的快照。
+//开启后需要先复制全部数据项再排序，内存占用与整个缓存相当，与 WithSortedIteration 相同
+func (c *Cache) SetSortedIteration(on bool) {
+  c.lockAll()
+  defer c.unlockAll()
+  c.sorted = on
+}
+
+func (c *Cache) sortedIteration() bool {
+  c.shards[0].mu.RLock()
+  defer c.shards[0].mu.RUnlock()
+  return c.sorted
+}
+
+//返回按顺序排列的键
+func sortedKeys(items map[string]Item) []string {
+  keys := make([]string, 0, len(items))
+  for k := range items {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+//每个数据项单独编码为一块，块内只有一个键，编码结果不受 map 遍历顺序的影响
+func (c *Cache) saveSorted(s Serializer, enc ChunkEncoder) (int, error) {
+  items := c.snapshot()
+  c.portable(s, items)
+  keys := sortedKeys(items)
+  if len(keys) == 0 {
+    return 0, enc.Encode(items)
+  }
+  for i, k := range keys {
+    if err := enc.Encode(map[string]Item{k: items[k]}); err != nil {
+      return i, err
+    }
+  }
+  return len(keys), nil
+}
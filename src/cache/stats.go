@@ -0,0 +1,103 @@
+package cache
+
+import (
+  "sync/atomic"
+  "time"
+)
+
+//缓存的统计数据
+type Stats struct {
+  Hits      uint64  // 命中次数
+  Misses    uint64  // 未命中次数
+  Sets      uint64  // 设置次数
+  Deletes   uint64  // 删除次数
+  Expired   uint64  // 过期清理的数据项数量
+  Evictions uint64  // 因容量限制淘汰的数据项数量
+  Rejected  uint64  // 未通过 TinyLFU 准入而没有放入的数据项数量
+  Items     int     // 当前数据项数量
+  Cost      int64   // 当前数据项的成本总和
+  SweepDuration time.Duration  // 最近一次过期清理的耗时
+  Loads         uint64  // 完成的计算次数，包括 LoadingCache 的加载和 GetOrSetWithStatus 等的计算
+  LoadErrors    uint64  // 返回错误或 panic 的计算次数，不包括 ErrNotFound
+  LoadsInFlight int64   // 正在进行的计算数量
+  Coalesced     uint64  // 等待同一个键正在进行的计算而没有自己计算的次数
+  LoadTime      time.Duration  // 全部计算的耗时总和
+  LoadLatency   LoadLatency    // 最近的计算耗时的分位数
+  Locks         LockStats      // 锁等待时间，没有调用 SetLockProfiling 开启时为零值
+}
+
+//统计计数器，均为原子访问
+type counters struct {
+  hits          uint64
+  misses        uint64
+  sets          uint64
+  deletes       uint64
+  expired       uint64
+  evictions     uint64
+  rejected      uint64
+  loads         uint64
+  loadErrors    uint64
+  coalesced     uint64
+  loadTime      int64
+  loadsInFlight int64
+}
+
+func (c *Cache) hit(found bool) {
+  if found {
+    atomic.AddUint64(&c.stats.hits, 1)
+  } else {
+    atomic.AddUint64(&c.stats.misses, 1)
+  }
+}
+
+//按删除事件的类型计数
+func (c *Cache) count(t EventType) {
+  switch t {
+  case EventDelete:
+    atomic.AddUint64(&c.stats.deletes, 1)
+  case EventExpire:
+    atomic.AddUint64(&c.stats.expired, 1)
+  case EventEvict:
+    atomic.AddUint64(&c.stats.evictions, 1)
+  }
+}
+
+//返回统计数据的快照
+func (c *Cache) Stats() Stats {
+  return Stats {
+    Hits: atomic.LoadUint64(&c.stats.hits),
+    Misses: atomic.LoadUint64(&c.stats.misses),
+    Sets: atomic.LoadUint64(&c.stats.sets),
+    Deletes: atomic.LoadUint64(&c.stats.deletes),
+    Expired: atomic.LoadUint64(&c.stats.expired),
+    Evictions: atomic.LoadUint64(&c.stats.evictions),
+    Rejected: atomic.LoadUint64(&c.stats.rejected),
+    Items: c.Count(),
+    Cost: c.Cost(),
+    SweepDuration: time.Duration(atomic.LoadInt64(&c.sweepDuration)),
+    Loads: atomic.LoadUint64(&c.stats.loads),
+    LoadErrors: atomic.LoadUint64(&c.stats.loadErrors),
+    LoadsInFlight: atomic.LoadInt64(&c.stats.loadsInFlight),
+    Coalesced: atomic.LoadUint64(&c.stats.coalesced),
+    LoadTime: time.Duration(atomic.LoadInt64(&c.stats.loadTime)),
+    LoadLatency: c.loadLatency.percentiles(),
+    Locks: c.lockStats(),
+  }
+}
+
+//将统计计数清零，正在进行的计算数量不变
+func (c *Cache) ResetStats() {
+  atomic.StoreUint64(&c.stats.hits, 0)
+  atomic.StoreUint64(&c.stats.misses, 0)
+  atomic.StoreUint64(&c.stats.sets, 0)
+  atomic.StoreUint64(&c.stats.deletes, 0)
+  atomic.StoreUint64(&c.stats.expired, 0)
+  atomic.StoreUint64(&c.stats.evictions, 0)
+  atomic.StoreUint64(&c.stats.rejected, 0)
+  atomic.StoreUint64(&c.stats.loads, 0)
+  atomic.StoreUint64(&c.stats.loadErrors, 0)
+  atomic.StoreUint64(&c.stats.coalesced, 0)
+  atomic.StoreInt64(&c.stats.loadTime, 0)
+  c.loadLatency.reset()
+  c.resetLockStats()
+}
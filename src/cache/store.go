@@ -0,0 +1,127 @@
+package cache
+
+import (
+  "context"
+  "errors"
+  "time"
+)
+
+//后端存储中不存在数据项时返回的错误
+var ErrNotFound = errors.New("Item not found.")
+
+//后端存储，例如 Redis、数据库或磁盘。数据项不存在时 Get 返回 ErrNotFound
+type Store interface {
+  Get(ctx context.Context, key string) (interface{}, error)
+  Set(ctx context.Context, key string, value interface{}, d time.Duration) error
+  Delete(ctx context.Context, key string) error
+}
+
+//两级缓存，先查内存，未命中时从后端存储读取并放入内存，同一个键的并发读取合并为一次
+type TieredCache struct {
+  c            *Cache
+  store        Store
+  writeThrough bool
+  wb           *writeBehind  // 写回模式下的队列，其他模式为 nil
+}
+
+//创建一个两级缓存，writeThrough 为 true 时 Set 和 Delete 同时写入后端存储
+func NewTieredCache(store Store, writeThrough bool, opts ...Option) *TieredCache {
+  return &TieredCache {
+    c: New(opts...),
+    store: store,
+    writeThrough: writeThrough,
+  }
+}
+
+//返回内存中的缓存
+func (t *TieredCache) Cache() *Cache {
+  return t.c
+}
+
+//返回后端存储
+func (t *TieredCache) Store() Store {
+  return t.store
+}
+
+//获取数据项，内存中没有时从后端存储读取，读取到的数据项使用默认的过期时间
+func (t *TieredCache) Get(ctx context.Context, k string) (interface{}, error) {
+  ctx, end := t.c.trace(ctx, OpGet, k)
+  v, computed, err := t.c.getOrCompute(ctx, k, DefaultExpiration, func() (interface{}, error) {
+    if t.wb != nil {
+      if op, found := t.wb.lookup(k); found {
+        if op.delete {
+          return nil, ErrNotFound
+        }
+        return op.value, nil
+      }
+    }
+    return t.c.traceLoad(ctx, k, t.store.Get)
+  })
+  endGet(end, computed, err)
+  return v, err
+}
+
+//设置数据项，直写模式下先写后端存储，写入失败时不修改内存。
+//写回模式下先修改内存再放入队列
+func (t *TieredCache) Set(ctx context.Context, k string, v interface{}, d time.Duration) error {
+  ctx, end := t.c.trace(ctx, OpSet, k)
+  err := t.set(ctx, k, v, d)
+  end(false, err)
+  return err
+}
+
+func (t *TieredCache) set(ctx context.Context, k string, v interface{}, d time.Duration) error {
+  if t.c.Frozen() {
+    return ErrReadOnly
+  }
+  if t.wb != nil {
+    if err := t.c.trySet(k, v, d); err != nil {
+      return err
+    }
+    return t.wb.enqueue(ctx, k, writeOp{value: v, d: d})
+  }
+  if t.writeThrough {
+    if err := t.store.Set(ctx, k, v, d); err != nil {
+      return err
+    }
+  }
+  return t.c.trySet(k, v, d)
+}
+
+//删除数据项，直写模式下同时从后端存储删除，写回模式下放入队列
+func (t *TieredCache) Delete(ctx context.Context, k string) error {
+  ctx, end := t.c.trace(ctx, OpDelete, k)
+  err := t.delete(ctx, k)
+  end(false, err)
+  return err
+}
+
+func (t *TieredCache) delete(ctx context.Context, k string) error {
+  if t.c.Frozen() {
+    return ErrReadOnly
+  }
+  t.c.Delete(k)
+  if t.wb != nil {
+    return t.wb.enqueue(ctx, k, writeOp{delete: true})
+  }
+  if t.writeThrough {
+    return t.store.Delete(ctx, k)
+  }
+  return nil
+}
+
+//返回写回队列中等待写入后端存储的操作数量
+func (t *TieredCache) Pending() int {
+  if t.wb == nil {
+    return 0
+  }
+  return t.wb.len()
+}
+
+//关闭内存中的缓存，写回模式下先写完队列中的操作，不会关闭后端存储
+func (t *TieredCache) Close() error {
+  if t.wb != nil {
+    t.wb.close()
+  }
+  return t.c.Close()
+}
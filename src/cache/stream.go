@@ -0,0 +1,157 @@
+package cache
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/binary"
+  "encoding/gob"
+  "fmt"
+  "hash/crc32"
+  "io"
+)
+
+//流式转储的格式版本
+const streamVersion = 1
+
+var streamMagic = []byte("GOCSTRM\x00")
+
+//单条记录的长度上限，超过时视为长度字段损坏
+const maxStreamRecord = 1 << 30
+
+//LoadStream 每次放入缓存的记录数量
+const streamBatch = 4096
+
+//流式转储中的一条记录
+type streamRecord struct {
+  Key  string
+  Item Item
+}
+
+//流式转储损坏时 LoadStream 返回的错误，完好的记录已经放入缓存
+type StreamError struct {
+  Loaded    int   // 读入的记录数量
+  Skipped   int   // 校验失败跳过的记录数量
+  Truncated bool  // 流在结束标记之前中断
+}
+
+func (e *StreamError) Error() string {
+  if e.Truncated {
+    return fmt.Sprintf("Stream is truncated after %d items, %d items skipped.", e.Loaded, e.Skipped)
+  }
+  return fmt.Sprintf("Stream is corrupt, %d items loaded and %d items skipped.", e.Loaded, e.Skipped)
+}
+
+func (e *StreamError) Unwrap() error {
+  return ErrDumpCorrupt
+}
+
+//逐个写入未过期的数据项，每条记录为 4 字节长度、4 字节 CRC32 和独立的 gob 数据，
+//最后写入长度为 0 的结束标记。每次只复制一个分片，内存占用与单个分片的大小相当
+func (c *Cache) SaveStream(w io.Writer) error {
+  bw := bufio.NewWriter(w)
+  var h [10]byte
+  copy(h[:], streamMagic)
+  binary.BigEndian.PutUint16(h[8:], streamVersion)
+  if _, err := bw.Write(h[:]); err != nil {
+    return err
+  }
+  var buf bytes.Buffer
+  items := map[string]Item{}
+  for _, s := range c.shards {
+    c.copyShard(s, items)
+    for k, item := range items {
+      if err := checkTypes(map[string]Item{k: item}); err != nil {
+        return err
+      }
+      //每条记录使用新的编码器，任何一条都可以单独解码
+      buf.Reset()
+      if err := gob.NewEncoder(&buf).Encode(&streamRecord{k, item}); err != nil {
+        return err
+      }
+      if err := writeFrame(bw, buf.Bytes()); err != nil {
+        return err
+      }
+    }
+    clear(items)
+  }
+  if err := writeFrame(bw, nil); err != nil {
+    return err
+  }
+  return bw.Flush()
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+  var h [8]byte
+  binary.BigEndian.PutUint32(h[:4], uint32(len(b)))
+  binary.BigEndian.PutUint32(h[4:], crc32.ChecksumIEEE(b))
+  if _, err := w.Write(h[:]); err != nil {
+    return err
+  }
+  _, err := w.Write(b)
+  return err
+}
+
+//读取 SaveStream 写入的数据项，每读满一批就放入缓存，已过期的数据项被忽略。
+//校验失败的记录被跳过，流中断时保留已经读入的记录，两种情况都返回 *StreamError
+func (c *Cache) LoadStream(r io.Reader) error {
+  br := bufio.NewReader(r)
+  var h [10]byte
+  if _, err := io.ReadFull(br, h[:]); err != nil || !bytes.Equal(h[:8], streamMagic) {
+    return ErrDumpCorrupt
+  }
+  if binary.BigEndian.Uint16(h[8:]) != streamVersion {
+    return ErrDumpVersion
+  }
+  now := c.now()
+  se := &StreamError{}
+  batch := map[string]Item{}
+  flush := func() error {
+    err := c.load(batch, nil)
+    clear(batch)
+    return err
+  }
+  for {
+    var fh [8]byte
+    if _, err := io.ReadFull(br, fh[:]); err != nil {
+      se.Truncated = true
+      break
+    }
+    n := binary.BigEndian.Uint32(fh[:4])
+    if n == 0 {
+      break
+    }
+    //长度损坏时无法找到下一条记录的位置
+    if n > maxStreamRecord {
+      se.Truncated = true
+      break
+    }
+    b := make([]byte, n)
+    if _, err := io.ReadFull(br, b); err != nil {
+      se.Truncated = true
+      break
+    }
+    var rec streamRecord
+    if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(fh[4:]) || gob.NewDecoder(bytes.NewReader(b)).Decode(&rec) != nil {
+      se.Skipped++
+      continue
+    }
+    se.Loaded++
+    if rec.Item.Expiration > 0 && now > rec.Item.Expiration {
+      continue
+    }
+    batch[rec.Key] = rec.Item
+    if len(batch) >= streamBatch {
+      if err := flush(); err != nil {
+        return err
+      }
+    }
+  }
+  if err := flush(); err != nil {
+    return err
+  }
+  if se.Truncated || se.Skipped > 0 {
+    c.log().Warnf("%v", se)
+    return se
+  }
+  return nil
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+  "context"
+  "path"
+  "sync/atomic"
+)
+
+//每个订阅缓冲的事件数，缓冲满时新的事件被丢弃
+const subscribeBuffer = 256
+
+//一个事件订阅
+type subscription struct {
+  pattern string
+  exact   bool  // 只匹配键等于 pattern 的事件
+  ch      chan Event
+  done    chan struct{}  // 取消订阅时关闭，Watch 的 goroutine 据此退出
+}
+
+func (sub *subscription) match(e Event) bool {
+  if e.Type == EventFlush {
+    return true
+  }
+  if sub.exact {
+    return e.Key == sub.pattern
+  }
+  if sub.pattern == "" {
+    return true
+  }
+  ok, _ := path.Match(sub.pattern, e.Key)
+  return ok
+}
+
+//订阅键匹配 pattern 的数据项的设置、删除、过期和淘汰事件，pattern 的语法与 path.Match 相同，
+//空字符串匹配所有键，格式错误时不匹配任何键，清空事件总会发送。事件由后台 goroutine 按发生的顺序以非阻塞方式发送，
+//接收方来不及处理时超出缓冲的事件会被丢弃。不再需要时调用 Unsubscribe，缓存关闭时所有订阅的 channel 都会被关闭
+func (c *Cache) Subscribe(pattern string) <-chan Event {
+  return c.subscribe(&subscription{pattern: pattern, ch: make(chan Event, subscribeBuffer), done: make(chan struct{})})
+}
+
+//监听一个键的设置、删除、过期和淘汰事件，键中的字符不按通配符处理，清空事件也会发送。
+//ctx 结束时取消监听并关闭 channel，调用 Unsubscribe 或缓存关闭时 channel 同样被关闭。缓冲满时的行为与 Subscribe 相同
+func (c *Cache) Watch(ctx context.Context, key string) <-chan Event {
+  sub := &subscription{pattern: key, exact: true, ch: make(chan Event, subscribeBuffer), done: make(chan struct{})}
+  ch := c.subscribe(sub)
+  go func() {
+    select {
+    case <-ctx.Done():
+      c.Unsubscribe(ch)
+    case <-sub.done:
+    }
+  }()
+  return ch
+}
+
+func (c *Cache) subscribe(sub *subscription) <-chan Event {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+  if c.subs == nil {
+    c.subs = map[<-chan Event]*subscription{}
+  }
+  c.subs[sub.ch] = sub
+  atomic.AddInt32(&c.subscribers, 1)
+  return sub.ch
+}
+
+//取消订阅并关闭 ch
+func (c *Cache) Unsubscribe(ch <-chan Event) {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+  if sub, found := c.subs[ch]; found {
+    delete(c.subs, ch)
+    atomic.AddInt32(&c.subscribers, -1)
+    close(sub.ch)
+    close(sub.done)
+  }
+}
+
+func (c *Cache) unsubscribeAll() {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+  for ch, sub := range c.subs {
+    delete(c.subs, ch)
+    atomic.AddInt32(&c.subscribers, -1)
+    close(sub.ch)
+    close(sub.done)
+  }
+}
+
+//等待发送给订阅者的事件，Value 还没有设置
+type queuedEvent struct {
+  e    Event
+  item Item
+}
+
+//按记录的顺序排队，由一个 goroutine 解压后发送，队列为空时 goroutine 退出。需要持有分片的锁
+func (c *Cache) queueEvent(e Event, item Item) {
+  c.eventMu.Lock()
+  defer c.eventMu.Unlock()
+  c.events = append(c.events, queuedEvent{e, item})
+  if !c.dispatching {
+    c.dispatching = true
+    go c.dispatch()
+  }
+}
+
+func (c *Cache) dispatch() {
+  c.eventMu.Lock()
+  defer c.eventMu.Unlock()
+  for len(c.events) > 0 {
+    events := c.events
+    c.events = nil
+    c.eventMu.Unlock()
+    for _, q := range events {
+      q.e.Value = q.item.value()
+      c.publish(q.e)
+    }
+    c.eventMu.Lock()
+  }
+  c.dispatching = false
+}
+
+//把事件发送给匹配的订阅者，不会阻塞
+func (c *Cache) publish(e Event) {
+  c.subMu.RLock()
+  defer c.subMu.RUnlock()
+  for _, sub := range c.subs {
+    if !sub.match(e) {
+      continue
+    }
+    select {
+    case sub.ch <- e:
+    default:
+    }
+  }
+}
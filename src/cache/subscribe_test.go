@@ -0,0 +1,57 @@
+package cache
+
+import (
+  "context"
+  "runtime"
+  "testing"
+  "time"
+)
+
+//等待 goroutine 数量回到 n 以下
+func waitGoroutines(t *testing.T, n int) {
+  t.Helper()
+  deadline := time.Now().Add(5 * time.Second)
+  for runtime.NumGoroutine() > n {
+    if time.Now().After(deadline) {
+      t.Fatalf("%d goroutines running, want at most %d", runtime.NumGoroutine(), n)
+    }
+    time.Sleep(10 * time.Millisecond)
+  }
+}
+
+func TestWatch(t *testing.T) {
+  c := New()
+  ctx, cancel := context.WithCancel(context.Background())
+  ch := c.Watch(ctx, "k*")
+  c.Set("k*", 1)
+  c.Set("kx", 2)
+  if e := <-ch; e.Key != "k*" || e.Type != EventSet {
+    t.Fatalf("got event %v for %s", e.Type, e.Key)
+  }
+  cancel()
+  for range ch {
+    t.Fatal("got an event for a key that doesn't match exactly")
+  }
+}
+
+//ctx 不会结束时，Unsubscribe 和 Close 也能让 Watch 的 goroutine 退出
+func TestWatchUnsubscribe(t *testing.T) {
+  c := New()
+  before := runtime.NumGoroutine()
+  for i := 0; i < 10; i++ {
+    c.Unsubscribe(c.Watch(context.Background(), "k"))
+  }
+  waitGoroutines(t, before)
+
+  var chs []<-chan Event
+  for i := 0; i < 10; i++ {
+    chs = append(chs, c.Watch(context.Background(), "k"))
+  }
+  c.Close()
+  for _, ch := range chs {
+    if _, ok := <-ch; ok {
+      t.Fatal("channel is still open after Close")
+    }
+  }
+  waitGoroutines(t, before)
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+  "time"
+)
+
+//设置带标签的数据项，之后可以用 InvalidateTag 按标签删除。
+//再次设置同一个键会替换原有的标签，标签不会被 Save 保存
+func (c *Cache) SetWithTags(k string, v interface{}, d time.Duration, tags ...string) {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  if c.writable() != nil {
+    return
+  }
+  s.set(k, v, d)
+  if _, found := s.items[k]; found {
+    s.tag(k, tags)
+  }
+}
+
+//删除所有带有标签 tag 的数据项，返回删除的数量
+func (c *Cache) InvalidateTag(tag string) int {
+  removed := 0
+  for _, s := range c.shards {
+    s.mu.Lock()
+    if c.frozen {
+      s.unlock()
+      return removed
+    }
+    for k := range s.tags[tag] {
+      s.remove(k, EventDelete)
+      removed++
+    }
+    s.unlock()
+  }
+  c.releaseMemory(removed)
+  return removed
+}
+
+//返回数据项的标签，数据项不存在时返回 false
+func (c *Cache) Tags(k string) ([]string, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  item, found := s.items[k]
+  if !found || c.expired(item) {
+    return nil, false
+  }
+  return append([]string(nil), s.keyTags[k]...), true
+}
+
+//给数据项加上标签，需要持有写锁
+func (s *shard) tag(k string, tags []string) {
+  if len(tags) == 0 {
+    return
+  }
+  if s.tags == nil {
+    s.tags = map[string]map[string]struct{}{}
+    s.keyTags = map[string][]string{}
+  }
+  for _, t := range tags {
+    keys, found := s.tags[t]
+    if !found {
+      keys = map[string]struct{}{}
+      s.tags[t] = keys
+    }
+    if _, dup := keys[k]; !dup {
+      keys[k] = struct{}{}
+      s.keyTags[k] = append(s.keyTags[k], t)
+    }
+  }
+}
+
+//从标签索引中移除数据项，需要持有写锁
+func (s *shard) untag(k string) {
+  for _, t := range s.keyTags[k] {
+    keys := s.tags[t]
+    delete(keys, k)
+    if len(keys) == 0 {
+      delete(s.tags, t)
+    }
+  }
+  delete(s.keyTags, k)
+}
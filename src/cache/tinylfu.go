@@ -0,0 +1,120 @@
+package cache
+
+import (
+  "sync/atomic"
+)
+
+//频率计数的上限，计数只用低 4 位即可表示
+const maxFreq = 15
+
+//TinyLFU 使用的 count-min sketch，估计每个键最近的访问次数。
+//计数总数达到 10 倍宽度后全部减半，让旧的访问逐渐失效
+type sketch struct {
+  rows  [4][]uint8
+  mask  uint64
+  adds  int
+  limit int
+}
+
+func newSketch(width int) *sketch {
+  w := 64
+  for w < width {
+    w <<= 1
+  }
+  s := &sketch{mask: uint64(w - 1), limit: 10 * w}
+  for i := range s.rows {
+    s.rows[i] = make([]uint8, w)
+  }
+  return s
+}
+
+//键的 64 位 FNV-1a 哈希
+func keyHash(k string) uint64 {
+  h := uint64(14695981039346656037)
+  for i := 0; i < len(k); i++ {
+    h ^= uint64(k[i])
+    h *= 1099511628211
+  }
+  return h
+}
+
+//第 i 行的位置，使用双重哈希由一个哈希值得到多个位置
+func (s *sketch) index(h uint64, i int) uint64 {
+  return (h + uint64(i)*((h>>32)|1)) & s.mask
+}
+
+func (s *sketch) add(k string) {
+  h := keyHash(k)
+  for i := range s.rows {
+    if j := s.index(h, i); s.rows[i][j] < maxFreq {
+      s.rows[i][j]++
+    }
+  }
+  if s.adds++; s.adds >= s.limit {
+    for i := range s.rows {
+      for j := range s.rows[i] {
+        s.rows[i][j] >>= 1
+      }
+    }
+    s.adds /= 2
+  }
+}
+
+func (s *sketch) estimate(k string) uint8 {
+  h := keyHash(k)
+  min := uint8(maxFreq)
+  for i := range s.rows {
+    if v := s.rows[i][s.index(h, i)]; v < min {
+      min = v
+    }
+  }
+  return min
+}
+
+//记录一次访问，持有读锁或写锁时均可调用
+func (s *shard) access(k string) {
+  if s.freq == nil {
+    return
+  }
+  s.lruMu.Lock()
+  s.freq.add(k)
+  s.lruMu.Unlock()
+}
+
+//TinyLFU 准入：新数据项会导致超出上限时，按淘汰顺序找出为它腾出成本需要淘汰的数据项，
+//只有访问频率高于其中每一个时才放入，一个大的数据项不能挤掉多个更热的小数据项。
+//本分片腾不出足够的成本时按已比较的结果决定，剩余的由其他分片淘汰。需要持有写锁
+func (s *shard) admit(k string, item Item) bool {
+  if s.freq == nil || s.c.dryRun {
+    return true
+  }
+  s.access(k)
+  b := s.c.budget()
+  b.cost += item.Cost
+  b.count++
+  if !s.c.overLimit(b) {
+    return true
+  }
+  admit := true
+  s.victims(func(vk string, v Item) bool {
+    if v.Pinned || vk == k {
+      return true
+    }
+    //过期的数据项可以直接淘汰，不需要比较访问频率
+    if !s.c.expired(v) {
+      s.lruMu.Lock()
+      admit = s.freq.estimate(k) > s.freq.estimate(vk)
+      s.lruMu.Unlock()
+      if !admit {
+        return false
+      }
+    }
+    b.cost -= v.Cost
+    b.count--
+    return s.c.overLimit(b)
+  })
+  if !admit {
+    atomic.AddUint64(&s.c.stats.rejected, 1)
+  }
+  return admit
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+  "context"
+)
+
+//追踪钩子中的操作名称
+const (
+  OpGet    = "get"
+  OpSet    = "set"
+  OpDelete = "delete"
+  OpLoad   = "load"  // 调用加载函数或后端存储
+)
+
+//追踪钩子，操作开始时调用 Start，返回的 ctx 会传给加载函数，操作结束时调用返回的函数。
+//hit 只对 OpGet 有意义，带 ctx 的方法、LoadingCache 和 TieredCache 会调用钩子
+type Tracer interface {
+  Start(ctx context.Context, op, key string) (context.Context, func(hit bool, err error))
+}
+
+func noopEnd(bool, error) {}
+
+//没有设置追踪钩子时不做任何事
+func (c *Cache) trace(ctx context.Context, op, k string) (context.Context, func(bool, error)) {
+  if c.tracer == nil {
+    return ctx, noopEnd
+  }
+  return c.tracer.Start(ctx, op, k)
+}
+
+//在 OpLoad 追踪中调用加载函数
+func (c *Cache) traceLoad(ctx context.Context, k string, loader LoaderFunc) (interface{}, error) {
+  ctx, end := c.trace(ctx, OpLoad, k)
+  v, err := loader(ctx, k)
+  end(false, err)
+  return v, err
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+  "sort"
+  "time"
+)
+
+//获取数据项及其过期时间，永不过期的数据项返回零值时间
+func (c *Cache) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  item, found := s.items[k]
+  if !found || c.expired(item) || item.Negative {
+    s.mu.RUnlock()
+    c.hit(false)
+    return nil, time.Time{}, false
+  }
+  s.touch(k)
+  s.accessed(k, item)
+  s.mu.RUnlock()
+  v, err := c.read(item)
+  if err != nil {
+    c.corrupt(k, item, err)
+    c.hit(false)
+    return nil, time.Time{}, false
+  }
+  c.hit(true)
+  if item.Expiration == 0 {
+    return v, time.Time{}, true
+  }
+  return v, time.Unix(0, item.Expiration), true
+}
+
+//获取数据项，过期但仍在 WithStaleWhileRevalidate 保留期内的数据项也会返回，
+//第二个返回值表示数据项是否已经过期
+func (c *Cache) GetStale(k string) (interface{}, bool, bool) {
+  item, stale, found := c.getStale(k)
+  if !found {
+    return nil, false, false
+  }
+  v, err := c.read(item)
+  if err != nil {
+    c.corrupt(k, item, err)
+    return nil, false, false
+  }
+  return v, stale, true
+}
+
+func (c *Cache) getStale(k string) (Item, bool, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  item, found := s.items[k]
+  if !found || item.Negative || (item.Expiration != 0 && c.now() > item.Expiration+int64(c.staleFor)) {
+    s.mu.RUnlock()
+    c.hit(false)
+    return Item{}, false, false
+  }
+  s.touch(k)
+  s.accessed(k, item)
+  s.mu.RUnlock()
+  stale := c.expired(item)
+  c.hit(!stale)
+  return item, stale, true
+}
+
+//返回数据项的剩余生存时间，永不过期的数据项返回 NoExpiration
+func (c *Cache) TTL(k string) (time.Duration, bool) {
+  s := c.shard(k)
+  s.mu.RLock()
+  item, found := s.items[k]
+  s.mu.RUnlock()
+  if !found || c.expired(item) {
+    return 0, false
+  }
+  if item.Expiration == 0 {
+    return NoExpiration, true
+  }
+  return time.Unix(0, item.Expiration).Sub(c.clock.Now()), true
+}
+
+//修改已存在数据项的生存时间，d 的含义与 Set 相同，数据项不存在时返回 false。
+//滑动过期的数据项之后按 d 滑动
+func (c *Cache) Expire(k string, d time.Duration) bool {
+  if d == DefaultExpiration {
+    d = c.defaultExpiration
+  }
+  if d < 0 {
+    d = 0
+  }
+  return c.setExpiration(k, d, false)
+}
+
+//移除已存在数据项的过期时间，使其永不过期，数据项不存在时返回 false
+func (c *Cache) Persist(k string) bool {
+  return c.setExpiration(k, 0, false)
+}
+
+//重置数据项的过期时间并标记为最近使用，用于会话保活，d 的含义与 Set 相同，数据项不存在时返回 false。
+//只在写锁下修改过期时间，不读取或复制值，版本号不变，不影响正在进行的 CompareAndSwap
+func (c *Cache) Touch(k string, d time.Duration) bool {
+  if d == DefaultExpiration {
+    d = c.defaultExpiration
+  }
+  if d < 0 {
+    d = 0
+  }
+  return c.setExpiration(k, d, true)
+}
+
+//d 为 0 表示永不过期，keepVersion 为 true 时不改变版本号
+func (c *Cache) setExpiration(k string, d time.Duration, keepVersion bool) bool {
+  s := c.shard(k)
+  s.mu.Lock()
+  defer s.unlock()
+  item, found := s.items[k]
+  if !found || c.expired(item) || c.writable() != nil {
+    return false
+  }
+  item.Expiration = 0
+  if d > 0 {
+    item.Expiration = c.clock.Now().Add(d).UnixNano()
+  }
+  if item.Sliding > 0 {
+    item.Sliding = int64(d)
+  }
+  if keepVersion {
+    s.items[k] = item
+    s.publish(k, &item)
+    s.touch(k)
+    s.schedule(k, item.Expiration)
+    s.c.record(EventSet, k, item)
+    return true
+  }
+  s.update(k, item)
+  return true
+}
+
+//ExpirationHistogram 的结果
+type ExpirationForecast struct {
+  Buckets []time.Duration  // 排好序的窗口上限
+  Counts  []int            // Counts[i] 是 Buckets[i-1] 之后到 Buckets[i] 之内过期的数量
+  Later   int              // 最后一个窗口之后过期的数量
+  Never   int              // 永不过期的数量
+}
+
+//按从现在开始的时间窗口统计未过期数据项的过期时间，每个窗口只统计上一个窗口之后过期的数据项，
+//用于预估即将集中过期或刷新的数据项数量。逐个分片在读锁下遍历
+func (c *Cache) ExpirationHistogram(buckets []time.Duration) ExpirationForecast {
+  f := ExpirationForecast{
+    Buckets: append([]time.Duration(nil), buckets...),
+    Counts: make([]int, len(buckets)),
+  }
+  sort.Slice(f.Buckets, func(i, j int) bool { return f.Buckets[i] < f.Buckets[j] })
+  for _, s := range c.shards {
+    s.mu.RLock()
+    now := c.now()
+    for _, v := range s.items {
+      if v.Negative || c.expired(v) {
+        continue
+      }
+      if v.Expiration == 0 {
+        f.Never++
+        continue
+      }
+      left := time.Duration(v.Expiration - now)
+      i := sort.Search(len(f.Buckets), func(i int) bool { return f.Buckets[i] >= left })
+      if i == len(f.Buckets) {
+        f.Later++
+      } else {
+        f.Counts[i]++
+      }
+    }
+    s.mu.RUnlock()
+  }
+  return f
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+  "fmt"
+  "io"
+  "reflect"
+  "time"
+)
+
+//对 Get 的结果做类型断言，类型不符或未找到时返回 T 的零值和 false，不会 panic。
+//缓存中存的是 nil 时，只有 T 可以为 nil（指针、接口、map、切片、chan、函数）才返回 true
+func As[T any](v interface{}, ok bool) (T, bool) {
+  var zero T
+  if !ok {
+    return zero, false
+  }
+  if v == nil {
+    return zero, nilable(reflect.TypeOf((*T)(nil)).Elem())
+  }
+  t, ok := v.(T)
+  return t, ok
+}
+
+//判断该类型的值能否为 nil
+func nilable(t reflect.Type) bool {
+  switch t.Kind() {
+  case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+    return true
+  }
+  return false
+}
+
+//类型安全的缓存，在 Cache 之上包装，调用方不再需要对 Get 的结果做类型断言
+type TypedCache[K comparable, V any] struct {
+  c *Cache
+}
+
+//创建一个类型安全的缓存系统
+func NewTypedCache[K comparable, V any](defaultExpiration, gcInterval time.Duration) *TypedCache[K, V] {
+  return &TypedCache[K, V]{c: NewCache(defaultExpiration, gcInterval)}
+}
+
+//包装已有的缓存系统
+func WrapTyped[K comparable, V any](c *Cache) *TypedCache[K, V] {
+  return &TypedCache[K, V]{c: c}
+}
+
+//把键转换为底层缓存使用的字符串，同一类型的不同键得到不同的字符串
+func typedKey[K comparable](k K) string {
+  if s, ok := any(k).(string); ok {
+    return s
+  }
+  return fmt.Sprintf("%#v", k)
+}
+
+//返回底层的缓存系统
+func (t *TypedCache[K, V]) Cache() *Cache {
+  return t.c
+}
+
+func (t *TypedCache[K, V]) Set(k K, v V, d time.Duration) {
+  t.c.Set(typedKey(k), v, WithTTL(d))
+}
+
+func (t *TypedCache[K, V]) Get(k K) (V, bool) {
+  return As[V](t.c.Get(typedKey(k)))
+}
+
+func (t *TypedCache[K, V]) Add(k K, v V, d time.Duration) error {
+  return t.c.Add(typedKey(k), v, d)
+}
+
+func (t *TypedCache[K, V]) Replace(k K, v V, d time.Duration) error {
+  return t.c.Replace(typedKey(k), v, d)
+}
+
+func (t *TypedCache[K, V]) Delete(k K) {
+  t.c.Delete(typedKey(k))
+}
+
+//将数据项写入 io.Writer 中
+func (t *TypedCache[K, V]) Save(w io.Writer) error {
+  return t.c.Save(w)
+}
+
+//从 io.Reader 中读取数据项，类型不符的数据项在 Get 时视为不存在
+func (t *TypedCache[K, V]) Load(r io.Reader) error {
+  return t.c.Load(r)
+}
+
+func (t *TypedCache[K, V]) SaveToFile(file string) error {
+  return t.c.SaveToFile(file)
+}
+
+func (t *TypedCache[K, V]) LoadFile(file string) error {
+  return t.c.LoadFile(file)
+}
+
+func (t *TypedCache[K, V]) Count() int {
+  return t.c.Count()
+}
+
+func (t *TypedCache[K, V]) Flush() {
+  t.c.Flush()
+}
+
+//关闭缓存
+func (t *TypedCache[K, V]) Close() error {
+  return t.c.Close()
+}
+
+//停止过期缓存清理
+func (t *TypedCache[K, V]) StopGc() {
+  t.c.StopGc()
+}
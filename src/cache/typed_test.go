@@ -0,0 +1,90 @@
+package cache
+
+import (
+  "io"
+  "testing"
+)
+
+type asPoint struct {
+  X, Y int
+}
+
+func TestAsFound(t *testing.T) {
+  c := New()
+  c.Set("n", 42)
+  if n, ok := As[int](c.Get("n")); !ok || n != 42 {
+    t.Fatalf("As[int] = %v, %v", n, ok)
+  }
+}
+
+func TestAsMissingKey(t *testing.T) {
+  c := New()
+  if n, ok := As[int](c.Get("missing")); ok || n != 0 {
+    t.Fatalf("As[int] on a missing key = %v, %v", n, ok)
+  }
+  if p, ok := As[*asPoint](c.Get("missing")); ok || p != nil {
+    t.Fatalf("As[*asPoint] on a missing key = %v, %v", p, ok)
+  }
+}
+
+func TestAsWrongType(t *testing.T) {
+  c := New()
+  c.Set("s", "text")
+  if n, ok := As[int](c.Get("s")); ok || n != 0 {
+    t.Fatalf("As[int] on a string = %v, %v", n, ok)
+  }
+  if p, ok := As[*asPoint](c.Get("s")); ok || p != nil {
+    t.Fatalf("As[*asPoint] on a string = %v, %v", p, ok)
+  }
+  c.Set("v", asPoint{1, 2})
+  if p, ok := As[*asPoint](c.Get("v")); ok || p != nil {
+    t.Fatalf("As[*asPoint] on an asPoint value = %v, %v", p, ok)
+  }
+}
+
+func TestAsPointer(t *testing.T) {
+  c := New()
+  p := &asPoint{1, 2}
+  c.Set("p", p, WithNoCopy())
+  if got, ok := As[*asPoint](c.Get("p")); !ok || got != p {
+    t.Fatalf("As[*asPoint] = %v, %v, want %p", got, ok, p)
+  }
+  if v, ok := As[asPoint](c.Get("p")); ok {
+    t.Fatalf("As[asPoint] on a pointer = %v, %v", v, ok)
+  }
+  //指针实现了接口时可以断言为该接口
+  c.Set("r", &nopReader{}, WithNoCopy())
+  if r, ok := As[io.Reader](c.Get("r")); !ok || r == nil {
+    t.Fatalf("As[io.Reader] = %v, %v", r, ok)
+  }
+}
+
+//缓存中存的是 nil 时，只有可以为 nil 的类型返回 true
+func TestAsNil(t *testing.T) {
+  c := New()
+  c.Set("nil", nil)
+  if p, ok := As[*asPoint](c.Get("nil")); !ok || p != nil {
+    t.Fatalf("As[*asPoint] on nil = %v, %v", p, ok)
+  }
+  if r, ok := As[io.Reader](c.Get("nil")); !ok || r != nil {
+    t.Fatalf("As[io.Reader] on nil = %v, %v", r, ok)
+  }
+  if m, ok := As[map[string]int](c.Get("nil")); !ok || m != nil {
+    t.Fatalf("As[map[string]int] on nil = %v, %v", m, ok)
+  }
+  if s, ok := As[[]int](c.Get("nil")); !ok || s != nil {
+    t.Fatalf("As[[]int] on nil = %v, %v", s, ok)
+  }
+  if n, ok := As[int](c.Get("nil")); ok || n != 0 {
+    t.Fatalf("As[int] on nil = %v, %v", n, ok)
+  }
+  if v, ok := As[asPoint](c.Get("nil")); ok {
+    t.Fatalf("As[asPoint] on nil = %v, %v", v, ok)
+  }
+}
+
+type nopReader struct{}
+
+func (*nopReader) Read(p []byte) (int, error) {
+  return 0, io.EOF
+}
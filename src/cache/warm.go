@@ -0,0 +1,103 @@
+package cache
+
+import (
+  "context"
+  "time"
+)
+
+//Warm 的配置，零值字段使用默认值
+type WarmOptions struct {
+  Rate     int                // 每秒最多写入的数据项数量，0 表示不限制
+  Batch    int                // 每批写入的数量，同一分片的数据项只加一次锁，默认 256
+  Progress func(loaded int)   // 每写完一批调用一次，loaded 为已写入的总数，可以为 nil
+}
+
+//预热时等待写入的数据项
+type warmItem struct {
+  k string
+  v interface{}
+  d time.Duration
+}
+
+//调用 feed 批量预热缓存，feed 通过 yield 逐个提供数据项，d 的含义与 Set 相同，返回写入的数量。
+//ctx 结束后 yield 不再写入，Warm 返回 ctx 的错误，feed 应同时检查 ctx 以便尽早停止
+func (c *Cache) Warm(ctx context.Context, feed func(yield func(k string, v interface{}, d time.Duration)) error, o WarmOptions) (int, error) {
+  if o.Batch <= 0 {
+    o.Batch = 256
+  }
+  start := time.Now()
+  loaded := 0
+  var err error
+  batch := make([]warmItem, 0, o.Batch)
+  flush := func() {
+    if len(batch) == 0 || err != nil {
+      return
+    }
+    if err = c.warmBatch(batch); err != nil {
+      return
+    }
+    loaded += len(batch)
+    batch = batch[:0]
+    if o.Progress != nil {
+      o.Progress(loaded)
+    }
+    if o.Rate > 0 {
+      err = sleepCtx(ctx, time.Until(start.Add(time.Duration(loaded) * time.Second / time.Duration(o.Rate))))
+    }
+  }
+  ferr := feed(func(k string, v interface{}, d time.Duration) {
+    if err != nil {
+      return
+    }
+    if err = ctx.Err(); err != nil {
+      return
+    }
+    batch = append(batch, warmItem{k, v, d})
+    //限速时一批不超过每秒的数量，避免一次写入过多
+    if len(batch) >= o.Batch || (o.Rate > 0 && len(batch) >= o.Rate) {
+      flush()
+    }
+  })
+  flush()
+  if err != nil {
+    return loaded, err
+  }
+  return loaded, ferr
+}
+
+func (c *Cache) warmBatch(batch []warmItem) error {
+  keys := make([]string, len(batch))
+  index := make(map[string]int, len(batch))
+  for i, it := range batch {
+    keys[i] = it.k
+    index[it.k] = i
+  }
+  for s, keys := range c.byShard(keys) {
+    s.mu.Lock()
+    if err := c.writable(); err != nil {
+      s.unlock()
+      return err
+    }
+    for _, k := range keys {
+      it := batch[index[k]]
+      s.set(k, it.v, it.d)
+    }
+    s.unlock()
+  }
+  return nil
+}
+
+//等待 d 或 ctx 结束
+func sleepCtx(ctx context.Context, d time.Duration) error {
+  if d <= 0 {
+    return ctx.Err()
+  }
+  t := time.NewTimer(d)
+  defer t.Stop()
+  select {
+  case <-t.C:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
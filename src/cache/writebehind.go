@@ -0,0 +1,232 @@
+package cache
+
+import (
+  "context"
+  "errors"
+  "sync"
+  "time"
+)
+
+//写回队列已满且溢出策略为 OverflowDrop 时返回的错误
+var ErrQueueFull = errors.New("Write-behind queue is full.")
+
+//写回队列已满时的处理方式
+type OverflowPolicy int
+
+const (
+  OverflowBlock OverflowPolicy = iota  // 等待队列有空位或 ctx 结束
+  OverflowDrop                         // 放弃写入后端存储，返回 ErrQueueFull
+  OverflowSync                         // 直接写入后端存储
+)
+
+//写回模式的配置，零值字段使用默认值
+type WriteBehindOptions struct {
+  QueueSize  int            // 队列中最多等待写入的键数量，默认 1024
+  BatchSize  int            // 每批写入的最大数量，队列达到该数量时立即写入，默认 64
+  Interval   time.Duration  // 写入间隔，默认 1 秒
+  Retries    int            // 写入失败后的重试次数，默认 3，小于 0 表示不重试
+  RetryDelay time.Duration  // 第一次重试前的等待时间，之后每次加倍，默认 100 毫秒
+  Overflow   OverflowPolicy
+  OnError    func(key string, err error)  // 重试后仍然失败或被丢弃时调用，可以为 nil
+}
+
+//等待写入后端存储的操作
+type writeOp struct {
+  value  interface{}
+  d      time.Duration
+  delete bool
+}
+
+//写回队列，同一个键的多次写入合并为最后一次
+type writeBehind struct {
+  store   Store
+  o       WriteBehindOptions
+  mu      sync.Mutex
+  pending map[string]writeOp
+  order   []string       // 按首次入队的顺序排列的键
+  freed   chan struct{}  // 队列有空位时关闭并替换
+  kick    chan struct{}
+  stop    chan struct{}
+  done    chan struct{}
+  once    sync.Once
+  closed  bool
+}
+
+//创建一个写回模式的两级缓存，Set 和 Delete 先修改内存，再由后台 goroutine 按批写入后端存储。
+//Close 会把队列中剩余的操作写完后返回
+func NewWriteBehindCache(store Store, o WriteBehindOptions, opts ...Option) *TieredCache {
+  if o.QueueSize <= 0 {
+    o.QueueSize = 1024
+  }
+  if o.BatchSize <= 0 {
+    o.BatchSize = 64
+  }
+  if o.Interval <= 0 {
+    o.Interval = time.Second
+  }
+  if o.Retries == 0 {
+    o.Retries = 3
+  }
+  if o.RetryDelay <= 0 {
+    o.RetryDelay = 100 * time.Millisecond
+  }
+  t := NewTieredCache(store, false, opts...)
+  t.wb = &writeBehind {
+    store: store,
+    o: o,
+    pending: map[string]writeOp{},
+    freed: make(chan struct{}),
+    kick: make(chan struct{}, 1),
+    stop: make(chan struct{}),
+    done: make(chan struct{}),
+  }
+  go t.wb.loop(t.c.clock.NewTicker(o.Interval))
+  return t
+}
+
+//把操作放入队列，队列已满时按溢出策略处理
+func (w *writeBehind) enqueue(ctx context.Context, k string, op writeOp) error {
+  w.mu.Lock()
+  for {
+    if w.closed {
+      w.mu.Unlock()
+      return ErrClosed
+    }
+    if _, found := w.pending[k]; found || len(w.pending) < w.o.QueueSize {
+      break
+    }
+    switch w.o.Overflow {
+    case OverflowDrop:
+      w.mu.Unlock()
+      if w.o.OnError != nil {
+        w.o.OnError(k, ErrQueueFull)
+      }
+      return ErrQueueFull
+    case OverflowSync:
+      w.mu.Unlock()
+      return w.write(ctx, k, op)
+    }
+    freed := w.freed
+    w.mu.Unlock()
+    select {
+    case <-freed:
+    case <-ctx.Done():
+      return ctx.Err()
+    }
+    w.mu.Lock()
+  }
+  if _, found := w.pending[k]; !found {
+    w.order = append(w.order, k)
+  }
+  w.pending[k] = op
+  n := len(w.pending)
+  w.mu.Unlock()
+  if n >= w.o.BatchSize {
+    select {
+    case w.kick <- struct{}{}:
+    default:
+    }
+  }
+  return nil
+}
+
+//返回队列中等待写入的操作，不存在时第二个返回值为 false
+func (w *writeBehind) lookup(k string) (writeOp, bool) {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  op, found := w.pending[k]
+  return op, found
+}
+
+func (w *writeBehind) len() int {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  return len(w.pending)
+}
+
+func (w *writeBehind) loop(ticker Ticker) {
+  defer close(w.done)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C():
+      w.flush(context.Background())
+    case <-w.kick:
+      w.flush(context.Background())
+    case <-w.stop:
+      w.flush(context.Background())
+      return
+    }
+  }
+}
+
+//按批写入队列中的所有操作
+func (w *writeBehind) flush(ctx context.Context) {
+  for {
+    keys, ops := w.take()
+    if len(keys) == 0 {
+      return
+    }
+    for i, k := range keys {
+      if err := w.write(ctx, k, ops[i]); err != nil && w.o.OnError != nil {
+        w.o.OnError(k, err)
+      }
+    }
+  }
+}
+
+//从队列中取出最多 BatchSize 个操作
+func (w *writeBehind) take() ([]string, []writeOp) {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  n := min(len(w.order), w.o.BatchSize)
+  keys := w.order[:n:n]
+  w.order = w.order[n:]
+  ops := make([]writeOp, n)
+  for i, k := range keys {
+    ops[i] = w.pending[k]
+    delete(w.pending, k)
+  }
+  if n > 0 {
+    close(w.freed)
+    w.freed = make(chan struct{})
+  }
+  return keys, ops
+}
+
+//写入后端存储，失败时按 RetryDelay 加倍等待后重试
+func (w *writeBehind) write(ctx context.Context, k string, op writeOp) error {
+  delay := w.o.RetryDelay
+  for i := 0; ; i++ {
+    var err error
+    if op.delete {
+      err = w.store.Delete(ctx, k)
+    } else {
+      err = w.store.Set(ctx, k, op.value, op.d)
+    }
+    if err == nil || i >= w.o.Retries {
+      return err
+    }
+    timer := time.NewTimer(delay)
+    select {
+    case <-timer.C:
+    case <-ctx.Done():
+      timer.Stop()
+      return err
+    }
+    delay *= 2
+  }
+}
+
+//停止接受新的操作，写完队列中剩余的操作后返回
+func (w *writeBehind) close() {
+  w.once.Do(func() {
+    w.mu.Lock()
+    w.closed = true
+    close(w.freed)
+    w.freed = make(chan struct{})
+    w.mu.Unlock()
+    close(w.stop)
+  })
+  <-w.done
+}